@@ -24,9 +24,14 @@ require (
 	github.com/facebookgo/stack v0.0.0-20160209184415-751773369052 // indirect
 	github.com/facebookgo/subset v0.0.0-20150612182917-8dac2c3c4870 // indirect
 	github.com/golang/mock v1.2.0
+	github.com/golang/protobuf v1.3.0
+	github.com/gorilla/websocket v1.4.0
 	github.com/improbable-eng/grpc-web v0.9.1 // indirect
 	github.com/ipfs/go-log v0.0.1
 	github.com/jmhodges/levigo v1.0.0 // indirect
+	github.com/multiformats/go-multiaddr v0.0.2
+	github.com/multiformats/go-multiaddr-net v0.0.1
+	github.com/pelletier/go-toml v1.2.0
 	github.com/pkg/errors v0.8.1
 	github.com/remyoudompheng/bigfft v0.0.0-20190321074620-2f0d2b0e0001 // indirect
 	github.com/satori/go.uuid v1.2.0
@@ -38,7 +43,7 @@ require (
 	github.com/stratumn/merkle v0.0.0-20181206165707-724150182895 // indirect
 	github.com/stretchr/testify v1.3.0
 	github.com/tecbot/gorocksdb v0.0.0-20181010114359-8752a9433481 // indirect
-	go.opencensus.io v0.19.1 // indirect
+	go.opencensus.io v0.19.1
 	google.golang.org/appengine v1.4.0 // indirect
-	google.golang.org/grpc v1.19.0 // indirect
+	google.golang.org/grpc v1.19.0
 )
@@ -7,6 +7,8 @@ import (
 	"net/url"
 
 	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-connector/lib/httpapi"
 )
 
 // Those are the errors returned by the middleware.
@@ -14,6 +16,8 @@ var (
 	ErrMissingToken = errors.New("an authorization token must be provided")
 
 	ErrUnauthorizedAccount = errors.New("user is not part of the authorized entities")
+
+	ErrMissingAccountURL = errors.New("the URL of Stratumn Account APIs must be provided to authenticate requests")
 )
 
 // Middleware is the interface exposing a middleware function providing authentication.
@@ -35,59 +39,93 @@ type AccountInfo struct {
 }
 
 // NewStratumnAccountMiddleware returns a new instance of StratumnAccountMiddleware.
+// It fails when the account URL is empty: the services serving the data of
+// the connector must not silently fall back to NoAuthMiddleware.
 func NewStratumnAccountMiddleware(accountURL string, authorizedAccounts []string) (Middleware, error) {
+	return newStratumnAccountMiddleware(accountURL, authorizedAccounts)
+}
+
+func newStratumnAccountMiddleware(accountURL string, authorizedAccounts []string) (*StratumnAccountMiddleware, error) {
+	if accountURL == "" {
+		return nil, ErrMissingAccountURL
+	}
 	if _, err := url.ParseRequestURI(accountURL); err != nil {
 		return nil, errors.Wrap(err, "could not instantiate Stratumn Account Auth Middleware")
 	}
 	return &StratumnAccountMiddleware{accountURL, authorizedAccounts}, nil
 }
 
+// NewEndpointMiddleware returns the middleware of an HTTP endpoint serving
+// the data of the connector on the given address.
+// The requests are authenticated with Stratumn Account APIs when an account
+// URL is given. Without one, they are only served on a loopback address,
+// where they go through without authentication.
+func NewEndpointMiddleware(address, accountURL string, authorizedAccounts []string) (Middleware, error) {
+	if accountURL == "" && httpapi.IsLoopback(address) {
+		return NoAuthMiddleware{}, nil
+	}
+	return NewStratumnAccountMiddleware(accountURL, authorizedAccounts)
+}
+
 // WithAuth is a middleware function.
 // The incoming request must have an 'authorization' header, which is relayed
 // to the 'GET /info' route of the Account API.
 // The request is rejected if a 401 is returned and goes through otherwise.
 func (s *StratumnAccountMiddleware) WithAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		infoReq, err := http.NewRequest("GET", s.AccountURL+"/info", nil)
-		if err != nil {
-			writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+		if status, err := s.authenticate(r.Header.Get("authorization")); err != nil {
+			writeResponse(w, status, []byte(err.Error()))
 			return
 		}
 
-		// forward the authorization token to Account API.
-		token := r.Header.Get("authorization")
-		if token == "" {
-			writeResponse(w, http.StatusUnauthorized, []byte(ErrMissingToken.Error()))
-			return
-		}
-		infoReq.Header.Set("authorization", token)
+		next.ServeHTTP(w, r)
+	}
+}
 
-		infoResp, err := http.DefaultClient.Do(infoReq)
-		if err != nil {
-			writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
-			return
-		}
-		if infoResp.StatusCode >= 400 {
-			b, _ := ioutil.ReadAll(infoResp.Body)
-			writeResponse(w, http.StatusUnauthorized, b)
-			return
-		}
+// Authenticate relays an authorization token to the 'GET /info' route of
+// the Account API and checks that the account is authorized.
+func (s *StratumnAccountMiddleware) Authenticate(token string) error {
+	_, err := s.authenticate(token)
+	return err
+}
 
-		info := AccountInfo{}
-		err = json.NewDecoder(infoResp.Body).Decode(&info)
-		if err != nil {
-			writeResponse(w, http.StatusUnauthorized, []byte(err.Error()))
-			return
-		}
+// authenticate checks an authorization token and returns the HTTP status
+// of the rejected requests.
+func (s *StratumnAccountMiddleware) authenticate(token string) (int, error) {
+	infoReq, err := http.NewRequest("GET", s.AccountURL+"/info", nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
-		err = s.checkAuth(&info)
-		if err != nil {
-			writeResponse(w, http.StatusUnauthorized, []byte(err.Error()))
-			return
-		}
+	// forward the authorization token to Account API.
+	if token == "" {
+		return http.StatusUnauthorized, ErrMissingToken
+	}
+	infoReq.Header.Set("authorization", token)
 
-		next.ServeHTTP(w, r)
+	infoResp, err := http.DefaultClient.Do(infoReq)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer infoResp.Body.Close()
+
+	if infoResp.StatusCode >= 400 {
+		b, _ := ioutil.ReadAll(infoResp.Body)
+		return http.StatusUnauthorized, errors.New(string(b))
+	}
+
+	info := AccountInfo{}
+	err = json.NewDecoder(infoResp.Body).Decode(&info)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+
+	err = s.checkAuth(&info)
+	if err != nil {
+		return http.StatusUnauthorized, err
 	}
+
+	return http.StatusOK, nil
 }
 
 func (s *StratumnAccountMiddleware) checkAuth(info *AccountInfo) error {
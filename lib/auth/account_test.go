@@ -45,6 +45,25 @@ func mockAPI(middleware func(http.HandlerFunc) http.HandlerFunc) *httptest.Serve
 	return ts
 }
 
+func TestEndpointMiddleware(t *testing.T) {
+	t.Run("Does not authenticate loopback requests without an account URL", func(t *testing.T) {
+		m, err := auth.NewEndpointMiddleware("/ip4/127.0.0.1/tcp/8907", "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, auth.NoAuthMiddleware{}, m)
+	})
+
+	t.Run("Requires an account URL on other addresses", func(t *testing.T) {
+		_, err := auth.NewEndpointMiddleware("/ip4/0.0.0.0/tcp/8907", "", nil)
+		assert.Equal(t, auth.ErrMissingAccountURL, err)
+	})
+
+	t.Run("Authenticates loopback requests with an account URL", func(t *testing.T) {
+		m, err := auth.NewEndpointMiddleware("/ip4/127.0.0.1/tcp/8907", "http://localhost:4000", nil)
+		require.NoError(t, err)
+		assert.IsType(t, &auth.StratumnAccountMiddleware{}, m)
+	})
+}
+
 func TestStratumnAccountMiddleware(t *testing.T) {
 
 	t.Run("Fails if the account URL is not specified", func(t *testing.T) {
@@ -53,6 +72,11 @@ func TestStratumnAccountMiddleware(t *testing.T) {
 		assert.Contains(t, err.Error(), "could not instantiate Stratumn Account Auth Middleware")
 	})
 
+	t.Run("Fails if the account URL is empty", func(t *testing.T) {
+		_, err := auth.NewStratumnAccountMiddleware("", nil)
+		assert.Equal(t, auth.ErrMissingAccountURL, err)
+	})
+
 	t.Run("Fails if the request does not have an auth token", func(t *testing.T) {
 		accountMock := mockStratumnAccount()
 		defer accountMock.Close()
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCAuth authenticates the calls to the gRPC methods serving the data of
+// the connector.
+// The calls are authenticated with Stratumn Account APIs when an account URL
+// is given. Without one, only the calls of local clients go through, like
+// the requests to the HTTP endpoints served on a loopback address.
+type GRPCAuth struct {
+	middleware *StratumnAccountMiddleware
+}
+
+// NewGRPCAuth returns the authentication of the gRPC methods of a service.
+func NewGRPCAuth(accountURL string, authorizedAccounts []string) (*GRPCAuth, error) {
+	if accountURL == "" {
+		return &GRPCAuth{}, nil
+	}
+
+	m, err := newStratumnAccountMiddleware(accountURL, authorizedAccounts)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCAuth{middleware: m}, nil
+}
+
+// Authenticate checks the call of the context.
+// The token is read from the 'authorization' metadata, which gRPC-Web
+// clients send as an HTTP header.
+// It returns an Unauthenticated status error when the call is rejected.
+func (a *GRPCAuth) Authenticate(ctx context.Context) error {
+	if a.middleware == nil {
+		if !isLocalPeer(ctx) {
+			return status.Error(codes.Unauthenticated, ErrMissingAccountURL.Error())
+		}
+		return nil
+	}
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = values[0]
+		}
+	}
+
+	if err := a.middleware.Authenticate(token); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil
+}
+
+// isLocalPeer returns whether the call of the context comes from the local
+// host.
+func isLocalPeer(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,74 @@
+package auth_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/stratumn/go-connector/lib/auth"
+)
+
+func TestGRPCAuth(t *testing.T) {
+	call := func(ip string, token string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4242},
+		})
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", token))
+		}
+		return ctx
+	}
+
+	t.Run("Accepts local calls without an account URL", func(t *testing.T) {
+		a, err := auth.NewGRPCAuth("", nil)
+		require.NoError(t, err)
+		assert.NoError(t, a.Authenticate(call("127.0.0.1", "")))
+	})
+
+	t.Run("Rejects remote calls without an account URL", func(t *testing.T) {
+		a, err := auth.NewGRPCAuth("", nil)
+		require.NoError(t, err)
+
+		err = a.Authenticate(call("10.0.0.1", validToken))
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Authenticates the calls with an account URL", func(t *testing.T) {
+		accountMock := mockStratumnAccount()
+		defer accountMock.Close()
+
+		a, err := auth.NewGRPCAuth(accountMock.URL, nil)
+		require.NoError(t, err)
+
+		assert.NoError(t, a.Authenticate(call("10.0.0.1", validToken)))
+
+		err = a.Authenticate(call("127.0.0.1", ""))
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		err = a.Authenticate(call("127.0.0.1", "Bearer bad token"))
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Rejects the accounts that are not authorized", func(t *testing.T) {
+		accountMock := mockStratumnAccount()
+		defer accountMock.Close()
+
+		a, err := auth.NewGRPCAuth(accountMock.URL, []string{"42"})
+		require.NoError(t, err)
+
+		err = a.Authenticate(call("10.0.0.1", validToken))
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Fails if the account URL is invalid", func(t *testing.T) {
+		_, err := auth.NewGRPCAuth("test", nil)
+		assert.Error(t, err)
+	})
+}
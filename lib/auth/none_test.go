@@ -0,0 +1,24 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stratumn/go-connector/lib/auth"
+)
+
+func TestNoAuthMiddleware(t *testing.T) {
+	apiMock := mockAPI(auth.NoAuthMiddleware{}.WithAuth)
+	defer apiMock.Close()
+
+	rsp, err := http.Get(apiMock.URL + "/any")
+	require.NoError(t, err)
+
+	b, _ := ioutil.ReadAll(rsp.Body)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, []byte(apiResponse), b)
+}
@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	"github.com/pkg/errors"
+)
+
+// ShutdownTimeout is the time given to in-flight requests to complete when
+// the server is stopped.
+const ShutdownTimeout = 5 * time.Second
+
+// Listen announces on the given multiaddr (eg: /ip4/127.0.0.1/tcp/8903).
+func Listen(address string) (net.Listener, error) {
+	maddr, err := ma.NewMultiaddr(address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: bad address", address)
+	}
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: bad address", address)
+	}
+
+	lis, err := net.Listen(netAddr.Network(), netAddr.String())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return lis, nil
+}
+
+// IsLoopback returns whether a multiaddr only accepts connections from the
+// local host. Unauthenticated endpoints must only be served on such
+// addresses.
+func IsLoopback(address string) bool {
+	maddr, err := ma.NewMultiaddr(address)
+	if err != nil {
+		return false
+	}
+	return manet.IsIPLoopback(maddr)
+}
+
+// Serve serves HTTP requests on the listener until the context is done.
+// It then gracefully shuts the server down and returns nil.
+func Serve(ctx context.Context, lis net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return errors.WithStack(err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	return errors.WithStack(server.Shutdown(shutdownCtx))
+}
+
+// WriteJSON writes the JSON encoded value with the given status code.
+func WriteJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ErrorResponse is the body written by WriteError.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// WriteError writes the error message as JSON with the given status code.
+func WriteError(w http.ResponseWriter, statusCode int, err error) {
+	WriteJSON(w, statusCode, &ErrorResponse{Error: err.Error()})
+}
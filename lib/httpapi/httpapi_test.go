@@ -0,0 +1,67 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stratumn/go-connector/lib/httpapi"
+)
+
+func TestIsLoopback(t *testing.T) {
+	assert.True(t, httpapi.IsLoopback("/ip4/127.0.0.1/tcp/8909"))
+	assert.True(t, httpapi.IsLoopback("/ip6/::1/tcp/8909"))
+	assert.False(t, httpapi.IsLoopback("/ip4/0.0.0.0/tcp/8909"))
+	assert.False(t, httpapi.IsLoopback("/ip4/10.0.0.1/tcp/8909"))
+	assert.False(t, httpapi.IsLoopback("127.0.0.1:8909"))
+}
+
+func TestListen(t *testing.T) {
+	t.Run("Fails if the address is not a multiaddr", func(t *testing.T) {
+		_, err := httpapi.Listen("127.0.0.1:8903")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "127.0.0.1:8903: bad address")
+	})
+
+	t.Run("Serves requests until the context is done", func(t *testing.T) {
+		lis, err := httpapi.Listen("/ip4/127.0.0.1/tcp/0")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		doneCh := make(chan error)
+		go func() {
+			doneCh <- httpapi.Serve(ctx, lis, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "ok")
+			}))
+		}()
+
+		rsp, err := http.Get("http://" + lis.Addr().String())
+		require.NoError(t, err)
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		assert.Equal(t, "ok", string(b))
+
+		cancel()
+		assert.NoError(t, <-doneCh)
+	})
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	httpapi.WriteError(w, http.StatusBadRequest, errors.New("no way"))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("content-type"))
+
+	var rsp httpapi.ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rsp))
+	assert.Equal(t, "no way", rsp.Error)
+}
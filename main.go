@@ -20,14 +20,17 @@ import (
 	"github.com/stratumn/go-node/core/cfg"
 	"github.com/stratumn/go-node/core/manager"
 
+	"github.com/stratumn/go-connector/services/analytics"
 	"github.com/stratumn/go-connector/services/bleveparser"
 	"github.com/stratumn/go-connector/services/blevestore"
 	"github.com/stratumn/go-connector/services/client"
 	"github.com/stratumn/go-connector/services/decryption"
+	"github.com/stratumn/go-connector/services/forkdetector"
 	"github.com/stratumn/go-connector/services/livesync"
 	"github.com/stratumn/go-connector/services/logging"
 	"github.com/stratumn/go-connector/services/memorystore"
 	"github.com/stratumn/go-connector/services/parser"
+	"github.com/stratumn/go-connector/services/proxy"
 	"github.com/stratumn/go-connector/services/search"
 )
 
@@ -47,7 +50,10 @@ var (
 		&livesync.Service{},
 		&blevestore.Service{},
 		&bleveparser.Service{},
+		&forkdetector.Service{},
 		&search.Service{},
+		&analytics.Service{},
+		&proxy.Service{},
 	}
 
 	Config = core.Config{
@@ -83,6 +89,21 @@ func requireCoreConfigSet() cfg.Set {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == ReindexCmd {
+		if err := reindex(requireCoreConfigSet()); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not reindex: %s.\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == ReplayCmd {
+		if err := replay(requireCoreConfigSet(), os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not replay: %s.\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	config := requireCoreConfigSet().Configs()
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan struct{}, 1)
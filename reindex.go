@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	grpcapi "github.com/stratumn/go-node/core/app/grpcapi/service"
+	"github.com/stratumn/go-node/core/cfg"
+
+	pb "github.com/stratumn/go-connector/services/bleveparser/grpc"
+)
+
+// ReindexCmd is the command line argument that rebuilds the search index of
+// a running connector instead of starting one.
+const ReindexCmd = "reindex"
+
+// reindex asks the running connector to rebuild its search index through
+// the gRPC API.
+// It blocks until the new index is swapped in.
+func reindex(set cfg.Set) error {
+	conn, err := dial(set)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	rsp, err := pb.NewBleveparserClient(conn).Reindex(context.Background(), &pb.ReindexRequest{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Reindexed %d links in %s.\n", rsp.Links, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// dial connects to the gRPC API of the running connector.
+func dial(set cfg.Set) (*grpc.ClientConn, error) {
+	conf, ok := set.Configs()["grpcapi"].(grpcapi.Config)
+	if !ok {
+		return nil, errors.New("the grpcapi service is not configured")
+	}
+
+	addr, err := ma.NewMultiaddr(conf.Address)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	_, host, err := manet.DialArgs(addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, host, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to the connector at %s", conf.Address)
+	}
+	return conn, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-node/core/cfg"
+
+	pb "github.com/stratumn/go-connector/services/livesync/grpc"
+)
+
+// ReplayCmd is the command line argument that replays the past links of a
+// workflow to a single subscription of a running connector instead of
+// starting one.
+const ReplayCmd = "replay"
+
+// replay asks the running connector to replay the links of a workflow
+// through the gRPC API.
+// It blocks until the replayed links are processed by the subscription.
+func replay(set cfg.Set, args []string) error {
+	flags := flag.NewFlagSet(ReplayCmd, flag.ContinueOnError)
+	subscription := flags.String("subscription", "", "The name of the subscription receiving the links, for instance parser or bleveparser.")
+	workflowID := flags.String("workflow", "", "The ID of the workflow to replay.")
+	cursor := flags.String("cursor", "", "Replay the links following this cursor.")
+	linkHash := flags.String("link-hash", "", "Replay the links from the link with this hex encoded hash.")
+	since := flags.String("since", "", "Replay the links created at or after this RFC 3339 time.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *subscription == "" || *workflowID == "" {
+		return errors.New("the subscription and the workflow must be provided")
+	}
+
+	req := &pb.ReplayRequest{
+		Subscription: *subscription,
+		WorkflowId:   *workflowID,
+		Cursor:       *cursor,
+		LinkHash:     *linkHash,
+	}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		req.Since = t.UnixNano() / int64(time.Millisecond)
+	}
+
+	conn, err := dial(set)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	rsp, err := pb.NewLivesyncClient(conn).Replay(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Replayed %d links in %s.\n", rsp.Links, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,314 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	bs "github.com/blevesearch/bleve/search"
+	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-connector/services/search"
+)
+
+const (
+	// DefaultSize is the number of values returned by a terms facet when no
+	// size is given.
+	DefaultSize = 10
+
+	// MaxSize is the maximum number of values returned by a terms facet.
+	MaxSize = 100
+
+	// MaxBuckets is the maximum number of buckets of a date histogram.
+	MaxBuckets = 1000
+)
+
+// Histogram intervals.
+const (
+	Hour  = "hour"
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+	Year  = "year"
+)
+
+// DefaultTermFields are the fields counted when a request has no facet.
+var DefaultTermFields = []string{
+	"meta.action",
+	"meta.process.state",
+	"metadata.formId",
+	"metadata.groupId",
+}
+
+var (
+	// ErrBadInterval is returned when a histogram has an unknown interval.
+	ErrBadInterval = errors.New("the interval must be one of hour, day, week, month or year")
+
+	// ErrBadRange is returned when a histogram does not end after its start.
+	ErrBadRange = errors.New("the histogram must end after its start")
+
+	// ErrTooManyBuckets is returned when a histogram has too many buckets.
+	ErrTooManyBuckets = errors.Errorf("a histogram cannot have more than %d buckets", MaxBuckets)
+
+	// ErrDuplicateFacet is returned when a request has two facets on the
+	// same field.
+	ErrDuplicateFacet = errors.New("a field can only have one facet")
+)
+
+// Analyzer is the type exposed by the analytics service.
+type Analyzer interface {
+	// Facets counts the links matching a request.
+	Facets(ctx context.Context, r *Request) (*Response, error)
+}
+
+// Request describes the facets to compute.
+// When it has neither terms nor histograms, the DefaultTermFields are counted.
+type Request struct {
+	// Query restricts the counted links. All the links are counted when it
+	// is nil.
+	Query *search.Query `json:"query,omitempty"`
+
+	// Terms are the fields whose values are counted.
+	Terms []string `json:"terms,omitempty"`
+	// Size is the maximum number of values returned by each terms facet.
+	Size int `json:"size,omitempty"`
+
+	// Histograms are the date histograms to compute.
+	Histograms []*Histogram `json:"histograms,omitempty"`
+}
+
+// Histogram counts the links by date intervals of a field between two dates.
+type Histogram struct {
+	Field    string    `json:"field"`
+	Interval string    `json:"interval"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// Response contains the computed facets.
+type Response struct {
+	// Total is the number of links matching the query.
+	Total uint64 `json:"total"`
+
+	// Terms are the terms facets indexed by field.
+	Terms map[string]*TermsFacet `json:"terms"`
+	// Histograms are the date histograms indexed by field.
+	Histograms map[string][]*Bucket `json:"histograms"`
+}
+
+// TermsFacet contains the most frequent values of a field.
+type TermsFacet struct {
+	Values []*TermCount `json:"values"`
+
+	// Missing is the number of links without a value for the field.
+	Missing int `json:"missing"`
+	// Other is the number of values not returned.
+	Other int `json:"other"`
+}
+
+// TermCount is the number of links having a value.
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// Bucket is the number of links in a date interval.
+// The start is inclusive and the end exclusive.
+type Bucket struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Count int       `json:"count"`
+}
+
+type analyzer struct {
+	idx bleve.Index
+}
+
+func newAnalyzer(idx bleve.Index) Analyzer {
+	return &analyzer{
+		idx: idx,
+	}
+}
+
+// Validate checks that the request is well-formed.
+func (r *Request) Validate() error {
+	if r.Query != nil {
+		if err := r.Query.Validate(); err != nil {
+			return errors.Wrap(err, "query")
+		}
+	}
+
+	fields := map[string]struct{}{}
+	for _, f := range r.Terms {
+		if _, ok := fields[f]; ok {
+			return errors.Wrap(ErrDuplicateFacet, f)
+		}
+		fields[f] = struct{}{}
+	}
+
+	for _, h := range r.Histograms {
+		if _, ok := fields[h.Field]; ok {
+			return errors.Wrap(ErrDuplicateFacet, h.Field)
+		}
+		fields[h.Field] = struct{}{}
+
+		if _, err := h.Buckets(); err != nil {
+			return errors.Wrap(err, h.Field)
+		}
+	}
+
+	return nil
+}
+
+// Buckets returns the empty buckets of the histogram.
+func (h *Histogram) Buckets() ([]*Bucket, error) {
+	if !h.End.After(h.Start) {
+		return nil, ErrBadRange
+	}
+
+	var next func(time.Time) time.Time
+	switch h.Interval {
+	case Hour:
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	case Day:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case Week:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case Month:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case Year:
+		next = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	default:
+		return nil, ErrBadInterval
+	}
+
+	var buckets []*Bucket
+	for start := h.Start; start.Before(h.End); start = next(start) {
+		if len(buckets) == MaxBuckets {
+			return nil, ErrTooManyBuckets
+		}
+
+		end := next(start)
+		if end.After(h.End) {
+			end = h.End
+		}
+		buckets = append(buckets, &Bucket{Start: start, End: end})
+	}
+
+	return buckets, nil
+}
+
+// Facets counts the links matching a request.
+func (a *analyzer) Facets(ctx context.Context, r *Request) (*Response, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	q := r.Query
+	if q == nil {
+		q = &search.Query{}
+	}
+
+	bq, err := q.BleveQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	size := r.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if size > MaxSize {
+		size = MaxSize
+	}
+
+	terms := r.Terms
+	if len(terms) == 0 && len(r.Histograms) == 0 {
+		terms = DefaultTermFields
+	}
+
+	// Only the facets are needed, not the matching documents.
+	req := bleve.NewSearchRequestOptions(bq, 0, 0, false)
+
+	for _, f := range terms {
+		req.AddFacet(f, bleve.NewFacetRequest(f, size))
+	}
+
+	histograms := make(map[string][]*Bucket, len(r.Histograms))
+	for _, h := range r.Histograms {
+		buckets, _ := h.Buckets()
+		histograms[h.Field] = buckets
+
+		facet := bleve.NewFacetRequest(h.Field, len(buckets))
+		for _, b := range buckets {
+			facet.AddDateTimeRange(bucketName(b), b.Start, b.End)
+		}
+		req.AddFacet(h.Field, facet)
+	}
+
+	res, err := a.idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &Response{
+		Total:      res.Total,
+		Terms:      make(map[string]*TermsFacet, len(terms)),
+		Histograms: histograms,
+	}
+
+	for _, f := range terms {
+		rsp.Terms[f] = toTermsFacet(res.Facets[f])
+	}
+
+	for field, buckets := range histograms {
+		fillBuckets(buckets, res.Facets[field])
+	}
+
+	return rsp, nil
+}
+
+func bucketName(b *Bucket) string {
+	return b.Start.Format(time.RFC3339)
+}
+
+func toTermsFacet(fr *bs.FacetResult) *TermsFacet {
+	facet := &TermsFacet{Values: []*TermCount{}}
+	if fr == nil {
+		return facet
+	}
+
+	facet.Missing = fr.Missing
+	facet.Other = fr.Other
+	for _, t := range fr.Terms {
+		facet.Values = append(facet.Values, &TermCount{Term: t.Term, Count: t.Count})
+	}
+
+	// Sort ties by term so that results are stable.
+	sort.SliceStable(facet.Values, func(i, j int) bool {
+		if facet.Values[i].Count == facet.Values[j].Count {
+			return facet.Values[i].Term < facet.Values[j].Term
+		}
+		return facet.Values[i].Count > facet.Values[j].Count
+	})
+
+	return facet
+}
+
+// fillBuckets sets the counts of the buckets from the date ranges of a facet.
+// Bleve omits the empty ranges.
+func fillBuckets(buckets []*Bucket, fr *bs.FacetResult) {
+	if fr == nil {
+		return
+	}
+
+	counts := make(map[string]int, len(fr.DateRanges))
+	for _, r := range fr.DateRanges {
+		counts[r.Name] = r.Count
+	}
+
+	for _, b := range buckets {
+		b.Count = counts[bucketName(b)]
+	}
+}
@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
+	"github.com/stratumn/go-connector/services/search"
+)
+
+type handler struct {
+	analyzer Analyzer
+}
+
+// NewHandler returns an HTTP handler serving the facets endpoint.
+// GET /facets counts the DefaultTermFields of the links matching the
+// optional q full-text parameter and POST /facets computes the facets of the
+// Request given in the request body.
+// Every route is guarded by the authentication middleware.
+func NewHandler(analyzer Analyzer, m auth.Middleware) http.Handler {
+	h := &handler{analyzer: analyzer}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/facets", m.WithAuth(h.facets))
+
+	return mux
+}
+
+func (h *handler) facets(w http.ResponseWriter, r *http.Request) {
+	req := &Request{}
+
+	switch r.Method {
+	case http.MethodGet:
+		if q := r.URL.Query().Get("q"); q != "" {
+			req.Query = &search.Query{Text: q}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			httpapi.WriteError(w, http.StatusBadRequest, errors.Wrap(err, "invalid request"))
+			return
+		}
+	default:
+		httpapi.WriteError(w, http.StatusMethodNotAllowed, errors.New("facets requests must use the GET or POST method"))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		httpapi.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rsp, err := h.analyzer.Facets(r.Context(), req)
+	if err != nil {
+		log.Errorf("Facets failed: %s", err)
+		httpapi.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, rsp)
+}
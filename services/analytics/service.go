@@ -0,0 +1,166 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/blevesearch/bleve"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stratumn/go-node/core/cfg"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
+)
+
+var log = logrus.WithField("service", "analytics")
+
+var (
+	// ErrNotStore is returned when the connected service does not expose a bleve index.
+	ErrNotStore = errors.New("connected service is not exposing a bleve index")
+)
+
+// Service is the Analytics service.
+type Service struct {
+	config   *Config
+	analyzer Analyzer
+
+	handler http.Handler
+}
+
+// Config contains configuration options for the Analytics service.
+type Config struct {
+	// ConfigVersion is the version of the configuration file.
+	ConfigVersion int `toml:"configuration_version" comment:"The version of the service configuration."`
+
+	// The name of the store service used for the analytics.
+	Store string `toml:"store" comment:"The name of the store service."`
+
+	// Address is the address the HTTP analytics endpoint binds to.
+	Address string `toml:"address" comment:"Address of the HTTP analytics endpoint. Leave empty to disable it."`
+
+	// AccountURL is the URL of the Account API used to authenticate requests.
+	AccountURL string `toml:"account_url" comment:"The URL of Stratumn Account APIs used to authenticate requests. Required when the address is not a loopback address."`
+
+	// AuthorizedAccounts restricts the access to a list of accounts.
+	AuthorizedAccounts []string `toml:"authorized_accounts" comment:"The IDs of the accounts allowed to query analytics. Leave empty to allow any authenticated account."`
+}
+
+// ID returns the unique identifier of the service.
+func (s *Service) ID() string {
+	return "analytics"
+}
+
+// Name returns the human friendly name of the service.
+func (s *Service) Name() string {
+	return "Analytics"
+}
+
+// Desc returns a description of what the service does.
+func (s *Service) Desc() string {
+	return "Faceted aggregations over the indexed links"
+}
+
+// Config returns the current service configuration or creates one with
+// good default values.
+func (s *Service) Config() interface{} {
+	if s.config != nil {
+		return *s.config
+	}
+
+	return Config{
+		Store:   "blevestore",
+		Address: "/ip4/127.0.0.1/tcp/8908",
+	}
+}
+
+// SetConfig configures the service.
+func (s *Service) SetConfig(config interface{}) error {
+	conf := config.(Config)
+	s.config = &conf
+	return nil
+}
+
+// Needs returns the set of services this service depends on.
+func (s *Service) Needs() map[string]struct{} {
+	return map[string]struct{}{s.config.Store: struct{}{}}
+}
+
+// Plug sets the connected services.
+func (s *Service) Plug(exposed map[string]interface{}) error {
+	idx, ok := exposed[s.config.Store].(bleve.Index)
+	if !ok {
+		return errors.Wrap(ErrNotStore, s.config.Store)
+	}
+
+	s.analyzer = newAnalyzer(idx)
+
+	// The HTTP endpoint serves decrypted data, so it is only exposed
+	// without authentication to local clients.
+	var middleware auth.Middleware
+	if s.config.Address != "" {
+		var err error
+		middleware, err = auth.NewEndpointMiddleware(s.config.Address, s.config.AccountURL, s.config.AuthorizedAccounts)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.handler = NewHandler(s.analyzer, middleware)
+
+	return nil
+}
+
+// Expose exposes the analyzer to other services.
+// It exposes the Analyzer instance.
+func (s *Service) Expose() interface{} {
+	return s.analyzer
+}
+
+// Run starts the service.
+// It serves the HTTP analytics endpoint if an address is configured.
+func (s *Service) Run(ctx context.Context, running, stopping func()) error {
+	if s.config.Address == "" {
+		running()
+		<-ctx.Done()
+		stopping()
+
+		return errors.WithStack(ctx.Err())
+	}
+
+	lis, err := httpapi.Listen(s.config.Address)
+	if err != nil {
+		return err
+	}
+
+	running()
+	err = httpapi.Serve(ctx, lis, s.handler)
+	stopping()
+
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(ctx.Err())
+}
+
+// Migrator methods.
+
+// VersionKey is the version key.
+func (s *Service) VersionKey() string {
+	return "configuration_version"
+}
+
+// Migrations is the services migrations.
+func (s *Service) Migrations() []cfg.MigrateHandler {
+	return []cfg.MigrateHandler{
+		func(tree *cfg.Tree) error {
+			if err := tree.Set("store", "blevestore"); err != nil {
+				return err
+			}
+			if err := tree.Set("address", "/ip4/127.0.0.1/tcp/8908"); err != nil {
+				return err
+			}
+			return tree.Set("account_url", "")
+		},
+	}
+}
@@ -0,0 +1,271 @@
+package analytics_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	chainscript "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/services/analytics"
+	"github.com/stratumn/go-connector/services/blevestore"
+	"github.com/stratumn/go-connector/services/search"
+)
+
+// newTestAnalyzer returns an analyzer on an in-memory index containing a few links.
+func newTestAnalyzer(ctx context.Context, t *testing.T) analytics.Analyzer {
+	store := &blevestore.Service{}
+	store.SetConfig(store.Config())
+	runningCh := make(chan struct{})
+	go store.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+	<-runningCh
+
+	idx := store.Expose().(bleve.Index)
+
+	docs := []struct {
+		action  string
+		state   string
+		formID  string
+		groupID string
+		date    string
+	}{
+		{"Consent request", "FREE", "12", "g1", "2019-01-01T10:00:00Z"},
+		{"Initialization", "FREE", "12", "g1", "2019-01-02T10:00:00Z"},
+		{"Consent request", "DONE", "13", "g2", "2019-01-02T12:00:00Z"},
+	}
+	for i, d := range docs {
+		l, _ := chainscript.NewLinkBuilder("p", "m").WithAction(d.action).Build()
+		lb, _ := json.Marshal(l)
+		require.NoError(t, idx.Index(strconv.Itoa(i), map[string]interface{}{
+			"type": "root",
+			"raw":  string(lb),
+			"meta": map[string]interface{}{
+				"action":  d.action,
+				"process": map[string]interface{}{"state": d.state},
+			},
+			"metadata": map[string]interface{}{"formId": d.formID, "groupId": d.groupID},
+			"data":     map[string]interface{}{"date": d.date},
+		}))
+	}
+
+	s := &analytics.Service{}
+	config := s.Config().(analytics.Config)
+	config.Address = ""
+	s.SetConfig(config)
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"blevestore": idx,
+	}))
+
+	return s.Expose().(analytics.Analyzer)
+}
+
+func TestAnalyticsService_MissingAccountURL(t *testing.T) {
+	idx, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+	require.NoError(t, err)
+
+	s := &analytics.Service{}
+	config := s.Config().(analytics.Config)
+	config.Address = "/ip4/0.0.0.0/tcp/8908"
+	s.SetConfig(config)
+
+	err = s.Plug(map[string]interface{}{
+		"blevestore": idx,
+	})
+	assert.Equal(t, auth.ErrMissingAccountURL, errors.Cause(err))
+}
+
+func TestAnalyticsService_DefaultConfig(t *testing.T) {
+	plug := func(t *testing.T, config analytics.Config) {
+		idx, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+		require.NoError(t, err)
+
+		s := &analytics.Service{}
+		s.SetConfig(config)
+		assert.NoError(t, s.Plug(map[string]interface{}{
+			"blevestore": idx,
+		}))
+	}
+
+	t.Run("Plugs with the default config", func(t *testing.T) {
+		s := &analytics.Service{}
+		plug(t, s.Config().(analytics.Config))
+	})
+
+	t.Run("Plugs with the migrated config", func(t *testing.T) {
+		tree, err := toml.LoadFile("../../config.core.toml")
+		require.NoError(t, err)
+
+		var config analytics.Config
+		require.NoError(t, tree.Get("analytics").(*toml.Tree).Unmarshal(&config))
+		assert.Equal(t, "/ip4/127.0.0.1/tcp/8908", config.Address)
+		assert.Empty(t, config.AccountURL)
+
+		plug(t, config)
+	})
+}
+
+func TestAnalyticsService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	analyzer := newTestAnalyzer(ctx, t)
+
+	t.Run("Counts the default fields", func(t *testing.T) {
+		rsp, err := analyzer.Facets(ctx, &analytics.Request{})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(3), rsp.Total)
+		require.Len(t, rsp.Terms, len(analytics.DefaultTermFields))
+		assert.Equal(t, []*analytics.TermCount{
+			{Term: "Consent request", Count: 2},
+			{Term: "Initialization", Count: 1},
+		}, rsp.Terms["meta.action"].Values)
+		assert.Equal(t, []*analytics.TermCount{
+			{Term: "FREE", Count: 2},
+			{Term: "DONE", Count: 1},
+		}, rsp.Terms["meta.process.state"].Values)
+		assert.Equal(t, []*analytics.TermCount{
+			{Term: "12", Count: 2},
+			{Term: "13", Count: 1},
+		}, rsp.Terms["metadata.formId"].Values)
+		assert.Equal(t, []*analytics.TermCount{
+			{Term: "g1", Count: 2},
+			{Term: "g2", Count: 1},
+		}, rsp.Terms["metadata.groupId"].Values)
+	})
+
+	t.Run("Restricts the facets to a query", func(t *testing.T) {
+		rsp, err := analyzer.Facets(ctx, &analytics.Request{
+			Query: &search.Query{
+				Must: []*search.Clause{{Field: "meta.process.state", Term: "FREE"}},
+			},
+			Terms: []string{"meta.action"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(2), rsp.Total)
+		require.Len(t, rsp.Terms, 1)
+		assert.Equal(t, []*analytics.TermCount{
+			{Term: "Consent request", Count: 1},
+			{Term: "Initialization", Count: 1},
+		}, rsp.Terms["meta.action"].Values)
+	})
+
+	t.Run("Limits the number of values", func(t *testing.T) {
+		rsp, err := analyzer.Facets(ctx, &analytics.Request{
+			Terms: []string{"meta.action"},
+			Size:  1,
+		})
+		require.NoError(t, err)
+
+		facet := rsp.Terms["meta.action"]
+		assert.Equal(t, []*analytics.TermCount{{Term: "Consent request", Count: 2}}, facet.Values)
+		assert.Equal(t, 1, facet.Other)
+	})
+
+	t.Run("Computes date histograms", func(t *testing.T) {
+		start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		rsp, err := analyzer.Facets(ctx, &analytics.Request{
+			Histograms: []*analytics.Histogram{{
+				Field:    "data.date",
+				Interval: analytics.Day,
+				Start:    start,
+				End:      start.AddDate(0, 0, 3),
+			}},
+		})
+		require.NoError(t, err)
+
+		assert.Empty(t, rsp.Terms)
+		buckets := rsp.Histograms["data.date"]
+		require.Len(t, buckets, 3)
+		for i, count := range []int{1, 2, 0} {
+			assert.Equal(t, start.AddDate(0, 0, i), buckets[i].Start)
+			assert.Equal(t, start.AddDate(0, 0, i+1), buckets[i].End)
+			assert.Equal(t, count, buckets[i].Count, "bucket %d", i)
+		}
+	})
+
+	t.Run("Rejects invalid histograms", func(t *testing.T) {
+		start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		_, err := analyzer.Facets(ctx, &analytics.Request{
+			Histograms: []*analytics.Histogram{{Field: "data.date", Interval: "century", Start: start, End: start.AddDate(1, 0, 0)}},
+		})
+		assert.Equal(t, analytics.ErrBadInterval, errors.Cause(err))
+
+		_, err = analyzer.Facets(ctx, &analytics.Request{
+			Histograms: []*analytics.Histogram{{Field: "data.date", Interval: analytics.Day, Start: start, End: start}},
+		})
+		assert.Equal(t, analytics.ErrBadRange, errors.Cause(err))
+
+		_, err = analyzer.Facets(ctx, &analytics.Request{
+			Histograms: []*analytics.Histogram{{Field: "data.date", Interval: analytics.Hour, Start: start, End: start.AddDate(1, 0, 0)}},
+		})
+		assert.Equal(t, analytics.ErrTooManyBuckets, errors.Cause(err))
+	})
+
+	t.Run("Rejects duplicate facets", func(t *testing.T) {
+		_, err := analyzer.Facets(ctx, &analytics.Request{
+			Terms: []string{"meta.action", "meta.action"},
+		})
+		assert.Equal(t, analytics.ErrDuplicateFacet, errors.Cause(err))
+	})
+}
+
+func TestAnalyticsService_HTTP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	analyzer := newTestAnalyzer(ctx, t)
+	ts := httptest.NewServer(analytics.NewHandler(analyzer, auth.NoAuthMiddleware{}))
+	defer ts.Close()
+
+	t.Run("Counts the default fields", func(t *testing.T) {
+		rsp, err := http.Get(ts.URL + "/facets")
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+		var res analytics.Response
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(&res))
+		assert.Equal(t, uint64(3), res.Total)
+		require.Len(t, res.Terms, len(analytics.DefaultTermFields))
+		assert.Equal(t, []*analytics.TermCount{
+			{Term: "Consent request", Count: 2},
+			{Term: "Initialization", Count: 1},
+		}, res.Terms["meta.action"].Values)
+	})
+
+	t.Run("Computes the requested facets", func(t *testing.T) {
+		body := `{"terms":["metadata.formId"],"histograms":[{"field":"data.date","interval":"month","start":"2019-01-01T00:00:00Z","end":"2019-03-01T00:00:00Z"}]}`
+		rsp, err := http.Post(ts.URL+"/facets", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+		var res analytics.Response
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(&res))
+		require.Contains(t, res.Terms, "metadata.formId")
+		require.Len(t, res.Histograms["data.date"], 2)
+		assert.Equal(t, 3, res.Histograms["data.date"][0].Count)
+		assert.Equal(t, 0, res.Histograms["data.date"][1].Count)
+	})
+
+	t.Run("Rejects invalid requests", func(t *testing.T) {
+		body := `{"histograms":[{"field":"data.date","interval":"century"}]}`
+		rsp, err := http.Post(ts.URL+"/facets", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	})
+}
@@ -0,0 +1,56 @@
+package bleveparser
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/stratumn/go-connector/services/bleveparser/grpc"
+	"github.com/stratumn/go-connector/services/blevestore"
+)
+
+//go:generate protoc --proto_path=$GOPATH/src --go_out=plugins=grpc:$GOPATH/src github.com/stratumn/go-connector/services/bleveparser/grpc/bleveparser.proto
+
+var (
+	// ErrUnavailable is returned from gRPC methods when the service is not
+	// available.
+	ErrUnavailable = errors.New("the service is not available")
+)
+
+// AddToGRPCServer adds the service to a gRPC server.
+func (s *Service) AddToGRPCServer(gs *grpc.Server) {
+	pb.RegisterBleveparserServer(gs, grpcServer{
+		RunReindex: func(ctx context.Context) (uint64, error) {
+			if s.parser == nil {
+				return 0, ErrUnavailable
+			}
+			return s.parser.reindex(ctx)
+		},
+	})
+}
+
+// grpcServer is a gRPC server for the bleve parser service.
+type grpcServer struct {
+	RunReindex func(context.Context) (uint64, error)
+}
+
+// Reindex rebuilds the bleve index from scratch and swaps it with the
+// current one. Searches keep using the current index until the swap.
+func (s grpcServer) Reindex(ctx context.Context, req *pb.ReindexRequest) (*pb.ReindexResponse, error) {
+	count, err := s.RunReindex(ctx)
+	switch errors.Cause(err) {
+	case nil:
+		return &pb.ReindexResponse{Links: count}, nil
+	case ErrUnavailable:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case ErrNotReindexable, blevestore.ErrReindexing:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case context.Canceled:
+		return nil, status.Error(codes.Canceled, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+}
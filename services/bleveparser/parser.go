@@ -2,12 +2,15 @@ package bleveparser
 
 import (
 	"context"
-	"encoding/json"
+	"sync"
 
 	"github.com/pkg/errors"
 
 	"github.com/blevesearch/bleve"
 	cs "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-node/core/db"
+
+	"github.com/stratumn/go-connector/services/blevestore"
 	"github.com/stratumn/go-connector/services/livesync"
 )
 
@@ -16,89 +19,78 @@ var (
 	ErrSyncStopped = errors.New("synchronizer service stopped")
 )
 
+// SubscriptionName is the name of the livesync subscription of the parser.
+const SubscriptionName = "bleveparser"
+
 type parser struct {
 	idx          bleve.Index
 	synchronizer livesync.Synchronizer
+
+	// source is the replicated store the index is rebuilt from.
+	// The index is rebuilt from a fresh sync when it is nil.
+	source db.DB
+
+	mu sync.Mutex
+	// pending is the index being rebuilt.
+	pending bleve.Index
+	// pendingErr is the first error that occurred while indexing new links
+	// in the pending index.
+	pendingErr error
 }
 
 // saveLinks stores the links in the bleve store.
 // links are indexed by linkHash, data and metadata are deserialized.
 // raw contains the non-indexed raw link used to recreate the full link.
+// During a reindex, the links are also stored in the index being rebuilt so
+// that it does not miss the links synced while it is filled.
 func (p *parser) saveSegments(ctx context.Context, segments []*cs.Segment) error {
-	b := p.idx.NewBatch()
-	for _, s := range segments {
-		if err := indexLink(ctx, b, s.Link); err != nil {
-			return err
-		}
-	}
-
-	return p.idx.Batch(b)
-}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-func indexLink(ctx context.Context, b *bleve.Batch, l *cs.Link) error {
-	// Unmarshal link data.
-	var data interface{}
-	_ = l.StructurizeData(&data)
-
-	// Marshal raw link into bytes.
-	lb, err := json.Marshal(l)
-	if err != nil {
+	if err := indexSegments(p.idx, segments); err != nil {
 		return err
 	}
 
-	// Unmarshal metadata.
-	var md map[string]interface{}
-	_ = json.Unmarshal(l.Meta.Data, &md)
-
-	// Use link hash as document key.
-	lh, err := l.Hash()
-	if err != nil {
-		return err
+	if p.pending != nil && p.pendingErr == nil {
+		p.pendingErr = indexSegments(p.pending, segments)
 	}
 
-	// Unmarshal link meta into a map. This converts the []byte into base64 strings.
-	var lm map[string]interface{}
-	lmb, err := json.Marshal(l.Meta)
-	if err != nil {
-		return err
-	}
-	err = json.Unmarshal(lmb, &lm)
-	if err != nil {
-		return err
+	return nil
+}
+
+func indexSegments(idx bleve.Index, segments []*cs.Segment) error {
+	b := idx.NewBatch()
+	for _, s := range segments {
+		if err := blevestore.IndexLink(idx, b, s.Link); err != nil {
+			return err
+		}
 	}
 
-	return b.Index(
-		lh.String(),
-		map[string]interface{}{
-			"type":     "root",
-			"raw":      string(lb),
-			"meta":     lm,
-			"metadata": md,
-			"data":     data,
-		},
-	)
+	return idx.Batch(b)
 }
 
 // run subscribes to the livesync service and waits for updates.
+// A batch that cannot be indexed is not acknowledged so that livesync
+// delivers it again.
 // It returns an error in case the channel is closed.
 func (p *parser) run(ctx context.Context) error {
-	segmentsChan, err := p.synchronizer.Register(nil)
+	batches, err := p.synchronizer.Subscribe(SubscriptionName, nil)
 	if err != nil {
 		return err
 	}
+	defer p.synchronizer.Unsubscribe(batches)
 
 	for {
 		select {
-		case segments, more := <-segmentsChan:
+		case batch, more := <-batches:
 			if !more {
 				return ErrSyncStopped
 			}
-			if segments != nil {
-				err := p.saveSegments(ctx, segments)
-				if err != nil {
-					return err
-				}
+			if err := p.saveSegments(ctx, batch.Segments); err != nil {
+				batch.Nack(err)
+				continue
 			}
+			batch.Ack()
 		case <-ctx.Done():
 			return nil
 		}
@@ -0,0 +1,159 @@
+package bleveparser
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/blevesearch/bleve"
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-node/core/db"
+
+	"github.com/stratumn/go-connector/services/blevestore"
+	dbparser "github.com/stratumn/go-connector/services/parser"
+)
+
+// ReindexBatchSize is the number of links indexed per batch during a reindex.
+const ReindexBatchSize = 500
+
+var (
+	// ErrNotReindexable is returned when the connected store cannot rebuild
+	// its index.
+	ErrNotReindexable = errors.New("the connected store does not support reindexing")
+)
+
+// reindex rebuilds the index from scratch and swaps it with the current one.
+// The new index is filled side by side from the replicated store, or from a
+// fresh sync starting at cursor zero, while the current index keeps serving
+// searches. It returns the number of links indexed.
+func (p *parser) reindex(ctx context.Context) (uint64, error) {
+	store, ok := p.idx.(blevestore.Store)
+	if !ok {
+		return 0, ErrNotReindexable
+	}
+
+	p.mu.Lock()
+	idx, err := store.NewIndex()
+	if err != nil {
+		p.mu.Unlock()
+		return 0, err
+	}
+	p.pending, p.pendingErr = idx, nil
+	p.mu.Unlock()
+
+	var count uint64
+	if p.source != nil {
+		count, err = fillFromStore(ctx, p.source, idx)
+	} else {
+		count, err = p.fillFromSync(ctx, idx)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		err = p.pendingErr
+	}
+	p.pending, p.pendingErr = nil, nil
+
+	if err != nil {
+		if e := store.Discard(idx); e != nil {
+			log.Errorf("could not discard the rebuilt index: %s", e)
+		}
+		return 0, err
+	}
+
+	// The parser is locked so no link is indexed in the previous index
+	// after the swap.
+	if err := store.Replace(idx); err != nil {
+		return 0, err
+	}
+
+	log.Infof("Reindexed %d links", count)
+
+	return count, nil
+}
+
+// fillFromStore indexes the links of a store replicated by the parser service.
+func fillFromStore(ctx context.Context, source db.DB, idx bleve.Index) (uint64, error) {
+	iter := source.IteratePrefix(dbparser.LinkPrefix)
+	defer iter.Release()
+
+	var count uint64
+	b := idx.NewBatch()
+	for {
+		next, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !next {
+			break
+		}
+
+		var l cs.Link
+		if err := json.Unmarshal(iter.Value(), &l); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if err := blevestore.IndexLink(idx, b, &l); err != nil {
+			return 0, err
+		}
+		count++
+
+		if b.Size() >= ReindexBatchSize {
+			if err := flush(ctx, idx, b); err != nil {
+				return 0, err
+			}
+			b = idx.NewBatch()
+		}
+	}
+
+	return count, flush(ctx, idx, b)
+}
+
+// fillFromSync indexes all the links of the synced workflows, fetched from
+// cursor zero.
+func (p *parser) fillFromSync(ctx context.Context, idx bleve.Index) (uint64, error) {
+	var count uint64
+	for _, workflowID := range p.synchronizer.Workflows() {
+		cursor := ""
+		for {
+			segments, next, err := p.synchronizer.Fetch(ctx, workflowID, cursor)
+			if err != nil {
+				return 0, errors.Wrap(err, workflowID)
+			}
+			if len(segments) == 0 {
+				break
+			}
+
+			b := idx.NewBatch()
+			for _, s := range segments {
+				if err := blevestore.IndexLink(idx, b, s.Link); err != nil {
+					return 0, err
+				}
+			}
+			if err := flush(ctx, idx, b); err != nil {
+				return 0, err
+			}
+
+			count += uint64(len(segments))
+			cursor = next
+		}
+	}
+
+	return count, nil
+}
+
+// flush applies a batch unless the reindex was cancelled.
+func flush(ctx context.Context, idx bleve.Index, b *bleve.Batch) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if b.Size() == 0 {
+		return nil
+	}
+
+	return idx.Batch(b)
+}
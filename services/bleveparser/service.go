@@ -5,17 +5,28 @@ import (
 
 	"github.com/blevesearch/bleve"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stratumn/go-node/core/cfg"
+	"github.com/stratumn/go-node/core/db"
 
 	"github.com/stratumn/go-connector/services/livesync"
 )
 
+// SyncSource is the reindex source that rebuilds the index from a fresh sync.
+const SyncSource = "livesync"
+
+var log = logrus.WithField("service", "bleveparser")
+
 var (
 	// ErrNotStore is returned when the connected service is not a blevestore.
 	ErrNotStore = errors.New("connected service is not a blevestore")
 
 	// ErrNotSynchronizer is returned when the connected service is not a synchronizer.
 	ErrNotSynchronizer = errors.New("connected service is not a synchronizer")
+
+	// ErrNotSource is returned when the connected reindex source is not a
+	// key/value store.
+	ErrNotSource = errors.New("connected reindex source is not a key/value store")
 )
 
 // Service is the Parser service.
@@ -32,6 +43,9 @@ type Config struct {
 
 	// Store is the service used to store the parsed data.
 	Store string `toml:"store" comment:"The name of the store service."`
+
+	// ReindexSource is the service the index is rebuilt from.
+	ReindexSource string `toml:"reindex_source" comment:"The name of the store service replicated by the parser service to rebuild the index from, or livesync to rebuild it from a fresh sync."`
 }
 
 // ID returns the unique identifier of the service.
@@ -57,7 +71,8 @@ func (s *Service) Config() interface{} {
 	}
 
 	return Config{
-		Store: "blevestore",
+		Store:         "blevestore",
+		ReindexSource: SyncSource,
 	}
 }
 
@@ -70,10 +85,15 @@ func (s *Service) SetConfig(config interface{}) error {
 
 // Needs returns the set of services this service depends on.
 func (s *Service) Needs() map[string]struct{} {
-	return map[string]struct{}{
+	needs := map[string]struct{}{
 		"blevestore": struct{}{},
 		"livesync":   struct{}{},
 	}
+	if s.config.ReindexSource != "" {
+		needs[s.config.ReindexSource] = struct{}{}
+	}
+
+	return needs
 }
 
 // Plug sets the connected services.
@@ -89,6 +109,12 @@ func (s *Service) Plug(exposed map[string]interface{}) error {
 		return errors.Wrap(ErrNotSynchronizer, "livesync")
 	}
 
+	if s.config.ReindexSource != "" && s.config.ReindexSource != SyncSource {
+		if s.parser.source, ok = exposed[s.config.ReindexSource].(db.DB); !ok {
+			return errors.Wrap(ErrNotSource, s.config.ReindexSource)
+		}
+	}
+
 	return nil
 }
 
@@ -131,5 +157,8 @@ func (s *Service) Migrations() []cfg.MigrateHandler {
 		func(tree *cfg.Tree) error {
 			return tree.Set("store", "blevestore")
 		},
+		func(tree *cfg.Tree) error {
+			return tree.Set("reindex_source", SyncSource)
+		},
 	}
 }
@@ -2,6 +2,10 @@ package bleveparser_test
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
@@ -9,12 +13,22 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	cs "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-node/core/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	parser "github.com/stratumn/go-connector/services/bleveparser"
+	pb "github.com/stratumn/go-connector/services/bleveparser/grpc"
+	"github.com/stratumn/go-connector/services/blevestore"
 	"github.com/stratumn/go-connector/services/blevestore/mockblevestore"
+	"github.com/stratumn/go-connector/services/client/mockclient"
+	"github.com/stratumn/go-connector/services/livesync"
 	"github.com/stratumn/go-connector/services/livesync/mocksynchronizer"
+	dbparser "github.com/stratumn/go-connector/services/parser"
 )
 
 func TestParserService(t *testing.T) {
@@ -26,7 +40,10 @@ func TestParserService(t *testing.T) {
 	// mock service dependencies
 	ctrl := gomock.NewController(t)
 	synchronizer := mocksynchronizer.NewMockSynchronizer(ctrl)
+	// the parser leaves the synchronizer when it stops.
+	synchronizer.EXPECT().Unsubscribe(gomock.Any()).Return(nil).AnyTimes()
 	mockStore := mockblevestore.NewMockIndex(ctrl)
+	mockStore.EXPECT().Mapping().Return(bleve.NewIndexMapping()).AnyTimes()
 
 	// init parser service
 	p := parser.Service{}
@@ -42,9 +59,9 @@ func TestParserService(t *testing.T) {
 		// add a timeout to the context in case the cancelFunc is not called
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
 
-		// parser must register to livesync updates
-		segmentsChan := make(chan []*cs.Segment)
-		synchronizer.EXPECT().Register(gomock.Nil()).Return(segmentsChan, nil).Times(1)
+		// parser must subscribe to livesync updates
+		batches := make(chan *livesync.Batch)
+		synchronizer.EXPECT().Subscribe(parser.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
 
 		// run service
 		runningCh := make(chan struct{})
@@ -74,7 +91,8 @@ func TestParserService(t *testing.T) {
 			cancel()
 		}).Times(1)
 
-		segmentsChan <- []*cs.Segment{s1, s2}
+		batch, _ := livesync.NewBatch("p", "", []*cs.Segment{s1, s2}, 1)
+		batches <- batch
 
 		<-stoppingCh
 	})
@@ -84,9 +102,9 @@ func TestParserService(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
 		defer cancel()
 
-		// parser must register to livesync updates
-		segmentsChan := make(chan []*cs.Segment)
-		synchronizer.EXPECT().Register(gomock.Nil()).Return(segmentsChan, nil).Times(1)
+		// parser must subscribe to livesync updates
+		batches := make(chan *livesync.Batch)
+		synchronizer.EXPECT().Subscribe(parser.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
 
 		// run service
 		runningCh := make(chan struct{})
@@ -99,32 +117,32 @@ func TestParserService(t *testing.T) {
 		<-runningCh
 
 		// closing the link channel should trigger an error and stop the service
-		close(segmentsChan)
+		close(batches)
 
 		<-stoppingCh
 	})
 
-	t.Run("returns an error when saving a link failed", func(t *testing.T) {
-		// add a timeout to the context in case no error is returned
+	t.Run("does not acknowledge a batch when saving a link failed", func(t *testing.T) {
+		// add a timeout to the context in case the cancelFunc is not called
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
 		defer cancel()
 
-		// parser must register to livesync updates
-		segmentsChan := make(chan []*cs.Segment)
-		synchronizer.EXPECT().Register(gomock.Nil()).Return(segmentsChan, nil).Times(1)
+		// parser must subscribe to livesync updates
+		batches := make(chan *livesync.Batch)
+		synchronizer.EXPECT().Subscribe(parser.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
 
 		// run service
 		runningCh := make(chan struct{})
 		stoppingCh := make(chan struct{})
 		go func() {
 			err := p.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
-			assert.EqualError(t, err, "wololololo")
+			assert.EqualError(t, err, context.Canceled.Error())
 			stoppingCh <- struct{}{}
 		}()
 		<-runningCh
 
 		// send a new link through the channel and
-		// ensure that it triggers an error
+		// ensure that the error is reported to livesync
 		l, _ := cs.NewLinkBuilder("p", "map").Build()
 		s, _ := l.Segmentify()
 
@@ -134,8 +152,174 @@ func TestParserService(t *testing.T) {
 
 		mockStore.EXPECT().NewBatch().Return(b).Times(1)
 		mockStore.EXPECT().Batch(b).Return(errors.New("wololololo")).Times(1)
-		segmentsChan <- []*cs.Segment{s}
+		batch, acks := livesync.NewBatch("p", "", []*cs.Segment{s}, 1)
+		batches <- batch
 
+		assert.EqualError(t, <-acks, "wololololo")
+
+		// the parser keeps running.
+		cancel()
 		<-stoppingCh
 	})
 }
+
+func TestParserService_Reindex(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	synchronizer := mocksynchronizer.NewMockSynchronizer(ctrl)
+
+	// run an in-memory blevestore containing an outdated link
+	bs := &blevestore.Service{}
+	bs.SetConfig(bs.Config())
+	runningCh := make(chan struct{})
+	go bs.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+	<-runningCh
+	store := bs.Expose().(bleve.Index)
+
+	outdated, _ := cs.NewLinkBuilder("p", "outdated").Build()
+	b := store.NewBatch()
+	require.NoError(t, blevestore.IndexLink(store, b, outdated))
+	require.NoError(t, store.Batch(b))
+
+	l1, _ := cs.NewLinkBuilder("p", "map1").Build()
+	l2, _ := cs.NewLinkBuilder("p", "map2").Build()
+	s1, _ := l1.Segmentify()
+	s2, _ := l2.Segmentify()
+
+	// reindex connects to the parser through the gRPC API.
+	reindex := func(t *testing.T, config parser.Config, exposed map[string]interface{}) (*pb.ReindexResponse, error) {
+		p := &parser.Service{}
+		p.SetConfig(config)
+		if _, ok := exposed["livesync"]; !ok {
+			exposed["livesync"] = synchronizer
+		}
+		exposed["blevestore"] = store
+		require.NoError(t, p.Plug(exposed))
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		gs := grpc.NewServer()
+		p.AddToGRPCServer(gs)
+		go gs.Serve(lis)
+		defer gs.Stop()
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		return pb.NewBleveparserClient(conn).Reindex(ctx, &pb.ReindexRequest{})
+	}
+
+	// linkHashes returns the hashes of the indexed links.
+	linkHashes := func(t *testing.T) []string {
+		res, err := store.Search(bleve.NewSearchRequest(bleve.NewMatchAllQuery()))
+		require.NoError(t, err)
+
+		ids := []string{}
+		for _, h := range res.Hits {
+			ids = append(ids, h.ID)
+		}
+		return ids
+	}
+
+	t.Run("Rebuilds the index from a fresh sync", func(t *testing.T) {
+		synchronizer.EXPECT().Workflows().Return([]string{"p"}).Times(1)
+		synchronizer.EXPECT().Fetch(gomock.Any(), "p", "").Return([]*cs.Segment{s1}, "c1", nil).Times(1)
+		synchronizer.EXPECT().Fetch(gomock.Any(), "p", "c1").Return([]*cs.Segment{s2}, "c2", nil).Times(1)
+		synchronizer.EXPECT().Fetch(gomock.Any(), "p", "c2").Return(nil, "c2", nil).Times(1)
+
+		rsp, err := reindex(t, parser.Config{Store: "blevestore", ReindexSource: parser.SyncSource}, map[string]interface{}{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), rsp.Links)
+
+		assert.ElementsMatch(t, []string{s1.LinkHash().String(), s2.LinkHash().String()}, linkHashes(t))
+	})
+
+	t.Run("Leaves the quarantined links out", func(t *testing.T) {
+		// the hash of the second link does not match, so the verification
+		// quarantines it.
+		raw1, _ := json.Marshal(l1)
+		raw2, _ := json.Marshal(l2)
+		page := fmt.Sprintf(`{"workflowByRowId":{"links":{"edges":[{"cursor":"c1","node":{"linkHash":"%s","raw":%s}},{"cursor":"c2","node":{"linkHash":"%s","raw":%s}}],"pageInfo":{"hasNextPage":false,"endCursor":"c2"}}}}`,
+			hex.EncodeToString(s1.LinkHash()), raw1, hex.EncodeToString([]byte("mismatch")), raw2)
+
+		client := mockclient.NewMockStratumnClient(ctrl)
+		client.EXPECT().CallTraceGqlRaw(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				if _, ok := variables["cursor"]; ok {
+					return json.Unmarshal([]byte(`{"workflowByRowId":{"links":{"edges":[],"pageInfo":{"hasNextPage":false,"endCursor":""}}}}`), rsp)
+				}
+				return json.Unmarshal([]byte(page), rsp)
+			}).AnyTimes()
+		client.EXPECT().DecryptLinks(gomock.Any(), gomock.Any()).AnyTimes()
+
+		ls := &livesync.Service{}
+		ls.SetConfig(livesync.Config{
+			WatchedWorkflows: []string{"p"},
+			VerifyIntegrity:  true,
+			IntegrityPolicy:  livesync.Quarantine,
+		})
+		require.NoError(t, ls.Plug(map[string]interface{}{"stratumnClient": client}))
+
+		rsp, err := reindex(t, parser.Config{Store: "blevestore", ReindexSource: parser.SyncSource}, map[string]interface{}{
+			"livesync": ls.Expose(),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), rsp.Links)
+
+		assert.Equal(t, []string{s1.LinkHash().String()}, linkHashes(t))
+	})
+
+	t.Run("Rebuilds the index from a replicated store", func(t *testing.T) {
+		replica, err := db.NewMemDB(nil)
+		require.NoError(t, err)
+
+		lb, _ := json.Marshal(l1)
+		require.NoError(t, replica.Put(append(dbparser.LinkPrefix, s1.LinkHash()...), lb))
+
+		rsp, err := reindex(t, parser.Config{Store: "blevestore", ReindexSource: "memorystore"}, map[string]interface{}{
+			"memorystore": replica,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), rsp.Links)
+
+		assert.Equal(t, []string{s1.LinkHash().String()}, linkHashes(t))
+	})
+
+	t.Run("Keeps the current index on error", func(t *testing.T) {
+		synchronizer.EXPECT().Workflows().Return([]string{"p"}).Times(1)
+		synchronizer.EXPECT().Fetch(gomock.Any(), "p", "").Return(nil, "", errors.New("no network")).Times(1)
+
+		_, err := reindex(t, parser.Config{Store: "blevestore", ReindexSource: parser.SyncSource}, map[string]interface{}{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		assert.Equal(t, []string{s1.LinkHash().String()}, linkHashes(t))
+	})
+
+	t.Run("Requires a reindexable store", func(t *testing.T) {
+		p := &parser.Service{}
+		p.SetConfig(parser.Config{Store: "blevestore", ReindexSource: parser.SyncSource})
+		require.NoError(t, p.Plug(map[string]interface{}{
+			"livesync":   synchronizer,
+			"blevestore": mockblevestore.NewMockIndex(ctrl),
+		}))
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		gs := grpc.NewServer()
+		p.AddToGRPCServer(gs)
+		go gs.Serve(lis)
+		defer gs.Stop()
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = pb.NewBleveparserClient(conn).Reindex(ctx, &pb.ReindexRequest{})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
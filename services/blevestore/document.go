@@ -0,0 +1,85 @@
+package blevestore
+
+import (
+	"encoding/json"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+	cs "github.com/stratumn/go-chainscript"
+)
+
+// IndexLink adds a link to a batch of the index.
+// Links are indexed by link hash, data and metadata are deserialized and
+// raw contains the non-indexed raw link used to recreate the full link.
+func IndexLink(idx bleve.Index, b *bleve.Batch, l *cs.Link) error {
+	id, doc, err := LinkDocument(idx.Mapping(), l)
+	if err != nil {
+		return err
+	}
+
+	return b.Index(id, doc)
+}
+
+// LinkDocument returns the ID and the document indexing a link.
+// The document type is the type of the link form if the index mapping has
+// one, otherwise the type of the link workflow if it has one, and the default
+// type otherwise.
+func LinkDocument(m mapping.IndexMapping, l *cs.Link) (string, map[string]interface{}, error) {
+	// Unmarshal link data.
+	var data interface{}
+	_ = l.StructurizeData(&data)
+
+	// Marshal raw link into bytes.
+	lb, err := json.Marshal(l)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Unmarshal metadata.
+	var md map[string]interface{}
+	_ = json.Unmarshal(l.Meta.Data, &md)
+
+	// Use link hash as document key.
+	lh, err := l.Hash()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Unmarshal link meta into a map. This converts the []byte into base64 strings.
+	var lm map[string]interface{}
+	lmb, err := json.Marshal(l.Meta)
+	if err != nil {
+		return "", nil, err
+	}
+	err = json.Unmarshal(lmb, &lm)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return lh.String(), map[string]interface{}{
+		"type":     documentType(m, l, md),
+		"raw":      string(lb),
+		"meta":     lm,
+		"metadata": md,
+		"data":     data,
+	}, nil
+}
+
+func documentType(m mapping.IndexMapping, l *cs.Link, md map[string]interface{}) string {
+	impl, ok := m.(*mapping.IndexMappingImpl)
+	if !ok || l.Meta.Process == nil {
+		return DefaultType
+	}
+
+	workflowID := l.Meta.Process.Name
+	if formID, ok := md["formId"].(string); ok {
+		if _, ok := impl.TypeMapping[FormType(workflowID, formID)]; ok {
+			return FormType(workflowID, formID)
+		}
+	}
+	if _, ok := impl.TypeMapping[WorkflowType(workflowID)]; ok {
+		return WorkflowType(workflowID)
+	}
+
+	return DefaultType
+}
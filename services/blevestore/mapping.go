@@ -0,0 +1,354 @@
+package blevestore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// Field types of the mapping file.
+const (
+	TextField    = "text"
+	KeywordField = "keyword"
+	NumberField  = "number"
+	DateField    = "date"
+	BooleanField = "boolean"
+)
+
+// DefaultType is the document type of the links of the workflows and forms
+// without a declared mapping.
+const DefaultType = "root"
+
+var (
+	// ErrUnknownMappingFormat is returned when the mapping file is neither a
+	// JSON nor a TOML file.
+	ErrUnknownMappingFormat = errors.New("the mapping file must have a .json or .toml extension")
+
+	// ErrMissingID is returned when a workflow or form mapping has no ID.
+	ErrMissingID = errors.New("workflow and form mappings must have an ID")
+
+	// ErrMissingPath is returned when a field mapping has no path.
+	ErrMissingPath = errors.New("field mappings must have a path")
+
+	// ErrBadFieldType is returned when a field mapping has an unknown type.
+	ErrBadFieldType = errors.New("the field type must be one of text, keyword, number, date or boolean")
+
+	// ErrMisplacedOption is returned when an analyzer or date format is set
+	// on a field of the wrong type.
+	ErrMisplacedOption = errors.New("analyzers are only supported on text fields and date formats on date fields")
+)
+
+// MappingConfig declares the typed mappings of the link data per workflow
+// and form.
+type MappingConfig struct {
+	Workflows []WorkflowMapping `json:"workflows" toml:"workflows"`
+}
+
+// WorkflowMapping declares the data fields of the links of a workflow.
+type WorkflowMapping struct {
+	// ID is the ID of the workflow (the process name of its links).
+	ID string `json:"id" toml:"id"`
+
+	// Fields are the mappings shared by all the forms of the workflow.
+	Fields []FieldMapping `json:"fields" toml:"fields"`
+
+	// Forms are the mappings specific to a form.
+	Forms []FormMapping `json:"forms" toml:"forms"`
+}
+
+// FormMapping declares the data fields of the links created by a form.
+type FormMapping struct {
+	// ID is the ID of the form (the formId of the link metadata).
+	ID string `json:"id" toml:"id"`
+
+	// Fields override the workflow fields with the same path.
+	Fields []FieldMapping `json:"fields" toml:"fields"`
+}
+
+// FieldMapping declares how a data field is indexed.
+type FieldMapping struct {
+	// Path is the dot-separated path of the field in the link data.
+	Path string `json:"path" toml:"path"`
+
+	// Type is one of text, keyword, number, date or boolean.
+	Type string `json:"type" toml:"type"`
+
+	// Analyzer is the name of the bleve analyzer of a text field.
+	Analyzer string `json:"analyzer,omitempty" toml:"analyzer"`
+
+	// DateFormat is the name of the bleve date time parser of a date field.
+	DateFormat string `json:"dateFormat,omitempty" toml:"date_format"`
+
+	// Exclude removes the field, or the whole sub-document, from the index.
+	Exclude bool `json:"exclude,omitempty" toml:"exclude"`
+}
+
+// WorkflowType returns the document type of the links of a workflow.
+func WorkflowType(workflowID string) string {
+	return "workflow:" + workflowID
+}
+
+// FormType returns the document type of the links created by a form.
+func FormType(workflowID, formID string) string {
+	return WorkflowType(workflowID) + "/form:" + formID
+}
+
+// LoadMappingConfig reads a JSON or TOML mapping file.
+func LoadMappingConfig(path string) (*MappingConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	conf := &MappingConfig{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, conf)
+	case ".toml":
+		err = toml.Unmarshal(b, conf)
+	default:
+		return nil, errors.Wrap(ErrUnknownMappingFormat, path)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	return conf, nil
+}
+
+// Validate checks that the mapping config is well-formed.
+func (c *MappingConfig) Validate() error {
+	for _, w := range c.Workflows {
+		if w.ID == "" {
+			return ErrMissingID
+		}
+		if err := validateFields(w.Fields); err != nil {
+			return errors.Wrapf(err, "workflow %s", w.ID)
+		}
+
+		for _, f := range w.Forms {
+			if f.ID == "" {
+				return errors.Wrapf(ErrMissingID, "workflow %s", w.ID)
+			}
+			if err := validateFields(f.Fields); err != nil {
+				return errors.Wrapf(err, "workflow %s, form %s", w.ID, f.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateFields(fields []FieldMapping) error {
+	for _, f := range fields {
+		if f.Path == "" {
+			return ErrMissingPath
+		}
+		if f.Exclude {
+			continue
+		}
+
+		switch f.Type {
+		case TextField, KeywordField, NumberField, DateField, BooleanField:
+		default:
+			return errors.Wrap(ErrBadFieldType, f.Path)
+		}
+
+		if (f.Analyzer != "" && f.Type != TextField) || (f.DateFormat != "" && f.Type != DateField) {
+			return errors.Wrap(ErrMisplacedOption, f.Path)
+		}
+	}
+
+	return nil
+}
+
+// buildMapping creates the index mapping.
+// Links of a workflow or form declared in the mapping config are indexed
+// with their own document type, whose data fields follow the declared
+// mappings. All the other links use the default root type.
+func buildMapping(conf *MappingConfig) *mapping.IndexMappingImpl {
+	m := bleve.NewIndexMapping()
+	m.TypeField = "type"
+
+	m.AddDocumentMapping(DefaultType, buildDocumentMapping(nil))
+
+	if conf == nil {
+		return m
+	}
+
+	for _, w := range conf.Workflows {
+		m.AddDocumentMapping(WorkflowType(w.ID), buildDocumentMapping(w.Fields))
+
+		for _, f := range w.Forms {
+			// Form fields override the workflow fields with the same path.
+			fields := make([]FieldMapping, 0, len(w.Fields)+len(f.Fields))
+			overridden := make(map[string]struct{}, len(f.Fields))
+			for _, field := range f.Fields {
+				overridden[field.Path] = struct{}{}
+			}
+			for _, field := range w.Fields {
+				if _, ok := overridden[field.Path]; !ok {
+					fields = append(fields, field)
+				}
+			}
+			fields = append(fields, f.Fields...)
+
+			m.AddDocumentMapping(FormType(w.ID, f.ID), buildDocumentMapping(fields))
+		}
+	}
+
+	return m
+}
+
+// buildDocumentMapping creates the mapping of a link document.
+// The raw field contains the non-indexed raw link in string. The data field
+// is indexed and saved, it contains the unmarshaled link.data whose declared
+// fields have a static mapping and other fields are mapped dynamically.
+// The meta field is indexed and not saved, it has a static mapping and
+// contains link.meta. The metadata field is indexed, it contains the
+// unmarshaled link.meta.data and is mapped dynamically.
+// The action, map ID, process state and metadata fields are indexed as
+// keywords so that they can be filtered on exact terms. Existing indexes
+// must be rebuilt with the reindex command to pick up this mapping.
+func buildDocumentMapping(fields []FieldMapping) *mapping.DocumentMapping {
+	root := bleve.NewDocumentMapping()
+
+	textFieldNotIndexed := bleve.NewTextFieldMapping()
+	textFieldNotIndexed.Index = false
+
+	textFieldNotStored := bleve.NewTextFieldMapping()
+	textFieldNotStored.Store = false
+
+	numFieldNotIndexed := bleve.NewNumericFieldMapping()
+	numFieldNotIndexed.Index = false
+
+	numFieldNotStored := bleve.NewNumericFieldMapping()
+	numFieldNotStored.Store = false
+
+	keywordFieldNotStored := bleve.NewTextFieldMapping()
+	keywordFieldNotStored.Analyzer = keyword.Name
+	keywordFieldNotStored.Store = false
+
+	// META
+	meta := bleve.NewDocumentStaticMapping()
+	meta.AddFieldMappingsAt("action", keywordFieldNotStored)
+	meta.AddFieldMappingsAt("mapId", keywordFieldNotStored)
+	meta.AddFieldMappingsAt("step", textFieldNotStored)
+	meta.AddFieldMappingsAt("tags", textFieldNotStored)
+	meta.AddFieldMappingsAt("prevLinkHash", textFieldNotStored)
+	meta.AddFieldMappingsAt("prevLinkHash", numFieldNotStored)
+	meta.AddFieldMappingsAt("priority", numFieldNotStored)
+
+	// META.PROCESS
+	process := bleve.NewDocumentStaticMapping()
+	process.AddFieldMappingsAt("name", textFieldNotStored)
+	process.AddFieldMappingsAt("state", keywordFieldNotStored)
+	meta.AddSubDocumentMapping("process", process)
+
+	root.AddSubDocumentMapping("meta", meta)
+
+	// METADATA
+	// The metadata keys are not known in advance, they are all mapped
+	// dynamically as keywords.
+	metadata := bleve.NewDocumentMapping()
+	metadata.DefaultAnalyzer = keyword.Name
+
+	root.AddSubDocumentMapping("metadata", metadata)
+
+	// DATA
+	data := bleve.NewDocumentMapping()
+	for _, f := range fields {
+		addDataField(data, strings.Split(f.Path, "."), f)
+	}
+	root.AddSubDocumentMapping("data", data)
+
+	// RAW
+	root.AddFieldMappingsAt("raw", numFieldNotIndexed)
+	root.AddFieldMappingsAt("raw", textFieldNotIndexed)
+
+	root.Dynamic = false
+
+	return root
+}
+
+// hasKeywordMapping checks whether an index mapping indexes the action,
+// map ID, process state and metadata fields as keywords.
+// Indexes created before these fields were mapped as keywords keep their
+// analyzed mapping, so term filters on them only match after a reindex.
+func hasKeywordMapping(m mapping.IndexMapping) bool {
+	impl, ok := m.(*mapping.IndexMappingImpl)
+	if !ok {
+		return false
+	}
+
+	root, ok := impl.TypeMapping[DefaultType]
+	if !ok {
+		return false
+	}
+
+	meta, ok := root.Properties["meta"]
+	if !ok {
+		return false
+	}
+
+	action, ok := meta.Properties["action"]
+	if !ok || len(action.Fields) == 0 || action.Fields[0].Analyzer != keyword.Name {
+		return false
+	}
+
+	metadata, ok := root.Properties["metadata"]
+	if !ok {
+		return false
+	}
+
+	return metadata.Dynamic && metadata.DefaultAnalyzer == keyword.Name
+}
+
+// addDataField adds the mapping of a field to the data document mapping,
+// creating the intermediate sub-documents.
+func addDataField(dm *mapping.DocumentMapping, path []string, f FieldMapping) {
+	for _, name := range path[:len(path)-1] {
+		sub, ok := dm.Properties[name]
+		if !ok {
+			sub = bleve.NewDocumentMapping()
+			dm.AddSubDocumentMapping(name, sub)
+		}
+		dm = sub
+	}
+
+	name := path[len(path)-1]
+	if f.Exclude {
+		dm.AddSubDocumentMapping(name, bleve.NewDocumentDisabledMapping())
+		return
+	}
+
+	var fm *mapping.FieldMapping
+	switch f.Type {
+	case TextField:
+		fm = bleve.NewTextFieldMapping()
+		fm.Analyzer = f.Analyzer
+	case KeywordField:
+		fm = bleve.NewTextFieldMapping()
+		fm.Analyzer = keyword.Name
+	case NumberField:
+		fm = bleve.NewNumericFieldMapping()
+	case DateField:
+		fm = bleve.NewDateTimeFieldMapping()
+		fm.DateFormat = f.DateFormat
+	case BooleanField:
+		fm = bleve.NewBooleanFieldMapping()
+	}
+
+	dm.AddFieldMappingsAt(name, fm)
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stratumn/go-node/core/cfg"
 )
 
+var log = logrus.WithField("service", "blevestore")
+
 // Service is the Blevestore service.
 type Service struct {
 	config *Config
@@ -21,6 +24,9 @@ type Config struct {
 
 	// Path is the path to the bleve store data.
 	Path string `toml:"path" comment:"The path to the bleve store data."`
+
+	// MappingFile is the path to the file declaring the index mappings.
+	MappingFile string `toml:"mapping_file" comment:"The path to a JSON or TOML file declaring typed index mappings per workflow and form. Leave empty to index the link data dynamically."`
 }
 
 // ID returns the unique identifier of the service.
@@ -66,15 +72,15 @@ func (s *Service) Plug(exposed map[string]interface{}) error {
 }
 
 // Expose exposes the database client to other services.
-// It exposes the database instance.
+// It exposes a Store, which is an alias of the current index.
 func (s *Service) Expose() interface{} {
-	return s.store.idx
+	return s.store
 }
 
 // Run starts the service.
 func (s *Service) Run(ctx context.Context, running, stopping func()) error {
 	var err error
-	s.store, err = newStore(s.config.Path)
+	s.store, err = newStore(s.config.Path, s.config.MappingFile)
 	if err != nil {
 		return err
 	}
@@ -99,5 +105,8 @@ func (s *Service) Migrations() []cfg.MigrateHandler {
 		func(tree *cfg.Tree) error {
 			return tree.Set("path", "bleve_store")
 		},
+		func(tree *cfg.Tree) error {
+			return tree.Set("mapping_file", "")
+		},
 	}
 }
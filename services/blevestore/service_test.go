@@ -0,0 +1,352 @@
+package blevestore_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stratumn/go-connector/services/blevestore"
+)
+
+const mappingTOML = `
+[[workflows]]
+  id = "211"
+
+  [[workflows.fields]]
+    path = "applicant.name"
+    type = "keyword"
+
+  [[workflows.fields]]
+    path = "amount"
+    type = "number"
+
+  [[workflows.forms]]
+    id = "2945"
+
+    [[workflows.forms.fields]]
+      path = "secret"
+      exclude = true
+
+    [[workflows.forms.fields]]
+      path = "amount"
+      type = "text"
+      analyzer = "standard"
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func runStore(ctx context.Context, t *testing.T, config blevestore.Config) bleve.Index {
+	s := &blevestore.Service{}
+	s.SetConfig(config)
+
+	runningCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Run(ctx, func() { runningCh <- struct{}{} }, func() {}) }()
+
+	select {
+	case <-runningCh:
+	case err := <-errCh:
+		require.NoError(t, err)
+	}
+
+	return s.Expose().(bleve.Index)
+}
+
+func indexLink(t *testing.T, idx bleve.Index, workflowID, formID string, data map[string]interface{}) string {
+	l, err := cs.NewLinkBuilder(workflowID, "m").
+		WithData(data).
+		WithMetadata(map[string]interface{}{"formId": formID}).
+		Build()
+	require.NoError(t, err)
+
+	b := idx.NewBatch()
+	require.NoError(t, blevestore.IndexLink(idx, b, l))
+	require.NoError(t, idx.Batch(b))
+
+	lh, _ := l.Hash()
+	return lh.String()
+}
+
+func search(t *testing.T, idx bleve.Index, q query.Query) []string {
+	res, err := idx.Search(bleve.NewSearchRequest(q))
+	require.NoError(t, err)
+
+	ids := []string{}
+	for _, h := range res.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestBlevestoreService_Mapping(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "blevestore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	idx := runStore(ctx, t, blevestore.Config{
+		MappingFile: writeFile(t, dir, "mapping.toml", mappingTOML),
+	})
+
+	wf := indexLink(t, idx, "211", "2944", map[string]interface{}{
+		"applicant": map[string]interface{}{"name": "Alice Liddell"},
+		"amount":    42,
+		"secret":    "rabbit",
+	})
+	form := indexLink(t, idx, "211", "2945", map[string]interface{}{
+		"applicant": map[string]interface{}{"name": "Bob Dylan"},
+		"amount":    "forty two",
+		"secret":    "hole",
+	})
+	other := indexLink(t, idx, "212", "2945", map[string]interface{}{
+		"applicant": map[string]interface{}{"name": "Alice Liddell"},
+		"secret":    "rabbit",
+	})
+
+	t.Run("Uses the workflow and form document types", func(t *testing.T) {
+		tests := []struct {
+			workflowID string
+			formID     string
+			docType    string
+		}{
+			{"211", "2944", blevestore.WorkflowType("211")},
+			{"211", "2945", blevestore.FormType("211", "2945")},
+			{"212", "2945", blevestore.DefaultType},
+		}
+		for _, tt := range tests {
+			l, _ := cs.NewLinkBuilder(tt.workflowID, "m").
+				WithMetadata(map[string]interface{}{"formId": tt.formID}).
+				Build()
+
+			_, doc, err := blevestore.LinkDocument(idx.Mapping(), l)
+			require.NoError(t, err)
+			assert.Equal(t, tt.docType, doc["type"])
+		}
+	})
+
+	t.Run("Indexes keywords", func(t *testing.T) {
+		q := bleve.NewTermQuery("Alice Liddell")
+		q.SetField("data.applicant.name")
+		assert.Equal(t, []string{wf}, search(t, idx, q))
+	})
+
+	t.Run("Indexes numbers", func(t *testing.T) {
+		min, max := 40., 50.
+		q := bleve.NewNumericRangeQuery(&min, &max)
+		q.SetField("data.amount")
+		assert.Equal(t, []string{wf}, search(t, idx, q))
+	})
+
+	t.Run("Form fields override workflow fields", func(t *testing.T) {
+		q := bleve.NewMatchQuery("forty")
+		q.SetField("data.amount")
+		assert.Equal(t, []string{form}, search(t, idx, q))
+	})
+
+	t.Run("Excludes fields", func(t *testing.T) {
+		q := bleve.NewMatchQuery("hole")
+		q.SetField("data.secret")
+		assert.Empty(t, search(t, idx, q))
+
+		q = bleve.NewMatchQuery("rabbit")
+		q.SetField("data.secret")
+		assert.ElementsMatch(t, []string{wf, other}, search(t, idx, q))
+	})
+
+	t.Run("Maps undeclared workflows dynamically", func(t *testing.T) {
+		q := bleve.NewMatchQuery("liddell")
+		q.SetField("data.applicant.name")
+		assert.Equal(t, []string{other}, search(t, idx, q))
+	})
+}
+
+func TestBlevestoreService_MappingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blevestore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("Loads JSON files", func(t *testing.T) {
+		path := writeFile(t, dir, "mapping.json", `{"workflows":[{"id":"211","fields":[{"path":"date","type":"date"}],"forms":[{"id":"2945","fields":[{"path":"notes","exclude":true}]}]}]}`)
+
+		conf, err := blevestore.LoadMappingConfig(path)
+		require.NoError(t, err)
+		require.Len(t, conf.Workflows, 1)
+		assert.Equal(t, "211", conf.Workflows[0].ID)
+		assert.Equal(t, []blevestore.FieldMapping{{Path: "date", Type: blevestore.DateField}}, conf.Workflows[0].Fields)
+		require.Len(t, conf.Workflows[0].Forms, 1)
+		assert.True(t, conf.Workflows[0].Forms[0].Fields[0].Exclude)
+	})
+
+	t.Run("Loads TOML files", func(t *testing.T) {
+		conf, err := blevestore.LoadMappingConfig(writeFile(t, dir, "mapping.toml", mappingTOML))
+		require.NoError(t, err)
+		require.Len(t, conf.Workflows, 1)
+		assert.Len(t, conf.Workflows[0].Fields, 2)
+		require.Len(t, conf.Workflows[0].Forms, 1)
+		assert.Equal(t, "standard", conf.Workflows[0].Forms[0].Fields[1].Analyzer)
+	})
+
+	t.Run("Rejects unknown formats", func(t *testing.T) {
+		_, err := blevestore.LoadMappingConfig(writeFile(t, dir, "mapping.yml", ""))
+		assert.Equal(t, blevestore.ErrUnknownMappingFormat, errors.Cause(err))
+	})
+
+	t.Run("Rejects unknown field types", func(t *testing.T) {
+		_, err := blevestore.LoadMappingConfig(writeFile(t, dir, "bad_type.json", `{"workflows":[{"id":"211","fields":[{"path":"date","type":"time"}]}]}`))
+		assert.Equal(t, blevestore.ErrBadFieldType, errors.Cause(err))
+	})
+
+	t.Run("Rejects misplaced options", func(t *testing.T) {
+		_, err := blevestore.LoadMappingConfig(writeFile(t, dir, "bad_option.json", `{"workflows":[{"id":"211","fields":[{"path":"date","type":"number","analyzer":"en"}]}]}`))
+		assert.Equal(t, blevestore.ErrMisplacedOption, errors.Cause(err))
+	})
+
+	t.Run("Rejects mappings without ID", func(t *testing.T) {
+		_, err := blevestore.LoadMappingConfig(writeFile(t, dir, "no_id.json", `{"workflows":[{"fields":[]}]}`))
+		assert.Equal(t, blevestore.ErrMissingID, errors.Cause(err))
+	})
+}
+
+func TestBlevestoreService_Reindex(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "blevestore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "index")
+	mappingFile := writeFile(t, dir, "mapping.toml", "")
+
+	store, ok := runStore(ctx, t, blevestore.Config{
+		Path:        path,
+		MappingFile: mappingFile,
+	}).(blevestore.Store)
+	require.True(t, ok, "the service must expose a store")
+
+	data := map[string]interface{}{
+		"applicant": map[string]interface{}{"name": "Alice Liddell"},
+	}
+	wf := indexLink(t, store, "211", "2944", data)
+
+	q := bleve.NewTermQuery("Alice Liddell")
+	q.SetField("data.applicant.name")
+	require.Empty(t, search(t, store, q))
+
+	t.Run("Swaps in a rebuilt index with the new mapping", func(t *testing.T) {
+		writeFile(t, dir, "mapping.toml", mappingTOML)
+
+		idx, err := store.NewIndex()
+		require.NoError(t, err)
+		assert.Empty(t, search(t, idx, bleve.NewMatchAllQuery()))
+
+		_, err = store.NewIndex()
+		assert.Equal(t, blevestore.ErrReindexing, errors.Cause(err))
+
+		indexLink(t, idx, "211", "2944", data)
+
+		// The current index is used until the swap.
+		assert.Empty(t, search(t, store, q))
+
+		require.NoError(t, store.Replace(idx))
+		assert.Equal(t, []string{wf}, search(t, store, q))
+
+		_, err = os.Stat(path)
+		assert.NoError(t, err)
+		_, err = os.Stat(path + blevestore.ReindexSuffix)
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(path + blevestore.BackupSuffix)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Discards a rebuilt index", func(t *testing.T) {
+		idx, err := store.NewIndex()
+		require.NoError(t, err)
+
+		require.NoError(t, store.Discard(idx))
+		assert.Equal(t, []string{wf}, search(t, store, q))
+
+		_, err = os.Stat(path + blevestore.ReindexSuffix)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Rejects unknown indexes", func(t *testing.T) {
+		idx, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+		require.NoError(t, err)
+
+		assert.Equal(t, blevestore.ErrUnknownIndex, errors.Cause(store.Replace(idx)))
+		assert.Equal(t, blevestore.ErrUnknownIndex, errors.Cause(store.Discard(idx)))
+	})
+}
+
+func TestBlevestoreService_Recover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// newIndex creates a closed index on disk containing a single link.
+	newIndex := func(t *testing.T, path, workflowID string) string {
+		idx, err := bleve.New(path, bleve.NewIndexMapping())
+		require.NoError(t, err)
+		defer idx.Close()
+
+		return indexLink(t, idx, workflowID, "2944", map[string]interface{}{"life": "42"})
+	}
+
+	assertClean := func(t *testing.T, path string) {
+		_, err := os.Stat(path + blevestore.BackupSuffix)
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(path + blevestore.ReindexSuffix)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	tests := []struct {
+		name     string
+		path     bool
+		backup   bool
+		reindex  bool
+		expected string
+	}{
+		{"Moves in the rebuilt index after the previous one was moved aside", false, true, true, "reindex"},
+		{"Restores the previous index if the rebuilt one is gone", false, true, false, "backup"},
+		{"Removes the leftovers of an interrupted swap or reindex", true, true, true, "path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "blevestore")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "index")
+			links := map[string]string{}
+			if tt.path {
+				links["path"] = newIndex(t, path, "1")
+			}
+			if tt.backup {
+				links["backup"] = newIndex(t, path+blevestore.BackupSuffix, "2")
+			}
+			if tt.reindex {
+				links["reindex"] = newIndex(t, path+blevestore.ReindexSuffix, "3")
+			}
+
+			store := runStore(ctx, t, blevestore.Config{Path: path})
+			assert.Equal(t, []string{links[tt.expected]}, search(t, store, bleve.NewMatchAllQuery()))
+			assertClean(t, path)
+		})
+	}
+}
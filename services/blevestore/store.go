@@ -2,101 +2,245 @@ package blevestore
 
 import (
 	"os"
+	"sync"
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/mapping"
+	"github.com/pkg/errors"
 )
 
 //go:generate mockgen -package mockblevestore -destination mockblevestore/mockblevestore.go  github.com/blevesearch/bleve Index
 
+// ReindexSuffix is appended to the store path to get the path of the index
+// being rebuilt.
+const ReindexSuffix = ".reindex"
+
+// BackupSuffix is appended to the store path to get the path where the
+// previous index is moved while a rebuilt index is swapped in.
+const BackupSuffix = ".backup"
+
+var (
+	// ErrReindexing is returned when an index is created while another one
+	// is being rebuilt.
+	ErrReindexing = errors.New("an index is already being rebuilt")
+
+	// ErrUnknownIndex is returned when replacing the current index with an
+	// index that was not created by the store.
+	ErrUnknownIndex = errors.New("the index was not created by the store")
+)
+
+// Store is the type exposed by the blevestore service.
+// It is an alias of the current index so that the index can be rebuilt side
+// by side and swapped in without search downtime.
+type Store interface {
+	bleve.IndexAlias
+
+	// NewIndex creates an empty index using the current mapping file.
+	NewIndex() (bleve.Index, error)
+
+	// Replace atomically swaps the current index with an index created by
+	// NewIndex and deletes the previous index.
+	Replace(idx bleve.Index) error
+
+	// Discard deletes an index created by NewIndex.
+	Discard(idx bleve.Index) error
+}
+
 type store struct {
-	idx bleve.Index
+	bleve.IndexAlias
+
+	path        string
+	mappingFile string
+
+	mu      sync.Mutex
+	idx     bleve.Index
+	pending bleve.Index
 }
 
-func newStore(path string) (*store, error) {
+func newStore(path, mappingFile string) (*store, error) {
+	m, err := loadMapping(mappingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		if err := recoverIndex(path); err != nil {
+			return nil, err
+		}
+	}
+
 	var idx bleve.Index
-	var err error
 	if path == "" {
 		// If no path provided, use in-mem store.
-		idx, err = bleve.NewMemOnly(buildMapping())
+		idx, err = bleve.NewMemOnly(m)
 	} else if _, e := os.Stat(path); os.IsNotExist(e) {
 		// If the path does not exist, create the index.
-		idx, err = bleve.New(path, buildMapping())
+		idx, err = bleve.New(path, m)
 	} else {
 		// If the path exists, use the existing data.
+		// The existing index keeps the mapping it was created with until it
+		// is rebuilt.
 		idx, err = bleve.Open(path)
+		if err == nil && !hasKeywordMapping(idx.Mapping()) {
+			log.Warnf("The index at %s was created with an outdated mapping: term filters on the action, map ID, process state and metadata fields will not match until it is rebuilt with the reindex command", path)
+		}
 	}
 
 	if err != nil {
 		return nil, err
 	}
-	return &store{idx}, nil
+
+	return &store{
+		IndexAlias:  bleve.NewIndexAlias(idx),
+		path:        path,
+		mappingFile: mappingFile,
+		idx:         idx,
+	}, nil
 }
 
-// buildMapping creates the document mapping for the bleve index.
-// The mapping defines one root object with 4 fields:
-//  - raw: non-indexed, contains the raw link in string.
-//  - data: indexed and saved, dynamic mapping, contains unmarshaled link.data.
-//  - meta: indexed and not saved, static mapping, contains link.meta and
-// 					the unmarshaled link.meta.process
-//  - metadata: index and not saved, static mapping, contains unmashaled link.meta.data.
-func buildMapping() *mapping.IndexMappingImpl {
-	root := bleve.NewDocumentMapping()
+// recoverIndex completes or rolls back a swap interrupted by a crash and
+// removes the leftovers of an interrupted reindex.
+// The previous index is only moved aside once the rebuilt index is
+// complete, so if the store path is missing the rebuilt index is moved in,
+// or the previous index is restored if the rebuilt one is gone too.
+func recoverIndex(path string) error {
+	backup, reindexed := path+BackupSuffix, path+ReindexSuffix
+
+	if !exists(path) && exists(backup) {
+		restored := backup
+		if exists(reindexed) {
+			restored = reindexed
+		}
+
+		log.Warnf("Recovering the index at %s from %s", path, restored)
+		if err := os.Rename(restored, path); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 
-	textFieldNotIndexed := bleve.NewTextFieldMapping()
-	textFieldNotIndexed.Index = false
+	if err := os.RemoveAll(backup); err != nil {
+		return errors.WithStack(err)
+	}
 
-	textFieldNotStored := bleve.NewTextFieldMapping()
-	textFieldNotStored.Store = false
+	return errors.WithStack(os.RemoveAll(reindexed))
+}
 
-	numFieldNotIndexed := bleve.NewNumericFieldMapping()
-	numFieldNotIndexed.Index = false
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
 
-	numFieldNotStored := bleve.NewTextFieldMapping()
-	numFieldNotStored.Store = false
+// loadMapping builds the index mapping from the mapping file.
+// The file is read again on every call so that a rebuilt index picks up the
+// changes.
+func loadMapping(mappingFile string) (*mapping.IndexMappingImpl, error) {
+	if mappingFile == "" {
+		return buildMapping(nil), nil
+	}
 
-	// META
-	meta := bleve.NewDocumentStaticMapping()
-	meta.AddFieldMappingsAt("action", textFieldNotStored)
-	meta.AddFieldMappingsAt("mapId", textFieldNotStored)
-	meta.AddFieldMappingsAt("step", textFieldNotStored)
-	meta.AddFieldMappingsAt("tags", textFieldNotStored)
-	meta.AddFieldMappingsAt("prevLinkHash", textFieldNotStored)
-	meta.AddFieldMappingsAt("prevLinkHash", numFieldNotStored)
-	meta.AddFieldMappingsAt("priority", numFieldNotStored)
+	conf, err := LoadMappingConfig(mappingFile)
+	if err != nil {
+		return nil, err
+	}
 
-	// META.PROCESS
-	process := bleve.NewDocumentStaticMapping()
-	process.AddFieldMappingsAt("name", textFieldNotStored)
-	process.AddFieldMappingsAt("state", textFieldNotStored)
-	meta.AddSubDocumentMapping("process", process)
+	return buildMapping(conf), nil
+}
 
-	root.AddSubDocumentMapping("meta", meta)
+// NewIndex creates an empty index using the current mapping file.
+// On disk, it is created next to the current index.
+func (s *store) NewIndex() (bleve.Index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// METADATA
-	metadata := bleve.NewDocumentStaticMapping()
-	metadata.AddFieldMappingsAt("createdById", textFieldNotStored)
-	metadata.AddFieldMappingsAt("formId", textFieldNotStored)
-	metadata.AddFieldMappingsAt("groupId", textFieldNotStored)
-	metadata.AddFieldMappingsAt("inputs", textFieldNotStored)
-	metadata.AddFieldMappingsAt("ownerId", textFieldNotStored)
+	if s.pending != nil {
+		return nil, ErrReindexing
+	}
 
-	root.AddSubDocumentMapping("metadata", metadata)
+	m, err := loadMapping(s.mappingFile)
+	if err != nil {
+		return nil, err
+	}
 
-	// DATA
-	data := bleve.NewDocumentMapping()
-	root.AddSubDocumentMapping("data", data)
+	var idx bleve.Index
+	if s.path == "" {
+		idx, err = bleve.NewMemOnly(m)
+	} else {
+		// Remove the leftovers of an interrupted reindex.
+		if err := os.RemoveAll(s.path + ReindexSuffix); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		idx, err = bleve.New(s.path+ReindexSuffix, m)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// RAW
-	root.AddFieldMappingsAt("raw", numFieldNotIndexed)
-	root.AddFieldMappingsAt("raw", textFieldNotIndexed)
+	s.pending = idx
+	return idx, nil
+}
+
+// Replace atomically swaps the current index with an index created by
+// NewIndex and deletes the previous index.
+// Searches running on the previous index complete before it is closed.
+func (s *store) Replace(idx bleve.Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if idx == nil || idx != s.pending {
+		return ErrUnknownIndex
+	}
 
-	root.Dynamic = false
+	s.IndexAlias.Swap([]bleve.Index{idx}, []bleve.Index{s.idx})
 
-	mapping := bleve.NewIndexMapping()
-	mapping.TypeField = "type"
+	old := s.idx
+	s.idx = idx
+	s.pending = nil
 
-	mapping.AddDocumentMapping("root", root)
+	if err := old.Close(); err != nil {
+		return err
+	}
+
+	if s.path == "" {
+		return nil
+	}
+
+	// Move the new index to the store path so that it is used on restart.
+	// The open index keeps working since it holds its files open.
+	// The previous index is moved aside before being deleted so that the
+	// store path always holds a complete index, or can be recovered on
+	// restart if the swap is interrupted.
+	backup := s.path + BackupSuffix
+	if err := os.RemoveAll(backup); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(s.path, backup); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(s.path+ReindexSuffix, s.path); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.RemoveAll(backup))
+}
+
+// Discard deletes an index created by NewIndex.
+func (s *store) Discard(idx bleve.Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if idx == nil || idx != s.pending {
+		return ErrUnknownIndex
+	}
+
+	s.pending = nil
+
+	if err := idx.Close(); err != nil {
+		return err
+	}
+
+	if s.path == "" {
+		return nil
+	}
 
-	return mapping
+	return errors.WithStack(os.RemoveAll(s.path + ReindexSuffix))
 }
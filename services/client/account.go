@@ -8,8 +8,7 @@ import (
 	"net/http"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 	"github.com/stratumn/go-crypto/signatures"
 )
 
@@ -20,7 +19,7 @@ type AccountClient interface {
 }
 
 func (c *client) CallAccountGql(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
-	return c.callGqlEndpoint(ctx, c.urlAccount+"/graphql", query, variables, rsp)
+	return c.callGqlEndpoint(ctx, c.accountBreaker, c.urlAccount+"/graphql", query, variables, rsp)
 }
 
 type tokenBody struct {
@@ -31,9 +30,10 @@ type tokenBody struct {
 func (c *client) login(ctx context.Context) (string, error) {
 	log.Info("Login")
 
+	now := time.Now()
 	tb := tokenBody{
-		Iat: time.Now().Unix(),
-		Exp: time.Now().Add(time.Minute * 5).Unix(),
+		Iat: now.Unix(),
+		Exp: now.Add(c.tokenLifetime).Unix(),
 	}
 
 	b, err := json.Marshal(tb)
@@ -56,13 +56,16 @@ func (c *client) login(ctx context.Context) (string, error) {
 		return "", err
 	}
 
+	requestID := uuid.NewV4().String()
 	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", base64.StdEncoding.EncodeToString(token)))
-	r, err := c.httpClient.Do(req)
+	req.Header.Set(requestIDHeader, requestID)
+	r, err := c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return "", err
+		return "", newTransportError(err, requestID)
 	}
+	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return "", errors.Errorf("HTTP error %d", r.StatusCode)
+		return "", newHTTPError(r, requestID)
 	}
 
 	var rsp struct{ Token string }
@@ -74,27 +77,3 @@ func (c *client) login(ctx context.Context) (string, error) {
 
 	return rsp.Token, nil
 }
-
-func (c *client) checkAndRenewToken(ctx context.Context) error {
-	// Check if the token is still valid
-	if c.authToken != "" {
-		p := jwt.Parser{}
-		cl := &jwt.StandardClaims{}
-		_, _, err := p.ParseUnverified(c.authToken, cl)
-		if err != nil {
-			return err
-		}
-
-		if cl.ExpiresAt > time.Now().Unix()+1 {
-			// The token is still valid.
-			return nil
-		}
-	}
-
-	t, err := c.login(ctx)
-	if err != nil {
-		return err
-	}
-	c.authToken = t
-	return nil
-}
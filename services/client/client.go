@@ -5,10 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 	"github.com/stratumn/go-crypto/keys"
 
 	"github.com/stratumn/go-connector/services/decryption"
@@ -26,17 +29,29 @@ type client struct {
 	urlTrace   string
 	urlAccount string
 	httpClient *http.Client
+	transport  *httpTransport
 	decryptor  decryption.Decryptor
 
 	// The PEM encoded signing keys of the conenctor.
 	signingPrivateKey []byte
 	signingPublicKey  []byte
 
-	authToken string
+	// tokens logs in and renews the authentication token.
+	tokens        *tokenManager
+	tokenLifetime time.Duration
+
+	// retry configures the retries of the calls.
+	retry Retry
+	// The circuit breakers of the Trace and Account endpoints.
+	traceBreaker   *breaker
+	accountBreaker *breaker
 }
 
-func newClient(traceURL string, accountURL string, signingPrivateKey []byte, decryptor decryption.Decryptor) (StratumnClient, error) {
-	httpClient := &http.Client{Timeout: time.Second * 10}
+func newClient(traceURL string, accountURL string, signingPrivateKey []byte, decryptor decryption.Decryptor, retry Retry, tokenPolicy TokenPolicy, transport Transport) (StratumnClient, error) {
+	t, err := newHTTPTransport(transport)
+	if err != nil {
+		return nil, err
+	}
 
 	_, pub, err := keys.ParseSecretKey(signingPrivateKey)
 	if err != nil {
@@ -47,53 +62,104 @@ func newClient(traceURL string, accountURL string, signingPrivateKey []byte, dec
 		return nil, err
 	}
 
-	return &client{
+	if retry.MaxBackoff < retry.MinBackoff {
+		retry.MaxBackoff = retry.MinBackoff
+	}
+
+	if err := tokenPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	tokenLifetime := tokenPolicy.Lifetime
+	if tokenLifetime <= 0 {
+		tokenLifetime = time.Millisecond * DefaultTokenLifetime
+	}
+
+	c := &client{
 		urlTrace:          traceURL,
 		urlAccount:        accountURL,
-		httpClient:        httpClient,
+		httpClient:        t.client(),
+		transport:         t,
 		decryptor:         decryptor,
 		signingPrivateKey: signingPrivateKey,
 		signingPublicKey:  signingPublicKey,
-	}, nil
-}
+		retry:             retry,
+		traceBreaker:      newBreaker(traceEndpoint, retry),
+		accountBreaker:    newBreaker(accountEndpoint, retry),
+		tokenLifetime:     tokenLifetime,
+	}
+	c.tokens = newTokenManager(c.login, tokenPolicy)
 
-type gqlError struct {
-	Message string
-	Status  int
+	return c, nil
 }
 
 type gqlResponse struct {
 	Data   interface{}
-	Errors []gqlError
+	Errors []GraphQLError
 }
 
 // Helper that calls the graphql endpoint and renews the token when necessary.
-func (c *client) callGqlEndpoint(ctx context.Context, url string, query string, variables map[string]interface{}, rsp interface{}) error {
-	if err := c.checkAndRenewToken(ctx); err != nil {
-		return err
-	}
-
+// Queries are sent again after network errors and 5xx or 429 responses,
+// mutations are sent once. A call whose token is rejected is sent once more
+// after logging in again.
+func (c *client) callGqlEndpoint(ctx context.Context, b *breaker, url string, query string, variables map[string]interface{}, rsp interface{}) error {
 	body := map[string]interface{}{
 		"query":     query,
 		"variables": variables,
 	}
 
-	b, err := json.Marshal(body)
+	payload, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(b))
+	send := func() (string, error) {
+		token, err := c.tokens.get(ctx)
+		if err != nil {
+			return "", err
+		}
+		return token, c.withRetries(ctx, b, isIdempotent(query), func() error {
+			return c.postGql(ctx, url, token, payload, rsp)
+		})
+	}
+
+	token, err := send()
+	if clientErr, ok := err.(*Error); ok && clientErr.Status == http.StatusUnauthorized {
+		// the API rejected the request before processing it, so even a
+		// mutation can be sent again.
+		log.Warnf("The API rejected the authentication token, logging in again")
+		c.tokens.invalidate(token)
+		_, err = send()
+	}
+	return err
+}
+
+// postGql sends a request to a graphql endpoint.
+// It returns an attemptError when the request may succeed if sent again.
+// The API errors are returned as an *Error.
+func (c *client) postGql(ctx context.Context, url, token string, payload []byte, rsp interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 
+	requestID := uuid.NewV4().String()
 	req.Header.Set("content-type", "application/json")
-	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set(requestIDHeader, requestID)
 
 	r, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return &attemptError{err: newTransportError(err, requestID)}
+	}
+	defer func() {
+		// drain the body so that the connection is reused.
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	if aerr := retryable(r, requestID); aerr != nil {
+		return aerr
 	}
 
 	gqlRsp := gqlResponse{
@@ -102,12 +168,14 @@ func (c *client) callGqlEndpoint(ctx context.Context, url string, query string,
 
 	err = json.NewDecoder(r.Body).Decode(&gqlRsp)
 	if err != nil {
-		return err
+		if r.StatusCode != http.StatusOK {
+			return newHTTPError(r, requestID)
+		}
+		return errors.WithStack(err)
 	}
 
 	if len(gqlRsp.Errors) > 0 {
-		// return the first error
-		return errors.Errorf("graphql (%d): %s", gqlRsp.Errors[0].Status, gqlRsp.Errors[0].Message)
+		return newGraphQLError(r, requestID, gqlRsp.Errors)
 	}
 
 	return nil
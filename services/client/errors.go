@@ -0,0 +1,175 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// requestIDHeader is the header carrying the ID of a request, sent to the
+// APIs and returned in their responses.
+const requestIDHeader = "X-Request-Id"
+
+// Kinds of the errors returned by the calls to the Stratumn APIs.
+// The Error returned by a call has one of them as its cause, so they can be
+// compared with errors.Cause.
+var (
+	// ErrUnauthorized is returned when the API rejects the credentials of the
+	// connector (401 and 403 statuses).
+	ErrUnauthorized = errors.New("the API rejected the credentials of the connector")
+
+	// ErrNotFound is returned when the requested resource does not exist
+	// (404 status).
+	ErrNotFound = errors.New("the requested resource was not found")
+
+	// ErrValidation is returned when the API rejects the request (other 4xx
+	// statuses).
+	ErrValidation = errors.New("the API rejected the request")
+
+	// ErrRateLimited is returned when too many requests were sent to the API
+	// (429 status).
+	ErrRateLimited = errors.New("too many requests were sent to the API")
+
+	// ErrTransport is returned when the API could not be reached or failed to
+	// answer (network errors and 5xx statuses).
+	ErrTransport = errors.New("the API could not be reached")
+
+	// ErrAPI is returned when the API answered with errors that do not tell
+	// whether the request was rejected (GraphQL errors without status).
+	ErrAPI = errors.New("the API returned an error")
+)
+
+// GraphQLError is an error returned by a GraphQL endpoint.
+type GraphQLError struct {
+	Message string `json:"message"`
+	Status  int    `json:"status,omitempty"`
+
+	// Path is the path of the field of the response that failed, made of
+	// field names and list indices.
+	Path []interface{} `json:"path,omitempty"`
+}
+
+func (e GraphQLError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s (at %s)", e.Message, strings.Join(path, "."))
+}
+
+// Error is returned when a call to a Stratumn API fails.
+type Error struct {
+	// Kind is one of ErrUnauthorized, ErrNotFound, ErrValidation,
+	// ErrRateLimited, ErrTransport or ErrAPI.
+	Kind error
+
+	// Status is the HTTP status of the response, or the status of the first
+	// GraphQL error. It is zero when the API could not be reached.
+	Status int
+
+	// RequestID identifies the failed request in the logs of the API.
+	RequestID string
+
+	// Errors are all the errors of a GraphQL response.
+	Errors []GraphQLError
+
+	// Err is the network error when the API could not be reached.
+	Err error
+}
+
+func (e *Error) Error() string {
+	var msg string
+	switch {
+	case len(e.Errors) > 0:
+		errs := make([]string, len(e.Errors))
+		for i, gqlErr := range e.Errors {
+			errs[i] = gqlErr.String()
+		}
+		msg = fmt.Sprintf("graphql (%d): %s", e.Status, strings.Join(errs, "; "))
+	case e.Status != 0:
+		msg = fmt.Sprintf("HTTP error %d", e.Status)
+	case e.Err != nil:
+		msg = fmt.Sprintf("%s: %s", e.Kind, e.Err)
+	default:
+		msg = e.Kind.Error()
+	}
+
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%s (request %s)", msg, e.RequestID)
+	}
+	return msg
+}
+
+// Cause returns the kind of the error.
+func (e *Error) Cause() error {
+	return e.Kind
+}
+
+// kindOf returns the kind of the errors with an HTTP status, or of the
+// GraphQL errors without status when it is zero.
+func kindOf(status int) error {
+	switch {
+	case status == 0:
+		return ErrAPI
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= http.StatusInternalServerError:
+		return ErrTransport
+	default:
+		return ErrValidation
+	}
+}
+
+// newHTTPError returns the error of a failed response.
+func newHTTPError(r *http.Response, requestID string) *Error {
+	return &Error{
+		Kind:      kindOf(r.StatusCode),
+		Status:    r.StatusCode,
+		RequestID: responseRequestID(r, requestID),
+	}
+}
+
+// newGraphQLError returns the error of a GraphQL response with errors.
+// The status of the first error gives the kind of the error, unless the
+// response itself failed.
+func newGraphQLError(r *http.Response, requestID string, gqlErrs []GraphQLError) *Error {
+	status := r.StatusCode
+	if status == http.StatusOK {
+		status = gqlErrs[0].Status
+	}
+
+	return &Error{
+		Kind:      kindOf(status),
+		Status:    status,
+		RequestID: responseRequestID(r, requestID),
+		Errors:    gqlErrs,
+	}
+}
+
+// newTransportError returns the error of a request that got no response.
+func newTransportError(err error, requestID string) *Error {
+	return &Error{
+		Kind:      ErrTransport,
+		RequestID: requestID,
+		Err:       err,
+	}
+}
+
+// responseRequestID returns the request ID set by the API, or the one
+// sent with the request.
+func responseRequestID(r *http.Response, requestID string) string {
+	if id := r.Header.Get(requestIDHeader); id != "" {
+		return id
+	}
+	return requestID
+}
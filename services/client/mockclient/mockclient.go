@@ -6,6 +6,7 @@ package mockclient
 
 import (
 	context "context"
+	json "encoding/json"
 	gomock "github.com/golang/mock/gomock"
 	go_chainscript "github.com/stratumn/go-chainscript"
 	chainscript "github.com/stratumn/go-connector/lib/chainscript"
@@ -60,6 +61,18 @@ func (mr *MockStratumnClientMockRecorder) CallTraceGql(arg0, arg1, arg2, arg3 in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CallTraceGql", reflect.TypeOf((*MockStratumnClient)(nil).CallTraceGql), arg0, arg1, arg2, arg3)
 }
 
+// CallTraceGqlRaw mocks base method
+func (m *MockStratumnClient) CallTraceGqlRaw(arg0 context.Context, arg1 string, arg2 map[string]interface{}, arg3 interface{}) error {
+	ret := m.ctrl.Call(m, "CallTraceGqlRaw", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CallTraceGqlRaw indicates an expected call of CallTraceGqlRaw
+func (mr *MockStratumnClientMockRecorder) CallTraceGqlRaw(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CallTraceGqlRaw", reflect.TypeOf((*MockStratumnClient)(nil).CallTraceGqlRaw), arg0, arg1, arg2, arg3)
+}
+
 // CreateLink mocks base method
 func (m *MockStratumnClient) CreateLink(arg0 context.Context, arg1 *go_chainscript.Link) (*client.CreateLinkPayload, error) {
 	ret := m.ctrl.Call(m, "CreateLink", arg0, arg1)
@@ -86,6 +99,16 @@ func (mr *MockStratumnClientMockRecorder) CreateLinks(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLinks", reflect.TypeOf((*MockStratumnClient)(nil).CreateLinks), arg0, arg1)
 }
 
+// DecryptLinks mocks base method
+func (m *MockStratumnClient) DecryptLinks(arg0 context.Context, arg1 interface{}) {
+	m.ctrl.Call(m, "DecryptLinks", arg0, arg1)
+}
+
+// DecryptLinks indicates an expected call of DecryptLinks
+func (mr *MockStratumnClientMockRecorder) DecryptLinks(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecryptLinks", reflect.TypeOf((*MockStratumnClient)(nil).DecryptLinks), arg0, arg1)
+}
+
 // GetRecipientsPublicKeys mocks base method
 func (m *MockStratumnClient) GetRecipientsPublicKeys(arg0 context.Context, arg1 string) ([]*chainscript.PublicKeyInfo, error) {
 	ret := m.ctrl.Call(m, "GetRecipientsPublicKeys", arg0, arg1)
@@ -110,3 +133,16 @@ func (m *MockStratumnClient) SignLink(arg0 *go_chainscript.Link) error {
 func (mr *MockStratumnClientMockRecorder) SignLink(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignLink", reflect.TypeOf((*MockStratumnClient)(nil).SignLink), arg0)
 }
+
+// SubscribeTraceGql mocks base method
+func (m *MockStratumnClient) SubscribeTraceGql(arg0 context.Context, arg1 string, arg2 map[string]interface{}) (<-chan json.RawMessage, error) {
+	ret := m.ctrl.Call(m, "SubscribeTraceGql", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeTraceGql indicates an expected call of SubscribeTraceGql
+func (mr *MockStratumnClientMockRecorder) SubscribeTraceGql(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeTraceGql", reflect.TypeOf((*MockStratumnClient)(nil).SubscribeTraceGql), arg0, arg1, arg2)
+}
@@ -0,0 +1,431 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of times an idempotent query is sent.
+	DefaultMaxAttempts = 4
+
+	// DefaultMinBackoff is the default delay before the first retry (in milliseconds).
+	DefaultMinBackoff = 200
+
+	// DefaultMaxBackoff is the default maximum delay between two attempts (in milliseconds).
+	DefaultMaxBackoff = 10000
+
+	// DefaultBreakerThreshold is the default number of consecutive failures opening the circuit of an endpoint.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is the default time the circuit of an endpoint stays open (in milliseconds).
+	DefaultBreakerCooldown = 30000
+)
+
+// Endpoints of the Stratumn APIs, each with its own circuit breaker.
+const (
+	traceEndpoint   = "trace"
+	accountEndpoint = "account"
+)
+
+// Outcomes of an attempt.
+const (
+	attemptSuccess = "success"
+	attemptRetry   = "retry"
+	attemptFailure = "failure"
+	attemptOpen    = "circuit-open"
+)
+
+var (
+	// ErrCircuitOpen is returned without calling an API that failed too many
+	// times in a row, until its cooldown elapses.
+	ErrCircuitOpen = errors.New("the API is unavailable, the circuit breaker is open")
+)
+
+// Retry configures the retries of the calls to the Stratumn APIs and the
+// circuit breakers of the endpoints.
+type Retry struct {
+	// MaxAttempts is the number of times an idempotent query is sent.
+	// Mutations are sent once.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. It doubles after each
+	// attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// BreakerThreshold is the number of consecutive failures opening the
+	// circuit of an endpoint. The breaker is disabled when it is zero.
+	BreakerThreshold int
+
+	// BreakerCooldown is the time the circuit stays open before a single
+	// call is let through to probe the endpoint.
+	BreakerCooldown time.Duration
+}
+
+// backoff returns the delay before the next attempt after a failed one.
+// A random jitter spreads the retries of the calls failing together.
+func (r Retry) backoff(attempt int) time.Duration {
+	delay := r.MaxBackoff
+	if attempt < 32 {
+		if d := r.MinBackoff << uint(attempt-1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// isIdempotent returns whether a GraphQL document can be sent again
+// without side effects. It must contain a single operation, which is a
+// query. Fragments are allowed next to it. Mutations, subscriptions,
+// documents with several operations and documents that cannot be read
+// are sent once.
+func isIdempotent(document string) bool {
+	tokens, ok := scanDefinitions(document)
+	if !ok {
+		return false
+	}
+
+	operations := 0
+	for _, t := range tokens {
+		switch t {
+		case "{", "query":
+			operations++
+		case "fragment":
+		default:
+			// mutation, subscription or an unknown definition.
+			return false
+		}
+	}
+
+	return operations == 1
+}
+
+// scanDefinitions returns the tokens starting the top-level definitions of
+// a GraphQL document: either a keyword or "{" for a shorthand query.
+// Comments, strings and everything nested in brackets are skipped.
+// It returns false when the document is malformed.
+func scanDefinitions(document string) ([]string, bool) {
+	var tokens []string
+	depth := 0
+	expectDefinition := true
+
+	for i := 0; i < len(document); {
+		c := document[i]
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(document[i:], `"""`):
+			i += 3
+			for !strings.HasPrefix(document[i:], `"""`) {
+				if i >= len(document) {
+					return nil, false
+				}
+				if strings.HasPrefix(document[i:], `\"""`) {
+					i += 3
+				}
+				i++
+			}
+			i += 3
+		case c == '"':
+			i++
+			for i < len(document) && document[i] != '"' {
+				if document[i] == '\n' || document[i] == '\r' {
+					return nil, false
+				}
+				if document[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(document) {
+				return nil, false
+			}
+			i++
+		case c == '{' || c == '(' || c == '[':
+			if c == '{' && depth == 0 && expectDefinition {
+				tokens = append(tokens, "{")
+				expectDefinition = false
+			}
+			depth++
+			i++
+		case c == '}' || c == ')' || c == ']':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+			if c == '}' && depth == 0 {
+				expectDefinition = true
+			}
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(document) && isNameChar(document[i]) {
+				i++
+			}
+			if depth == 0 && expectDefinition {
+				tokens = append(tokens, document[start:i])
+				expectDefinition = false
+			}
+		default:
+			i++
+		}
+	}
+
+	return tokens, depth == 0 && expectDefinition
+}
+
+// isNameChar returns whether a character can be part of a GraphQL name.
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// attemptError is returned by a failed attempt that may succeed if retried.
+type attemptError struct {
+	err *Error
+
+	// retryAfter is the delay requested by the API.
+	retryAfter time.Duration
+}
+
+func (e *attemptError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the underlying error.
+func (e *attemptError) Cause() error {
+	return e.err
+}
+
+// retryable returns the error of a response that may succeed if sent
+// again, or nil.
+func retryable(r *http.Response, requestID string) *attemptError {
+	if r.StatusCode != http.StatusTooManyRequests && r.StatusCode < http.StatusInternalServerError {
+		return nil
+	}
+
+	return &attemptError{
+		err:        newHTTPError(r, requestID),
+		retryAfter: parseRetryAfter(r.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, given either in seconds
+// or as an HTTP date. It returns zero when the header is missing or
+// malformed.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// withRetries sends a call until it succeeds, fails with an error that is
+// not an attemptError or runs out of attempts.
+// The calls are not sent while the circuit of the endpoint is open.
+func (c *client) withRetries(ctx context.Context, b *breaker, idempotent bool, call func() error) error {
+	maxAttempts := 1
+	if idempotent && c.retry.MaxAttempts > 1 {
+		maxAttempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := b.allow(); err != nil {
+			record(b.endpoint, attemptOpen, 0)
+			return err
+		}
+
+		start := time.Now()
+		err := call()
+		latency := time.Since(start)
+
+		aerr, ok := err.(*attemptError)
+		switch {
+		case err == nil:
+			b.success()
+			record(b.endpoint, attemptSuccess, latency)
+			log.WithField("endpoint", b.endpoint).Debugf("Attempt %d succeeded in %s", attempt, latency)
+			return nil
+		case !ok:
+			// the API answered, so it is available.
+			b.success()
+			record(b.endpoint, attemptFailure, latency)
+			return err
+		case ctx.Err() != nil:
+			b.release()
+			record(b.endpoint, attemptFailure, latency)
+			return errors.WithStack(ctx.Err())
+		}
+
+		b.failure()
+
+		delay := c.retry.backoff(attempt)
+		if aerr.retryAfter > delay {
+			delay = aerr.retryAfter
+		}
+		if attempt >= maxAttempts || delay > c.retry.MaxBackoff {
+			record(b.endpoint, attemptFailure, latency)
+			log.WithField("endpoint", b.endpoint).Warnf("Attempt %d failed, giving up: %s", attempt, aerr)
+			return aerr.err
+		}
+
+		record(b.endpoint, attemptRetry, latency)
+		log.WithField("endpoint", b.endpoint).Warnf("Attempt %d failed, retrying in %s: %s", attempt, delay, aerr)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+// breaker stops calling an endpoint after consecutive failures.
+// Once the cooldown elapses, a single call probes the endpoint: its success
+// closes the circuit and its failure opens it again.
+type breaker struct {
+	endpoint  string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(endpoint string, retry Retry) *breaker {
+	return &breaker{
+		endpoint:  endpoint,
+		threshold: retry.BreakerThreshold,
+		cooldown:  retry.BreakerCooldown,
+	}
+}
+
+// open returns whether the circuit is open.
+// It must be called with the lock held.
+func (b *breaker) open() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// allow returns ErrCircuitOpen when the endpoint must not be called.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open() {
+		return nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return errors.Wrap(ErrCircuitOpen, b.endpoint)
+	}
+
+	b.probing = true
+	return nil
+}
+
+// success closes the circuit.
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.open() {
+		log.WithField("endpoint", b.endpoint).Infof("The API is available again, closing the circuit")
+	}
+	b.failures = 0
+	b.probing = false
+}
+
+// failure counts a failed call and opens the circuit after too many.
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.open() {
+		if b.failures == b.threshold {
+			log.WithField("endpoint", b.endpoint).Errorf("The API failed %d times in a row, opening the circuit for %s", b.failures, b.cooldown)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a probe that neither succeeded nor failed.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+var (
+	endpointKey, _ = tag.NewKey("endpoint")
+	outcomeKey, _  = tag.NewKey("outcome")
+
+	attempts = stats.Int64(
+		"stratumn-connector/client/attempts",
+		"number of calls sent to the Stratumn APIs",
+		stats.UnitDimensionless,
+	)
+
+	attemptLatency = stats.Float64(
+		"stratumn-connector/client/attempt-latency",
+		"latency of the calls sent to the Stratumn APIs",
+		stats.UnitMilliseconds,
+	)
+
+	// Views of the client metrics, registered when the service runs.
+	Views = []*view.View{
+		{
+			Name:        "stratumn-connector/views/client/attempts",
+			Description: "number of calls sent to the Stratumn APIs",
+			Measure:     attempts,
+			TagKeys:     []tag.Key{endpointKey, outcomeKey},
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "stratumn-connector/views/client/attempt-latency",
+			Description: "latency of the calls sent to the Stratumn APIs",
+			Measure:     attemptLatency,
+			TagKeys:     []tag.Key{endpointKey, outcomeKey},
+			Aggregation: view.Distribution(10, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+		},
+	}
+)
+
+// record records the metrics of an attempt.
+func record(endpoint, outcome string, latency time.Duration) {
+	ctx, err := tag.New(context.Background(), tag.Insert(endpointKey, endpoint), tag.Insert(outcomeKey, outcome))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, attempts.M(1), attemptLatency.M(float64(latency)/float64(time.Millisecond)))
+}
@@ -2,10 +2,12 @@ package client
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stratumn/go-node/core/cfg"
+	"go.opencensus.io/stats/view"
 
 	"github.com/stratumn/go-connector/services/decryption"
 )
@@ -40,6 +42,48 @@ type Config struct {
 
 	// ConfigVersion is the version of the configuration file.
 	ConfigVersion int `toml:"configuration_version" comment:"The version of the service configuration."`
+
+	// MaxAttempts is the number of times a query is sent.
+	MaxAttempts int `toml:"max_attempts" comment:"The number of times a query is sent when the API is unreachable or returns a 5xx or 429 status. Mutations are sent once."`
+
+	// MinBackoff and MaxBackoff bound the delay between two attempts.
+	MinBackoff time.Duration `toml:"min_backoff" comment:"The delay (in milliseconds) before the first retry, doubled after each attempt."`
+	MaxBackoff time.Duration `toml:"max_backoff" comment:"The maximum delay (in milliseconds) between two attempts. A query is not retried when the API asks to wait longer."`
+
+	// BreakerThreshold is the number of consecutive failures opening the circuit of an API.
+	BreakerThreshold int `toml:"breaker_threshold" comment:"The number of consecutive failed calls after which calls to an API fail fast. Set to 0 to disable the circuit breaker."`
+
+	// BreakerCooldown is the time calls to an API fail fast.
+	BreakerCooldown time.Duration `toml:"breaker_cooldown" comment:"The time (in milliseconds) calls to an API fail fast before the API is called again."`
+
+	// TokenLifetime is the lifetime requested for the authentication tokens.
+	TokenLifetime time.Duration `toml:"token_lifetime" comment:"The lifetime (in milliseconds) requested for the authentication tokens."`
+
+	// TokenRefreshRatio is the fraction of the lifetime after which a token is renewed.
+	TokenRefreshRatio float64 `toml:"token_refresh_ratio" comment:"The fraction of the lifetime of a token after which it is renewed in the background, between 0 and 1."`
+
+	// ProxyURL is the URL of the HTTP(S) proxy the APIs are called through.
+	ProxyURL string `toml:"proxy_url" comment:"The URL of the HTTP(S) proxy the APIs are called through. Leave empty to use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables."`
+
+	// CAFile is the file of the additional certificate authorities.
+	CAFile string `toml:"ca_file" comment:"A PEM bundle of certificate authorities trusted in addition to the ones of the system. Leave empty to trust only the system ones."`
+
+	// ClientCertFile and ClientKeyFile authenticate the connector with mutual TLS.
+	ClientCertFile string `toml:"client_cert_file" comment:"The PEM encoded certificate presented to the APIs for mutual TLS. Leave empty to disable mutual TLS."`
+	ClientKeyFile  string `toml:"client_key_file" comment:"The PEM encoded private key of the client certificate."`
+
+	// ConnectTimeout, ReadTimeout and Timeout bound the requests.
+	ConnectTimeout time.Duration `toml:"connect_timeout" comment:"The time (in milliseconds) given to establish a connection, TLS handshake included."`
+	ReadTimeout    time.Duration `toml:"read_timeout" comment:"The time (in milliseconds) given to the APIs to answer a request once it is sent."`
+	Timeout        time.Duration `toml:"timeout" comment:"The time (in milliseconds) given to a request, from the connection to the end of the response."`
+
+	// MaxIdleConns, MaxIdleConnsPerHost and MaxConnsPerHost size the connection pool.
+	MaxIdleConns        int `toml:"max_idle_conns" comment:"The maximum number of idle connections kept open."`
+	MaxIdleConnsPerHost int `toml:"max_idle_conns_per_host" comment:"The maximum number of idle connections kept open to each API."`
+	MaxConnsPerHost     int `toml:"max_conns_per_host" comment:"The maximum number of connections to each API. Set to 0 for no limit."`
+
+	// Headers are added to every request.
+	Headers []string `toml:"headers" comment:"The headers added to every request, formatted as \"Name: value\"."`
 }
 
 // ID returns the unique identifier of the service.
@@ -65,9 +109,21 @@ func (s *Service) Config() interface{} {
 	}
 
 	return Config{
-		TraceURL:   "https://trace-api.stratumn.com",
-		AccountURL: "https://account-api.stratumn.com",
-		Decryption: "decryption",
+		TraceURL:            "https://trace-api.stratumn.com",
+		AccountURL:          "https://account-api.stratumn.com",
+		Decryption:          "decryption",
+		MaxAttempts:         DefaultMaxAttempts,
+		MinBackoff:          DefaultMinBackoff,
+		MaxBackoff:          DefaultMaxBackoff,
+		BreakerThreshold:    DefaultBreakerThreshold,
+		BreakerCooldown:     DefaultBreakerCooldown,
+		TokenLifetime:       DefaultTokenLifetime,
+		TokenRefreshRatio:   DefaultTokenRefreshRatio,
+		ConnectTimeout:      DefaultConnectTimeout,
+		ReadTimeout:         DefaultReadTimeout,
+		Timeout:             DefaultTimeout,
+		MaxIdleConns:        DefaultMaxIdleConns,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
 	}
 }
 
@@ -113,12 +169,44 @@ func (s *Service) Expose() interface{} {
 
 // Run starts the service.
 func (s *Service) Run(ctx context.Context, running, stopping func()) error {
+	retry := Retry{
+		MaxAttempts:      s.config.MaxAttempts,
+		MinBackoff:       time.Millisecond * s.config.MinBackoff,
+		MaxBackoff:       time.Millisecond * s.config.MaxBackoff,
+		BreakerThreshold: s.config.BreakerThreshold,
+		BreakerCooldown:  time.Millisecond * s.config.BreakerCooldown,
+	}
+
+	tokenPolicy := TokenPolicy{
+		Lifetime:     time.Millisecond * s.config.TokenLifetime,
+		RefreshRatio: s.config.TokenRefreshRatio,
+	}
+
+	transport := Transport{
+		ProxyURL:            s.config.ProxyURL,
+		CAFile:              s.config.CAFile,
+		CertFile:            s.config.ClientCertFile,
+		KeyFile:             s.config.ClientKeyFile,
+		ConnectTimeout:      time.Millisecond * s.config.ConnectTimeout,
+		ReadTimeout:         time.Millisecond * s.config.ReadTimeout,
+		Timeout:             time.Millisecond * s.config.Timeout,
+		MaxIdleConns:        s.config.MaxIdleConns,
+		MaxIdleConnsPerHost: s.config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     s.config.MaxConnsPerHost,
+		Headers:             s.config.Headers,
+	}
+
 	var err error
-	s.client, err = newClient(s.config.TraceURL, s.config.AccountURL, []byte(s.config.SigningPrivateKey), s.decryptor)
+	s.client, err = newClient(s.config.TraceURL, s.config.AccountURL, []byte(s.config.SigningPrivateKey), s.decryptor, retry, tokenPolicy, transport)
 	if err != nil {
 		return err
 	}
 
+	if err := view.Register(Views...); err != nil {
+		return errors.WithStack(err)
+	}
+	defer view.Unregister(Views...)
+
 	running()
 	<-ctx.Done()
 	stopping()
@@ -151,5 +239,59 @@ func (s *Service) Migrations() []cfg.MigrateHandler {
 			}
 			return tree.Set("decryption", "decryption")
 		},
+		func(tree *cfg.Tree) error {
+			if err := tree.Set("max_attempts", DefaultMaxAttempts); err != nil {
+				return err
+			}
+			if err := tree.Set("min_backoff", DefaultMinBackoff); err != nil {
+				return err
+			}
+			if err := tree.Set("max_backoff", DefaultMaxBackoff); err != nil {
+				return err
+			}
+			if err := tree.Set("breaker_threshold", DefaultBreakerThreshold); err != nil {
+				return err
+			}
+			return tree.Set("breaker_cooldown", DefaultBreakerCooldown)
+		},
+		func(tree *cfg.Tree) error {
+			if err := tree.Set("token_lifetime", DefaultTokenLifetime); err != nil {
+				return err
+			}
+			return tree.Set("token_refresh_ratio", DefaultTokenRefreshRatio)
+		},
+		func(tree *cfg.Tree) error {
+			if err := tree.Set("proxy_url", ""); err != nil {
+				return err
+			}
+			if err := tree.Set("ca_file", ""); err != nil {
+				return err
+			}
+			if err := tree.Set("client_cert_file", ""); err != nil {
+				return err
+			}
+			if err := tree.Set("client_key_file", ""); err != nil {
+				return err
+			}
+			if err := tree.Set("connect_timeout", DefaultConnectTimeout); err != nil {
+				return err
+			}
+			if err := tree.Set("read_timeout", DefaultReadTimeout); err != nil {
+				return err
+			}
+			if err := tree.Set("timeout", DefaultTimeout); err != nil {
+				return err
+			}
+			if err := tree.Set("max_idle_conns", DefaultMaxIdleConns); err != nil {
+				return err
+			}
+			if err := tree.Set("max_idle_conns_per_host", DefaultMaxIdleConnsPerHost); err != nil {
+				return err
+			}
+			if err := tree.Set("max_conns_per_host", 0); err != nil {
+				return err
+			}
+			return tree.Set("headers", []string{})
+		},
 	}
 }
@@ -2,12 +2,24 @@ package client_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,6 +31,8 @@ import (
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
 	chainscript "github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-crypto/keys"
 	"github.com/stratumn/go-crypto/signatures"
@@ -165,6 +179,79 @@ func TestClientService_TraceClient(t *testing.T) {
 		assert.Equal(t, "42", rsp.CreateLinks.Links[0].TraceID)
 	})
 
+	t.Run("SubscribeTraceGql", func(t *testing.T) {
+		traceServer := createMockWebsocketServer(t, token, []string{
+			`{"data": {"value": "42"}}`,
+			`{"errors": [{"message": "oops", "status": 500}]}`,
+			`{"data": {"value": "43"}}`,
+		})
+		accountServer := createMockServer(t, token, 1, nil, "")
+
+		defer traceServer.Close()
+		defer accountServer.Close()
+
+		config := client.Config{
+			TraceURL:          traceServer.URL,
+			AccountURL:        accountServer.URL,
+			SigningPrivateKey: key,
+		}
+
+		s := &client.Service{}
+		s.SetConfig(config)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		c := s.Expose().(client.StratumnClient)
+
+		events, err := c.SubscribeTraceGql(ctx, q, v)
+		require.NoError(t, err)
+
+		// the errors are skipped and the channel is closed when the
+		// subscription completes.
+		var values []string
+		for data := range events {
+			var rsp testRsp
+			require.NoError(t, json.Unmarshal(data, &rsp))
+			values = append(values, rsp.Value)
+		}
+		assert.Equal(t, []string{"42", "43"}, values)
+	})
+
+	t.Run("SubscribeTraceGql rejected", func(t *testing.T) {
+		traceServer := createMockWebsocketServer(t, "other", nil)
+		accountServer := createMockServer(t, token, 1, nil, "")
+
+		defer traceServer.Close()
+		defer accountServer.Close()
+
+		config := client.Config{
+			TraceURL:          traceServer.URL,
+			AccountURL:        accountServer.URL,
+			SigningPrivateKey: key,
+		}
+
+		s := &client.Service{}
+		s.SetConfig(config)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		c := s.Expose().(client.StratumnClient)
+
+		_, err := c.SubscribeTraceGql(ctx, q, v)
+		assert.Equal(t, client.ErrSubscriptionRejected, errors.Cause(err))
+	})
 }
 
 func TestClientService_AccountClient(t *testing.T) {
@@ -249,6 +336,583 @@ func TestClientService_TokenExpired(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestClientService_Retry(t *testing.T) {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
+		ExpiresAt: time.Now().Unix() + 1000,
+		IssuedAt:  time.Now().Unix() - 1000,
+	}).SignedString([]byte("plap"))
+
+	// run starts a client calling a Trace API that answers with the given
+	// statuses, then succeeds. It returns a function counting the calls
+	// received and a function stopping the client.
+	run := func(t *testing.T, config client.Config, retryAfter string, statuses ...int) (client.StratumnClient, func() int, func()) {
+		var mu sync.Mutex
+		calls := 0
+
+		traceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls++
+			if calls <= len(statuses) {
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				w.WriteHeader(statuses[calls-1])
+				return
+			}
+			fmt.Fprintln(w, `{"data": {"value": "42"}}`)
+		}))
+		accountServer := createMockServer(t, token, 1, nil, "")
+
+		config.TraceURL = traceServer.URL
+		config.AccountURL = accountServer.URL
+		config.SigningPrivateKey = key
+
+		s := &client.Service{}
+		s.SetConfig(config)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		countCalls := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return calls
+		}
+		stop := func() {
+			cancel()
+			traceServer.Close()
+			accountServer.Close()
+		}
+		return s.Expose().(client.StratumnClient), countCalls, stop
+	}
+
+	ctx := context.Background()
+
+	t.Run("Retries queries after server errors", func(t *testing.T) {
+		c, calls, stop := run(t, client.Config{MaxAttempts: 4, MinBackoff: 1, MaxBackoff: 5}, "", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		defer stop()
+
+		var rsp testRsp
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, "42", rsp.Value)
+		assert.Equal(t, 3, calls())
+	})
+
+	t.Run("Gives up after the last attempt", func(t *testing.T) {
+		c, calls, stop := run(t, client.Config{MaxAttempts: 2, MinBackoff: 1, MaxBackoff: 5}, "", http.StatusBadGateway, http.StatusBadGateway)
+		defer stop()
+
+		var rsp testRsp
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		assert.Equal(t, client.ErrTransport, errors.Cause(err))
+		assert.Equal(t, http.StatusBadGateway, err.(*client.Error).Status)
+		assert.Equal(t, 2, calls())
+	})
+
+	t.Run("Gives up when the API asks to wait too long", func(t *testing.T) {
+		c, calls, stop := run(t, client.Config{MaxAttempts: 4, MinBackoff: 1, MaxBackoff: 5}, "3600", http.StatusTooManyRequests)
+		defer stop()
+
+		var rsp testRsp
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		assert.Equal(t, client.ErrRateLimited, errors.Cause(err))
+		assert.Equal(t, 1, calls())
+	})
+
+	t.Run("Does not retry mutations", func(t *testing.T) {
+		c, calls, stop := run(t, client.Config{MaxAttempts: 4, MinBackoff: 1, MaxBackoff: 5}, "", http.StatusServiceUnavailable)
+		defer stop()
+
+		link, _ := chainscript.NewLinkBuilder("one", "two").Build()
+		_, err := c.CreateLink(ctx, link)
+		assert.Equal(t, client.ErrTransport, errors.Cause(err))
+		assert.Equal(t, 1, calls())
+	})
+
+	t.Run("Reads the operation of the document", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			document string
+			attempts int
+		}{
+			{"shorthand query", `{ value }`, 2},
+			{"named query with a fragment", `fragment F on Value { value } query Q($id: String = "mutation") { ...F }`, 2},
+			{"mutation after a comment", "# reads a value\nmutation { value }", 1},
+			{"mutation after a fragment", `fragment F on Value { value } mutation { ...F }`, 1},
+			{"subscription", `subscription { value }`, 1},
+			{"several operations", `query A { value } query B { value }`, 1},
+			{"query and mutation", `query A { value } mutation B { value }`, 1},
+			{"fragment only", `fragment F on Value { value }`, 1},
+			{"unterminated string", `query { value(id: "1) }`, 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, calls, stop := run(t, client.Config{MaxAttempts: 2, MinBackoff: 1, MaxBackoff: 5}, "", http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+				defer stop()
+
+				var rsp testRsp
+				assert.Error(t, c.CallTraceGql(ctx, tt.document, v, &rsp))
+				assert.Equal(t, tt.attempts, calls())
+			})
+		}
+	})
+
+	t.Run("Fails fast while the circuit is open", func(t *testing.T) {
+		c, calls, stop := run(t, client.Config{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: 50}, "", http.StatusInternalServerError, http.StatusInternalServerError)
+		defer stop()
+
+		var rsp testRsp
+		assert.Error(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Error(t, c.CallTraceGql(ctx, q, v, &rsp))
+
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		assert.Equal(t, client.ErrCircuitOpen, errors.Cause(err))
+		assert.Equal(t, 2, calls())
+
+		// a call probes the API once the cooldown elapsed.
+		time.Sleep(60 * time.Millisecond)
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, 3, calls())
+	})
+}
+
+func TestClientService_Errors(t *testing.T) {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
+		ExpiresAt: time.Now().Unix() + 1000,
+		IssuedAt:  time.Now().Unix() - 1000,
+	}).SignedString([]byte("plap"))
+
+	// call sends a query to a Trace API answering with the given status and
+	// body.
+	call := func(t *testing.T, status int, body string) error {
+		traceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.NotEmpty(t, r.Header.Get("X-Request-Id"))
+			w.Header().Set("X-Request-Id", "req-42")
+			w.WriteHeader(status)
+			fmt.Fprintln(w, body)
+		}))
+		accountServer := createMockServer(t, token, 1, nil, "")
+
+		defer traceServer.Close()
+		defer accountServer.Close()
+
+		config := client.Config{
+			TraceURL:          traceServer.URL,
+			AccountURL:        accountServer.URL,
+			SigningPrivateKey: key,
+		}
+
+		s := &client.Service{}
+		s.SetConfig(config)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		c := s.Expose().(client.StratumnClient)
+
+		var rsp testRsp
+		return c.CallTraceGql(ctx, q, v, &rsp)
+	}
+
+	t.Run("Surfaces every GraphQL error", func(t *testing.T) {
+		err := call(t, http.StatusOK, `{"errors": [
+			{"message": "workflow not found", "status": 404, "path": ["workflowByRowId"]},
+			{"message": "link not found", "status": 404, "path": ["workflowByRowId", "links", 0]}
+		]}`)
+		require.Error(t, err)
+		assert.Equal(t, client.ErrNotFound, errors.Cause(err))
+
+		clientErr, ok := err.(*client.Error)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, clientErr.Status)
+		assert.Equal(t, "req-42", clientErr.RequestID)
+		require.Len(t, clientErr.Errors, 2)
+		assert.Equal(t, []interface{}{"workflowByRowId", "links", float64(0)}, clientErr.Errors[1].Path)
+		assert.EqualError(t, err, "graphql (404): workflow not found (at workflowByRowId); link not found (at workflowByRowId.links.0) (request req-42)")
+	})
+
+	t.Run("Classifies the errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			status int
+			body   string
+			kind   error
+		}{
+			{http.StatusUnauthorized, `{"errors": [{"message": "bad token"}]}`, client.ErrUnauthorized},
+			{http.StatusForbidden, "", client.ErrUnauthorized},
+			{http.StatusBadRequest, `{"errors": [{"message": "syntax error"}]}`, client.ErrValidation},
+			{http.StatusOK, `{"errors": [{"message": "unknown field"}]}`, client.ErrAPI},
+			{http.StatusOK, `{"errors": [{"message": "slow down", "status": 429}]}`, client.ErrRateLimited},
+			{http.StatusInternalServerError, "", client.ErrTransport},
+		} {
+			err := call(t, tt.status, tt.body)
+			assert.Equal(t, tt.kind, errors.Cause(err), "status %d: %s", tt.status, tt.body)
+		}
+	})
+
+	t.Run("Reports unreachable APIs", func(t *testing.T) {
+		accountServer := createMockServer(t, token, 1, nil, "")
+		defer accountServer.Close()
+
+		s := &client.Service{}
+		s.SetConfig(client.Config{
+			TraceURL:          "http://127.0.0.1:1",
+			AccountURL:        accountServer.URL,
+			SigningPrivateKey: key,
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		c := s.Expose().(client.StratumnClient)
+
+		var rsp testRsp
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		assert.Equal(t, client.ErrTransport, errors.Cause(err))
+		assert.NotEmpty(t, err.(*client.Error).RequestID)
+	})
+}
+
+func TestClientService_Token(t *testing.T) {
+	// run starts a client whose Account API issues tokens with the given
+	// lifetime, or without expiry when it is zero, and whose Trace API
+	// rejects the first unauthorized calls.
+	// It returns functions counting the logins and the calls to Trace.
+	run := func(t *testing.T, config client.Config, lifetime time.Duration, unauthorized int) (client.StratumnClient, func() int, func() int, func()) {
+		var mu sync.Mutex
+		logins, calls := 0, 0
+
+		accountServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/login", r.URL.String())
+
+			mu.Lock()
+			logins++
+			mu.Unlock()
+
+			// slow logins give concurrent calls a chance to log in too.
+			time.Sleep(10 * time.Millisecond)
+
+			now := time.Now()
+			claims := &jwt.StandardClaims{
+				Id:       fmt.Sprint(now.UnixNano()),
+				IssuedAt: now.Unix(),
+			}
+			if lifetime > 0 {
+				claims.ExpiresAt = now.Add(lifetime).Unix()
+			}
+			token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("plap"))
+			fmt.Fprintf(w, `{"token": "%s"}`, token)
+		}))
+
+		traceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls++
+			if calls <= unauthorized {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintln(w, `{"data": {"value": "42"}}`)
+		}))
+
+		config.TraceURL = traceServer.URL
+		config.AccountURL = accountServer.URL
+		config.SigningPrivateKey = key
+
+		s := &client.Service{}
+		s.SetConfig(config)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		count := func(n *int) func() int {
+			return func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return *n
+			}
+		}
+		stop := func() {
+			cancel()
+			traceServer.Close()
+			accountServer.Close()
+		}
+		return s.Expose().(client.StratumnClient), count(&logins), count(&calls), stop
+	}
+
+	ctx := context.Background()
+
+	t.Run("Logs in once for concurrent calls", func(t *testing.T) {
+		c, logins, calls, stop := run(t, client.Config{}, time.Hour, 0)
+		defer stop()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var rsp testRsp
+				assert.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, logins())
+		assert.Equal(t, 10, calls())
+	})
+
+	t.Run("Renews the token before it expires", func(t *testing.T) {
+		c, logins, _, stop := run(t, client.Config{TokenRefreshRatio: 0.01}, 10*time.Second, 0)
+		defer stop()
+
+		var rsp testRsp
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, 1, logins())
+
+		// the token is renewed in the background, the call does not wait.
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		for i := 0; i < 100 && logins() < 2; i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		assert.Equal(t, 2, logins())
+	})
+
+	t.Run("Uses the requested lifetime for tokens without expiry", func(t *testing.T) {
+		c, logins, calls, stop := run(t, client.Config{}, 0, 0)
+		defer stop()
+
+		var rsp testRsp
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, 1, logins())
+		assert.Equal(t, 2, calls())
+	})
+
+	t.Run("Logs in again when the token is rejected", func(t *testing.T) {
+		c, logins, calls, stop := run(t, client.Config{}, time.Hour, 1)
+		defer stop()
+
+		var rsp testRsp
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, "42", rsp.Value)
+		assert.Equal(t, 2, logins())
+		assert.Equal(t, 2, calls())
+	})
+
+	t.Run("Sends a call once more only", func(t *testing.T) {
+		c, logins, calls, stop := run(t, client.Config{}, time.Hour, 2)
+		defer stop()
+
+		var rsp testRsp
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		assert.Equal(t, client.ErrUnauthorized, errors.Cause(err))
+		assert.Equal(t, 2, logins())
+		assert.Equal(t, 2, calls())
+	})
+
+	t.Run("Rejects bad refresh ratios", func(t *testing.T) {
+		s := &client.Service{}
+		s.SetConfig(client.Config{SigningPrivateKey: key, TokenRefreshRatio: 2})
+
+		err := s.Run(context.Background(), func() {}, func() {})
+		assert.Equal(t, client.ErrBadTokenRefreshRatio, errors.Cause(err))
+	})
+}
+
+func TestClientService_Transport(t *testing.T) {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
+		ExpiresAt: time.Now().Unix() + 1000,
+		IssuedAt:  time.Now().Unix() - 1000,
+	}).SignedString([]byte("plap"))
+
+	dir, err := ioutil.TempDir("", "client-transport")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// writePEM writes a PEM block to a file of the temporary directory.
+	writePEM := func(t *testing.T, name, blockType string, der []byte) string {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+		return filename
+	}
+
+	// handler serves both APIs and checks the custom headers of the
+	// requests.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "connector-42", r.Header.Get("X-Connector-Id"))
+
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprintf(w, `{"token": "%s"}`, token)
+		case "/graphql":
+			fmt.Fprintln(w, `{"data": {"value": "42"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	// run starts a client calling both APIs at the given URL.
+	run := func(t *testing.T, url string, config client.Config) (client.StratumnClient, func()) {
+		config.TraceURL = url
+		config.AccountURL = url
+		config.SigningPrivateKey = key
+		config.Headers = append(config.Headers, "X-Connector-Id: connector-42")
+
+		s := &client.Service{}
+		s.SetConfig(config)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		runningCh := make(chan struct{})
+
+		go s.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+		<-runningCh
+
+		return s.Expose().(client.StratumnClient), cancel
+	}
+
+	ctx := context.Background()
+
+	t.Run("Trusts the CA bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(handler)
+		defer server.Close()
+
+		var rsp testRsp
+
+		c, stop := run(t, server.URL, client.Config{})
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		stop()
+		assert.Equal(t, client.ErrTransport, errors.Cause(err))
+
+		caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+		c, stop = run(t, server.URL, client.Config{CAFile: caFile})
+		defer stop()
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, "42", rsp.Value)
+	})
+
+	t.Run("Presents the client certificate", func(t *testing.T) {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(42),
+			Subject:               pkix.Name{CommonName: "connector"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		keyDer, err := x509.MarshalECPrivateKey(priv)
+		require.NoError(t, err)
+
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(cert)
+
+		server := httptest.NewUnstartedServer(handler)
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		server.StartTLS()
+		defer server.Close()
+
+		c, stop := run(t, server.URL, client.Config{
+			CAFile:         writePEM(t, "server.pem", "CERTIFICATE", server.Certificate().Raw),
+			ClientCertFile: writePEM(t, "client.pem", "CERTIFICATE", der),
+			ClientKeyFile:  writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDer),
+		})
+		defer stop()
+
+		var rsp testRsp
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, "42", rsp.Value)
+	})
+
+	t.Run("Calls the APIs through the proxy", func(t *testing.T) {
+		var mu sync.Mutex
+		var hosts []string
+
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hosts = append(hosts, r.URL.Host)
+			mu.Unlock()
+
+			handler.ServeHTTP(w, r)
+		}))
+		defer proxy.Close()
+
+		c, stop := run(t, "http://stratumn.invalid", client.Config{ProxyURL: proxy.URL})
+		defer stop()
+
+		var rsp testRsp
+		require.NoError(t, c.CallTraceGql(ctx, q, v, &rsp))
+		assert.Equal(t, "42", rsp.Value)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"stratumn.invalid", "stratumn.invalid"}, hosts)
+	})
+
+	t.Run("Times out slow APIs", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/graphql" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			handler.ServeHTTP(w, r)
+		}))
+		defer server.Close()
+
+		c, stop := run(t, server.URL, client.Config{ReadTimeout: 20})
+		defer stop()
+
+		var rsp testRsp
+		err := c.CallTraceGql(ctx, q, v, &rsp)
+		assert.Equal(t, client.ErrTransport, errors.Cause(err))
+	})
+
+	t.Run("Rejects malformed settings", func(t *testing.T) {
+		for _, tt := range []struct {
+			config client.Config
+			err    error
+		}{
+			{client.Config{ProxyURL: "proxy:3128"}, client.ErrBadProxyURL},
+			{client.Config{CAFile: writePEM(t, "empty.pem", "EMPTY", nil)}, client.ErrBadCAFile},
+			{client.Config{ClientCertFile: "client.pem"}, client.ErrMissingClientKey},
+			{client.Config{Headers: []string{"X-Connector-Id"}}, client.ErrBadHeader},
+		} {
+			tt.config.SigningPrivateKey = key
+			s := &client.Service{}
+			s.SetConfig(tt.config)
+
+			err := s.Run(context.Background(), func() {}, func() {})
+			assert.Equal(t, tt.err, errors.Cause(err))
+		}
+	})
+}
+
 func TestClientService_NoDecryption(t *testing.T) {
 	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
 		ExpiresAt: time.Now().Unix() + 1000,
@@ -500,6 +1164,27 @@ func TestClientService_RawLinkDecryption(t *testing.T) {
 		// raw should be decrypted.
 		assert.Equal(t, linkData, rsp.Link.Raw.Data)
 	})
+
+	t.Run("raw call decrypted afterwards", func(t *testing.T) {
+		var rsp struct {
+			Link struct {
+				Raw *chainscript.Link
+			}
+		}
+
+		// the decryptor is not called until the links are decrypted.
+		err := c.CallTraceGqlRaw(ctx, q, v, &rsp)
+		assert.NoError(t, err)
+		assert.Empty(t, rsp.Link.Raw.Data)
+
+		mockDec.EXPECT().DecryptLink(ctx, csLink).Times(1).Do(func(ctx context.Context, l *chainscript.Link) error {
+			l.Data = linkData
+			return nil
+		})
+
+		c.DecryptLinks(ctx, &rsp)
+		assert.Equal(t, linkData, rsp.Link.Raw.Data)
+	})
 }
 
 // Check that if another field is called raw, nothing fails.
@@ -800,3 +1485,50 @@ func createMockServer(t *testing.T, token string, maxLogin int, expected map[str
 		}
 	}))
 }
+
+// createMockWebsocketServer serves a graphql-ws subscription that sends the
+// given payloads, then completes. The connection is rejected when the token
+// sent in the connection payload differs from the given one.
+func createMockWebsocketServer(t *testing.T, token string, payloads []string) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"graphql-ws"}}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/graphql", r.URL.String())
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		type message struct {
+			ID      string          `json:"id,omitempty"`
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload,omitempty"`
+		}
+
+		var init message
+		require.NoError(t, conn.ReadJSON(&init))
+		require.Equal(t, "connection_init", init.Type)
+
+		var auth map[string]string
+		require.NoError(t, json.Unmarshal(init.Payload, &auth))
+		if auth["authorization"] != fmt.Sprintf("Bearer %s", token) {
+			conn.WriteJSON(&message{Type: "connection_error", Payload: json.RawMessage(`{"message":"unauthorized"}`)})
+			return
+		}
+		require.NoError(t, conn.WriteJSON(&message{Type: "connection_ack"}))
+
+		var start message
+		require.NoError(t, conn.ReadJSON(&start))
+		require.Equal(t, "start", start.Type)
+
+		var req map[string]interface{}
+		require.NoError(t, json.Unmarshal(start.Payload, &req))
+		assert.Equal(t, expected["query"], req["query"])
+		assert.Equal(t, expected["variables"], req["variables"])
+
+		for _, p := range payloads {
+			require.NoError(t, conn.WriteJSON(&message{ID: start.ID, Type: "data", Payload: json.RawMessage(p)}))
+		}
+		conn.WriteJSON(&message{ID: start.ID, Type: "complete"})
+	}))
+}
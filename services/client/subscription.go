@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GraphQL over WebSocket protocol (graphql-ws).
+const (
+	gqlWsProtocol = "graphql-ws"
+
+	gqlMsgConnectionInit      = "connection_init"
+	gqlMsgConnectionAck       = "connection_ack"
+	gqlMsgConnectionError     = "connection_error"
+	gqlMsgConnectionTerminate = "connection_terminate"
+	gqlMsgStart               = "start"
+	gqlMsgStop                = "stop"
+	gqlMsgData                = "data"
+	gqlMsgError               = "error"
+	gqlMsgComplete            = "complete"
+
+	// gqlSubscriptionID is the ID of the single subscription of a connection.
+	gqlSubscriptionID = "1"
+)
+
+const (
+	// DefaultHandshakeTimeout is the time given to the Trace websocket
+	// endpoint to accept a subscription.
+	DefaultHandshakeTimeout = 10 * time.Second
+
+	// DefaultPingInterval is the interval at which the Trace websocket
+	// endpoint is pinged while a subscription is open.
+	DefaultPingInterval = 20 * time.Second
+
+	// DefaultSubscriptionTimeout is the time after which a subscription is
+	// considered lost when nothing was received from the Trace websocket
+	// endpoint, pongs and keep alive messages included.
+	DefaultSubscriptionTimeout = 3 * DefaultPingInterval
+)
+
+var (
+	// ErrSubscriptionRejected is returned when the Trace websocket endpoint
+	// rejects a subscription.
+	ErrSubscriptionRejected = errors.New("the subscription was rejected")
+)
+
+type gqlMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// websocketURL returns the URL of the websocket endpoint of an HTTP API.
+func websocketURL(url string) string {
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		return "wss://" + strings.TrimPrefix(url, "https://")
+	case strings.HasPrefix(url, "http://"):
+		return "ws://" + strings.TrimPrefix(url, "http://")
+	default:
+		return url
+	}
+}
+
+// SubscribeTraceGql opens a GraphQL subscription on the Trace websocket
+// endpoint.
+// The data of the events is sent to the returned channel, which is closed
+// when the subscription ends: the connection is lost, the server completes
+// the subscription or the context is done.
+// Contrary to CallTraceGql, the links of the events are not decrypted.
+func (c *client) SubscribeTraceGql(ctx context.Context, query string, variables map[string]interface{}) (<-chan json.RawMessage, error) {
+	token, err := c.tokens.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	authorization := fmt.Sprintf("Bearer %s", token)
+
+	header := http.Header{}
+	header.Set("authorization", authorization)
+
+	requestID := uuid.NewV4().String()
+	header.Set(requestIDHeader, requestID)
+
+	addHeaders(header, c.transport.headers)
+
+	conn, r, err := c.transport.dialer().DialContext(ctx, websocketURL(c.urlTrace)+"/graphql", header)
+	if err == websocket.ErrBadHandshake && r != nil {
+		if r.StatusCode == http.StatusUnauthorized {
+			// the next subscription logs in again.
+			c.tokens.invalidate(token)
+		}
+		return nil, newHTTPError(r, requestID)
+	}
+	if err != nil {
+		return nil, newTransportError(err, requestID)
+	}
+
+	if err := handshake(conn, authorization, query, variables); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	events := make(chan json.RawMessage)
+	go readEvents(ctx, conn, events)
+
+	return events, nil
+}
+
+// handshake initializes the connection and starts the subscription.
+func handshake(conn *websocket.Conn, authorization, query string, variables map[string]interface{}) error {
+	conn.SetReadDeadline(time.Now().Add(DefaultHandshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	init, err := json.Marshal(map[string]string{"authorization": authorization})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := conn.WriteJSON(&gqlMessage{Type: gqlMsgConnectionInit, Payload: init}); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for {
+		var msg gqlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return errors.WithStack(err)
+		}
+
+		switch msg.Type {
+		case gqlMsgConnectionAck:
+			start, err := json.Marshal(map[string]interface{}{
+				"query":     query,
+				"variables": variables,
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return errors.WithStack(conn.WriteJSON(&gqlMessage{ID: gqlSubscriptionID, Type: gqlMsgStart, Payload: start}))
+		case gqlMsgConnectionError:
+			return errors.Wrap(ErrSubscriptionRejected, string(msg.Payload))
+		}
+	}
+}
+
+// readEvents forwards the data of the subscription until it ends, then
+// closes the connection and the channel.
+// The endpoint is pinged at regular intervals, and the subscription ends when
+// nothing is received for too long, so that a connection that silently
+// dropped is not mistaken for a subscription without events.
+func readEvents(ctx context.Context, conn *websocket.Conn, events chan<- json.RawMessage) {
+	defer close(events)
+
+	extendDeadline := func() error {
+		return conn.SetReadDeadline(time.Now().Add(DefaultSubscriptionTimeout))
+	}
+	extendDeadline()
+	conn.SetPongHandler(func(string) error { return extendDeadline() })
+
+	// ping the endpoint and unblock the reads when the context is done.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(DefaultPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(DefaultPingInterval))
+				if err == nil {
+					continue
+				}
+			case <-ctx.Done():
+				conn.WriteJSON(&gqlMessage{ID: gqlSubscriptionID, Type: gqlMsgStop})
+				conn.WriteJSON(&gqlMessage{Type: gqlMsgConnectionTerminate})
+			case <-stop:
+			}
+			conn.Close()
+			return
+		}
+	}()
+
+	// keep alive messages (ka) are ignored, they only extend the deadline.
+	for {
+		var msg gqlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() == nil {
+				log.Warnf("Trace subscription closed: %s", err)
+			}
+			return
+		}
+		extendDeadline()
+
+		switch msg.Type {
+		case gqlMsgData:
+			var rsp struct {
+				Data   json.RawMessage
+				Errors []GraphQLError
+			}
+			if err := json.Unmarshal(msg.Payload, &rsp); err != nil {
+				log.Warnf("Trace subscription sent a malformed event: %s", err)
+				return
+			}
+			if len(rsp.Errors) > 0 {
+				log.Warnf("Trace subscription error: %s", &Error{Kind: kindOf(rsp.Errors[0].Status), Status: rsp.Errors[0].Status, Errors: rsp.Errors})
+				continue
+			}
+			if rsp.Data == nil {
+				continue
+			}
+
+			select {
+			case events <- rsp.Data:
+			case <-ctx.Done():
+				return
+			}
+		case gqlMsgError, gqlMsgComplete, gqlMsgConnectionError:
+			return
+		}
+	}
+}
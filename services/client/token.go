@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTokenLifetime is the default lifetime requested for the authentication tokens (in milliseconds).
+	DefaultTokenLifetime = 300000
+
+	// DefaultTokenRefreshRatio is the default fraction of the lifetime of a token after which it is renewed.
+	DefaultTokenRefreshRatio = 0.8
+)
+
+// expiryMargin is the time before its expiry a token stops being used.
+const expiryMargin = time.Second
+
+var (
+	// ErrBadTokenRefreshRatio is returned when the refresh ratio of the tokens
+	// is not a fraction.
+	ErrBadTokenRefreshRatio = errors.New("the token refresh ratio must be between 0 and 1")
+)
+
+// TokenPolicy configures the authentication tokens of the client.
+type TokenPolicy struct {
+	// Lifetime is the lifetime requested when logging in.
+	Lifetime time.Duration
+
+	// RefreshRatio is the fraction of the lifetime of a token after which it
+	// is renewed in the background. The token is still used until the new
+	// one is received.
+	RefreshRatio float64
+}
+
+// Validate checks that the token policy is valid.
+func (p TokenPolicy) Validate() error {
+	if p.RefreshRatio < 0 || p.RefreshRatio > 1 {
+		return ErrBadTokenRefreshRatio
+	}
+	return nil
+}
+
+// loginCall is a running login, shared by the calls waiting for a token.
+type loginCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenManager logs in and renews the authentication token.
+// It is safe for concurrent use: a single login runs at a time and the
+// calls needing a token wait for it.
+type tokenManager struct {
+	login        func(context.Context) (string, error)
+	lifetime     time.Duration
+	refreshRatio float64
+
+	mu sync.Mutex
+	// token is the current token, empty until the first login.
+	token string
+	// refreshAt is when the token is renewed in the background.
+	refreshAt time.Time
+	// expiresAt is when the token stops being used.
+	expiresAt time.Time
+	// call is the running login, if any.
+	call *loginCall
+}
+
+func newTokenManager(login func(context.Context) (string, error), policy TokenPolicy) *tokenManager {
+	lifetime := policy.Lifetime
+	if lifetime <= 0 {
+		lifetime = time.Millisecond * DefaultTokenLifetime
+	}
+
+	refreshRatio := policy.RefreshRatio
+	if refreshRatio <= 0 {
+		refreshRatio = DefaultTokenRefreshRatio
+	}
+
+	return &tokenManager{
+		login:        login,
+		lifetime:     lifetime,
+		refreshRatio: refreshRatio,
+	}
+}
+
+// get returns a valid token.
+// It logs in when there is no valid token, and starts renewing the token
+// in the background once the refresh ratio of its lifetime elapsed.
+func (m *tokenManager) get(ctx context.Context) (string, error) {
+	m.mu.Lock()
+
+	now := time.Now()
+	if m.token != "" && now.Before(m.expiresAt) {
+		if !now.Before(m.refreshAt) {
+			m.startLogin()
+		}
+		token := m.token
+		m.mu.Unlock()
+		return token, nil
+	}
+
+	call := m.startLogin()
+	m.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", errors.WithStack(ctx.Err())
+	}
+}
+
+// invalidate drops a token rejected by an API, unless it was already
+// renewed, so that the next call logs in again.
+func (m *tokenManager) invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token == token {
+		m.token = ""
+	}
+}
+
+// startLogin starts a login unless one is running, and returns it.
+// The login is not tied to the context of a call since other calls may
+// wait for it.
+// It must be called with the lock held.
+func (m *tokenManager) startLogin() *loginCall {
+	if m.call != nil {
+		return m.call
+	}
+
+	call := &loginCall{done: make(chan struct{})}
+	m.call = call
+
+	go func() {
+		defer close(call.done)
+
+		token, err := m.login(context.Background())
+		var claims jwt.StandardClaims
+		if err == nil {
+			_, _, err = new(jwt.Parser).ParseUnverified(token, &claims)
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.call = nil
+
+		if err != nil {
+			if m.token != "" {
+				log.Warnf("Could not renew the authentication token, keeping the current one: %s", err)
+			}
+			call.err = err
+			return
+		}
+
+		issuedAt := time.Now()
+		if claims.IssuedAt > 0 {
+			issuedAt = time.Unix(claims.IssuedAt, 0)
+		}
+		// tokens without expiry are renewed as if they had the requested
+		// lifetime.
+		expiresAt := issuedAt.Add(m.lifetime)
+		if claims.ExpiresAt > 0 {
+			expiresAt = time.Unix(claims.ExpiresAt, 0)
+		}
+		lifetime := expiresAt.Sub(issuedAt)
+
+		m.token = token
+		m.refreshAt = issuedAt.Add(time.Duration(float64(lifetime) * m.refreshRatio))
+		m.expiresAt = expiresAt.Add(-expiryMargin)
+		call.token = token
+	}()
+
+	return call
+}
@@ -16,7 +16,17 @@ import (
 // TraceClient defines all the possible interactions with Trace.
 type TraceClient interface {
 	// CallTraceGql makes a call to the Trace graphql endpoint.
+	// The links of the response are decrypted when a decryptor is configured.
 	CallTraceGql(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error
+	// CallTraceGqlRaw makes a call to the Trace graphql endpoint without
+	// decrypting the links of the response, e.g. to verify their hashes.
+	CallTraceGqlRaw(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error
+	// DecryptLinks decrypts in place the links of a response returned by
+	// CallTraceGqlRaw. It does nothing when no decryptor is configured.
+	DecryptLinks(ctx context.Context, rsp interface{})
+	// SubscribeTraceGql opens a subscription on the Trace graphql websocket
+	// endpoint. The returned channel is closed when the subscription ends.
+	SubscribeTraceGql(ctx context.Context, query string, variables map[string]interface{}) (<-chan json.RawMessage, error)
 	CreateLink(ctx context.Context, link *chainscript.Link) (*CreateLinkPayload, error)
 	CreateLinks(ctx context.Context, links []*chainscript.Link) (*CreateLinksPayload, error)
 
@@ -25,15 +35,23 @@ type TraceClient interface {
 }
 
 func (c *client) CallTraceGql(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
-	err := c.callGqlEndpoint(ctx, c.urlTrace+"/graphql", query, variables, rsp)
+	err := c.CallTraceGqlRaw(ctx, query, variables, rsp)
 	if err != nil {
 		return err
 	}
 
+	c.DecryptLinks(ctx, rsp)
+	return nil
+}
+
+func (c *client) CallTraceGqlRaw(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+	return c.callGqlEndpoint(ctx, c.traceBreaker, c.urlTrace+"/graphql", query, variables, rsp)
+}
+
+func (c *client) DecryptLinks(ctx context.Context, rsp interface{}) {
 	if c.decryptor != nil {
 		c.decryptLinks(ctx, reflect.ValueOf(rsp))
 	}
-	return nil
 }
 
 type encryptedLink struct {
@@ -155,9 +173,9 @@ func (c *client) parseAndDecryptLink(ctx context.Context, v reflect.Value) {
 type CreateLinkPayload struct {
 	CreateLink struct {
 		Trace struct {
-			RowID string
-		}
-	}
+			RowID string `json:"rowId"`
+		} `json:"trace"`
+	} `json:"createLink"`
 }
 
 // CreateLinkMutation is the mutation sent to create a link.
@@ -190,9 +208,9 @@ func (c *client) CreateLink(ctx context.Context, link *chainscript.Link) (*Creat
 type CreateLinksPayload struct {
 	CreateLinks struct {
 		Links []struct {
-			TraceID string
-		}
-	}
+			TraceID string `json:"traceId"`
+		} `json:"links"`
+	} `json:"createLinks"`
 }
 
 // CreateLinksMutation is the mutation sent to create multiple links.
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultConnectTimeout is the default time given to establish a connection, TLS handshake included (in milliseconds).
+	DefaultConnectTimeout = 5000
+
+	// DefaultReadTimeout is the default time given to the APIs to answer a request once it is sent (in milliseconds).
+	DefaultReadTimeout = 10000
+
+	// DefaultTimeout is the default time given to a request, from the connection to the end of the response (in milliseconds).
+	DefaultTimeout = 10000
+
+	// DefaultMaxIdleConns is the default number of idle connections kept open.
+	DefaultMaxIdleConns = 100
+
+	// DefaultMaxIdleConnsPerHost is the default number of idle connections kept open to each API.
+	DefaultMaxIdleConnsPerHost = 10
+)
+
+var (
+	// ErrBadProxyURL is returned when the proxy URL is malformed.
+	ErrBadProxyURL = errors.New("the proxy URL is malformed")
+
+	// ErrBadCAFile is returned when the CA bundle contains no certificate.
+	ErrBadCAFile = errors.New("the CA bundle contains no PEM encoded certificate")
+
+	// ErrMissingClientKey is returned when a client certificate is given
+	// without its key, or the opposite.
+	ErrMissingClientKey = errors.New("the client certificate and key must be given together")
+
+	// ErrBadHeader is returned when a custom header is not formatted as
+	// "Name: value".
+	ErrBadHeader = errors.New(`the headers must be formatted as "Name: value"`)
+)
+
+// Transport configures the connections to the Stratumn APIs.
+type Transport struct {
+	// ProxyURL is the URL of the HTTP(S) proxy. The proxy is taken from the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables when it is
+	// empty.
+	ProxyURL string
+
+	// CAFile is a PEM bundle of the certificate authorities trusted in
+	// addition to the ones of the system.
+	CAFile string
+
+	// CertFile and KeyFile are the PEM encoded certificate and key
+	// authenticating the connector with mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// ConnectTimeout bounds the connection, TLS handshake included.
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds the wait for the response once the request is sent.
+	ReadTimeout time.Duration
+	// Timeout bounds the whole request, response body included.
+	Timeout time.Duration
+
+	// MaxIdleConns and MaxIdleConnsPerHost size the pool of idle
+	// connections. MaxConnsPerHost limits the connections to each API, zero
+	// means no limit.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// Headers are added to every request, formatted as "Name: value".
+	Headers []string
+}
+
+// httpTransport holds the settings shared by the HTTP client and the
+// websocket dialer.
+type httpTransport struct {
+	config    Transport
+	proxy     func(*http.Request) (*url.URL, error)
+	tlsConfig *tls.Config
+	headers   http.Header
+}
+
+// newHTTPTransport loads the files and parses the settings of a transport
+// configuration. Zero durations and pool sizes are set to their default.
+func newHTTPTransport(config Transport) (*httpTransport, error) {
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = time.Millisecond * DefaultConnectTimeout
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = time.Millisecond * DefaultReadTimeout
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Millisecond * DefaultTimeout
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if config.MaxIdleConnsPerHost <= 0 {
+		config.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	t := &httpTransport{config: config, proxy: http.ProxyFromEnvironment}
+
+	if config.ProxyURL != "" {
+		u, err := url.Parse(config.ProxyURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Wrap(ErrBadProxyURL, config.ProxyURL)
+		}
+		t.proxy = http.ProxyURL(u)
+	}
+
+	var err error
+	if t.tlsConfig, err = loadTLSConfig(config); err != nil {
+		return nil, err
+	}
+	if t.headers, err = parseHeaders(config.Headers); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// loadTLSConfig returns the TLS configuration trusting the CA bundle and
+// presenting the client certificate. It returns nil when neither is set.
+func loadTLSConfig(config Transport) (*tls.Config, error) {
+	if config.CAFile == "" && config.CertFile == "" && config.KeyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if config.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		bundle, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.Wrap(ErrBadCAFile, config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, ErrMissingClientKey
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseHeaders parses the headers formatted as "Name: value".
+func parseHeaders(headers []string) (http.Header, error) {
+	parsed := http.Header{}
+	for _, h := range headers {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Wrap(ErrBadHeader, h)
+		}
+		parsed.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return parsed, nil
+}
+
+// netDialer opens the connections within the connect timeout.
+func (t *httpTransport) netDialer() *net.Dialer {
+	return &net.Dialer{Timeout: t.config.ConnectTimeout, KeepAlive: 30 * time.Second}
+}
+
+// client returns the HTTP client calling the APIs.
+func (t *httpTransport) client() *http.Client {
+	var rt http.RoundTripper = &http.Transport{
+		Proxy:                 t.proxy,
+		DialContext:           t.netDialer().DialContext,
+		TLSClientConfig:       t.tlsConfig,
+		TLSHandshakeTimeout:   t.config.ConnectTimeout,
+		ResponseHeaderTimeout: t.config.ReadTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          t.config.MaxIdleConns,
+		MaxIdleConnsPerHost:   t.config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       t.config.MaxConnsPerHost,
+	}
+	if len(t.headers) > 0 {
+		rt = &headerRoundTripper{base: rt, headers: t.headers}
+	}
+
+	return &http.Client{Transport: rt, Timeout: t.config.Timeout}
+}
+
+// dialer returns the dialer of the websocket subscriptions.
+func (t *httpTransport) dialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		NetDial:          t.netDialer().Dial,
+		Proxy:            t.proxy,
+		TLSClientConfig:  t.tlsConfig,
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		Subprotocols:     []string{gqlWsProtocol},
+	}
+}
+
+// addHeaders adds the custom headers missing from the headers of a request.
+func addHeaders(header, custom http.Header) {
+	for name, values := range custom {
+		if _, ok := header[name]; !ok {
+			header[name] = values
+		}
+	}
+}
+
+// headerRoundTripper adds the custom headers to the requests.
+type headerRoundTripper struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+// RoundTrip sends a copy of the request with the custom headers it does not
+// set itself, since a round tripper must not modify the request.
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+len(rt.headers))
+	for name, values := range req.Header {
+		r.Header[name] = values
+	}
+	addHeaders(r.Header, rt.headers)
+
+	return rt.base.RoundTrip(r)
+}
@@ -0,0 +1,476 @@
+package forkdetector
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-node/core/db"
+
+	"github.com/stratumn/go-connector/services/livesync"
+)
+
+// SubscriptionName is the name of the livesync subscription of the detector.
+const SubscriptionName = "forkdetector"
+
+// Kinds of the events detected in the traces.
+const (
+	// Fork is detected when a link has more children than its out degree
+	// allows, or more than one when it has no out degree.
+	Fork = "fork"
+
+	// Orphan is detected when the previous link of a link was not synced in
+	// the same trace.
+	Orphan = "orphan"
+
+	// OutOfOrderPriority is detected when the priority of a link does not
+	// follow the priority of its previous link.
+	OutOfOrderPriority = "out-of-order-priority"
+)
+
+var (
+	// ErrSyncStopped is returned when the subscription channel is closed by the synchronizer service.
+	ErrSyncStopped = errors.New("synchronizer service stopped")
+
+	// Prefixes of the keys in the store.
+	nodePrefix   = []byte("forkdetector/node/")
+	orphanPrefix = []byte("forkdetector/orphan/")
+	tracePrefix  = []byte("forkdetector/trace/")
+	eventPrefix  = []byte("forkdetector/event/")
+	// seqPrefix is the key of the sequence number of the last event, which
+	// orders the events detected at the same time across restarts.
+	seqPrefix = []byte("forkdetector/seq")
+	// workflowPrefix marks the workflows whose past links were added to the
+	// store.
+	workflowPrefix = []byte("forkdetector/workflow/")
+)
+
+// Detector is the type exposed by the fork detector service.
+type Detector interface {
+	// Events returns the events detected in a trace, in the order they were
+	// detected. It returns the events of all the traces when the map ID is
+	// empty.
+	Events(mapID string) ([]*Event, error)
+}
+
+// Event reports an anomaly detected in a trace.
+type Event struct {
+	Kind       string    `json:"kind"`
+	Time       time.Time `json:"time"`
+	WorkflowID string    `json:"workflowId"`
+	MapID      string    `json:"mapId"`
+
+	// LinkHash is the hex encoded hash of the link that revealed the anomaly.
+	LinkHash     string  `json:"linkHash"`
+	PrevLinkHash string  `json:"prevLinkHash,omitempty"`
+	Priority     float64 `json:"priority"`
+
+	// Children are the links sharing the previous link, for forks.
+	Children []string `json:"children,omitempty"`
+
+	// ExpectedPriority is the priority following the priority of the
+	// previous link, for out-of-order priorities.
+	ExpectedPriority float64 `json:"expectedPriority,omitempty"`
+}
+
+// node is a link of the DAG of a trace.
+type node struct {
+	PrevLinkHash string   `json:"prevLinkHash,omitempty"`
+	Priority     float64  `json:"priority"`
+	OutDegree    int32    `json:"outDegree"`
+	Children     []string `json:"children,omitempty"`
+}
+
+// trace summarizes the links of a trace.
+type trace struct {
+	Links       uint64  `json:"links"`
+	MaxPriority float64 `json:"maxPriority"`
+}
+
+type detector struct {
+	db           db.DB
+	synchronizer livesync.Synchronizer
+}
+
+func newDetector(store db.DB, synchronizer livesync.Synchronizer) *detector {
+	return &detector{db: store, synchronizer: synchronizer}
+}
+
+// run subscribes to the livesync service and adds the synced links to the
+// DAG of their trace.
+// A batch that cannot be saved is not acknowledged so that livesync delivers
+// it again.
+// It returns an error in case the channel is closed.
+func (d *detector) run(ctx context.Context) error {
+	// pass nil to subscribe to all updates
+	batches, err := d.synchronizer.Subscribe(SubscriptionName, nil)
+	if err != nil {
+		return err
+	}
+	defer d.synchronizer.Unsubscribe(batches)
+
+	for {
+		select {
+		case batch, more := <-batches:
+			if !more {
+				return ErrSyncStopped
+			}
+			events, err := d.process(ctx, batch)
+			if err != nil {
+				batch.Nack(err)
+				report(events)
+				continue
+			}
+			batch.Ack()
+			report(events)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// report logs the detected events.
+func report(events []*Event) {
+	for _, e := range events {
+		log.WithFields(map[string]interface{}{
+			"kind":             e.Kind,
+			"workflowId":       e.WorkflowID,
+			"mapId":            e.MapID,
+			"linkHash":         e.LinkHash,
+			"prevLinkHash":     e.PrevLinkHash,
+			"priority":         e.Priority,
+			"children":         e.Children,
+			"expectedPriority": e.ExpectedPriority,
+		}).Warnf("Detected %s in trace %s", e.Kind, e.MapID)
+	}
+}
+
+// process adds the links of a batch to the DAG of their trace.
+// The subscription resumes from its acknowledged cursor, so the links of the
+// workflow synced before are added first when the store does not have them,
+// e.g. after a restart with an in-memory store.
+func (d *detector) process(ctx context.Context, batch *livesync.Batch) ([]*Event, error) {
+	var rebuilt bool
+	found, err := newTx(d.db).get(workflowPrefix, batch.WorkflowID, &rebuilt)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	if !found {
+		if events, err = d.rebuild(ctx, batch.WorkflowID); err != nil {
+			return events, err
+		}
+	}
+
+	added, err := d.add(batch.WorkflowID, batch.Segments, !found)
+	return append(events, added...), err
+}
+
+// rebuild adds the links synced so far in a workflow to the DAG of their
+// trace. Links already in the DAG are ignored, and the links quarantined by
+// livesync are left out by Fetch.
+func (d *detector) rebuild(ctx context.Context, workflowID string) ([]*Event, error) {
+	log.Infof("Rebuilding the traces of workflow %s", workflowID)
+
+	var events []*Event
+	cursor := ""
+	for {
+		segments, next, err := d.synchronizer.Fetch(ctx, workflowID, cursor)
+		if err != nil {
+			return events, err
+		}
+		if len(segments) == 0 {
+			return events, nil
+		}
+
+		added, err := d.add(workflowID, segments, false)
+		events = append(events, added...)
+		if err != nil {
+			return events, err
+		}
+		cursor = next
+	}
+}
+
+// add inserts links in the DAG of their trace and returns the detected
+// events. The changes are written at once, with the mark of a rebuilt
+// workflow when requested.
+// Links already in the DAG are ignored, so a batch delivered again does not
+// report its events twice.
+func (d *detector) add(workflowID string, segments []*cs.Segment, rebuilt bool) ([]*Event, error) {
+	tx := newTx(d.db)
+	if rebuilt {
+		if err := tx.put(workflowPrefix, workflowID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []*Event
+	for _, s := range segments {
+		if s.Link == nil || s.Link.Meta == nil {
+			continue
+		}
+
+		linkHash := s.Meta.LinkHash
+		if len(linkHash) == 0 {
+			lh, err := s.Link.Hash()
+			if err != nil {
+				return nil, err
+			}
+			linkHash = lh
+		}
+
+		e, err := d.addLink(tx, workflowID, hex.EncodeToString(linkHash), s.Link)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e...)
+	}
+
+	if len(events) > 0 {
+		var seq uint64
+		if _, err := tx.get(seqPrefix, "", &seq); err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			seq++
+			key := fmt.Sprintf("%s/%020d-%020d", e.MapID, e.Time.UnixNano(), seq)
+			if err := tx.put(eventPrefix, key, e); err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.put(seqPrefix, "", seq); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.db.Write(tx.batch); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return events, nil
+}
+
+// addLink inserts a link in the DAG of its trace.
+func (d *detector) addLink(tx *tx, workflowID, linkHash string, link *cs.Link) ([]*Event, error) {
+	mapID := link.Meta.MapId
+	nodeKey := mapID + "/" + linkHash
+
+	var n node
+	found, err := tx.get(nodePrefix, nodeKey, &n)
+	if err != nil || found {
+		return nil, err
+	}
+
+	n = node{
+		PrevLinkHash: hex.EncodeToString(link.Meta.PrevLinkHash),
+		Priority:     link.Meta.Priority,
+		OutDegree:    link.Meta.OutDegree,
+	}
+
+	newEvent := func(kind string) *Event {
+		return &Event{
+			Kind:         kind,
+			Time:         time.Now(),
+			WorkflowID:   workflowID,
+			MapID:        mapID,
+			LinkHash:     linkHash,
+			PrevLinkHash: n.PrevLinkHash,
+			Priority:     n.Priority,
+		}
+	}
+	var events []*Event
+
+	// children synced before the link become its children.
+	var orphans []string
+	if _, err := tx.get(orphanPrefix, nodeKey, &orphans); err != nil {
+		return nil, err
+	}
+	if len(orphans) > 0 {
+		n.Children = orphans
+		tx.delete(orphanPrefix, nodeKey)
+		if isFork(&n) {
+			e := newEvent(Fork)
+			e.Children = n.Children
+			events = append(events, e)
+		}
+
+		// the priorities of the orphans could not be checked without
+		// their previous link.
+		for _, orphanHash := range orphans {
+			var orphan node
+			found, err := tx.get(nodePrefix, mapID+"/"+orphanHash, &orphan)
+			if err != nil {
+				return nil, err
+			}
+			if expected := n.Priority + 1; found && orphan.Priority != expected {
+				e := newEvent(OutOfOrderPriority)
+				e.LinkHash = orphanHash
+				e.PrevLinkHash = linkHash
+				e.Priority = orphan.Priority
+				e.ExpectedPriority = expected
+				events = append(events, e)
+			}
+		}
+	}
+
+	if n.PrevLinkHash != "" {
+		parentKey := mapID + "/" + n.PrevLinkHash
+
+		var parent node
+		found, err := tx.get(nodePrefix, parentKey, &parent)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			parent.Children = append(parent.Children, linkHash)
+			if err := tx.put(nodePrefix, parentKey, &parent); err != nil {
+				return nil, err
+			}
+			if isFork(&parent) {
+				e := newEvent(Fork)
+				e.Children = parent.Children
+				events = append(events, e)
+			}
+			if expected := parent.Priority + 1; n.Priority != expected {
+				e := newEvent(OutOfOrderPriority)
+				e.ExpectedPriority = expected
+				events = append(events, e)
+			}
+		} else {
+			events = append(events, newEvent(Orphan))
+
+			var siblings []string
+			if _, err := tx.get(orphanPrefix, parentKey, &siblings); err != nil {
+				return nil, err
+			}
+			if err := tx.put(orphanPrefix, parentKey, append(siblings, linkHash)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var t trace
+	if _, err := tx.get(tracePrefix, mapID, &t); err != nil {
+		return nil, err
+	}
+	t.Links++
+	if n.Priority > t.MaxPriority {
+		t.MaxPriority = n.Priority
+	}
+	if err := tx.put(tracePrefix, mapID, &t); err != nil {
+		return nil, err
+	}
+
+	return events, tx.put(nodePrefix, nodeKey, &n)
+}
+
+// isFork returns whether a link has more children than allowed.
+// A negative out degree allows any number of children.
+func isFork(n *node) bool {
+	if n.OutDegree < 0 {
+		return false
+	}
+
+	allowed := int(n.OutDegree)
+	if allowed == 0 {
+		allowed = 1
+	}
+	return len(n.Children) > allowed
+}
+
+// Events returns the events detected in a trace.
+func (d *detector) Events(mapID string) ([]*Event, error) {
+	prefix := eventPrefix
+	if mapID != "" {
+		prefix = append(append([]byte{}, eventPrefix...), mapID+"/"...)
+	}
+
+	iter := d.db.IteratePrefix(prefix)
+	defer iter.Release()
+
+	var events []*Event
+	for {
+		next, err := iter.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !next {
+			break
+		}
+
+		var e Event
+		if err := json.Unmarshal(iter.Value(), &e); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		events = append(events, &e)
+	}
+
+	return events, nil
+}
+
+// tx buffers the changes to the store made while adding a batch of links.
+// Reads see the changes not written yet.
+type tx struct {
+	db      db.DB
+	batch   db.Batch
+	pending map[string][]byte
+}
+
+func newTx(store db.DB) *tx {
+	return &tx{
+		db:      store,
+		batch:   store.Batch(),
+		pending: make(map[string][]byte),
+	}
+}
+
+// get decodes the value of a key. It returns false when the key is not
+// found.
+func (t *tx) get(prefix []byte, key string, v interface{}) (bool, error) {
+	k := append(append([]byte{}, prefix...), key...)
+
+	value, ok := t.pending[string(k)]
+	if !ok {
+		var err error
+		value, err = t.db.Get(k)
+		if errors.Cause(err) == db.ErrNotFound {
+			return false, nil
+		}
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+	}
+	if value == nil {
+		return false, nil
+	}
+
+	return true, errors.WithStack(json.Unmarshal(value, v))
+}
+
+// put encodes and sets the value of a key.
+func (t *tx) put(prefix []byte, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	k := append(append([]byte{}, prefix...), key...)
+	t.pending[string(k)] = value
+	t.batch.Put(k, value)
+
+	return nil
+}
+
+// delete removes a key.
+func (t *tx) delete(prefix []byte, key string) {
+	k := append(append([]byte{}, prefix...), key...)
+	t.pending[string(k)] = nil
+	t.batch.Delete(k)
+}
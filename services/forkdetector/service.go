@@ -0,0 +1,134 @@
+package forkdetector
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stratumn/go-node/core/cfg"
+	"github.com/stratumn/go-node/core/db"
+
+	"github.com/stratumn/go-connector/services/livesync"
+)
+
+var log = logrus.WithField("service", "forkdetector")
+
+var (
+	// ErrNotStore is returned when the connected service is not a store.
+	ErrNotStore = errors.New("connected service is not a store")
+
+	// ErrNotSynchronizer is returned when the connected service is not a synchronizer.
+	ErrNotSynchronizer = errors.New("connected service is not a synchronizer")
+)
+
+// Service is the Fork Detector service.
+type Service struct {
+	config *Config
+
+	detector *detector
+}
+
+// Config contains configuration options for the Fork Detector service.
+type Config struct {
+	// ConfigVersion is the version of the configuration file.
+	ConfigVersion int `toml:"configuration_version" comment:"The version of the service configuration."`
+
+	// Store is the service used to store the traces and the events.
+	// The traces missing from the store, e.g. after a restart with an
+	// in-memory store, are rebuilt from the synced links.
+	Store string `toml:"store" comment:"The name of the store service the traces and the detected events are saved to. With an in-memory store, the traces and their events are rebuilt from the synced links on start."`
+}
+
+// ID returns the unique identifier of the service.
+func (s *Service) ID() string {
+	return "forkdetector"
+}
+
+// Name returns the human friendly name of the service.
+func (s *Service) Name() string {
+	return "Fork Detector"
+}
+
+// Desc returns a description of what the service does.
+func (s *Service) Desc() string {
+	return "Detects forks, orphans and out-of-order priorities in the synced traces"
+}
+
+// Config returns the current service configuration or creates one with
+// good default values.
+func (s *Service) Config() interface{} {
+	if s.config != nil {
+		return *s.config
+	}
+
+	return Config{
+		Store: "memorystore",
+	}
+}
+
+// SetConfig configures the service.
+func (s *Service) SetConfig(config interface{}) error {
+	conf := config.(Config)
+	s.config = &conf
+	return nil
+}
+
+// Needs returns the set of services this service depends on.
+func (s *Service) Needs() map[string]struct{} {
+	return map[string]struct{}{
+		s.config.Store: struct{}{},
+		"livesync":     struct{}{},
+	}
+}
+
+// Plug sets the connected services.
+func (s *Service) Plug(exposed map[string]interface{}) error {
+	store, ok := exposed[s.config.Store].(db.DB)
+	if !ok {
+		return errors.Wrap(ErrNotStore, s.config.Store)
+	}
+
+	synchronizer, ok := exposed["livesync"].(livesync.Synchronizer)
+	if !ok {
+		return errors.Wrap(ErrNotSynchronizer, "livesync")
+	}
+
+	s.detector = newDetector(store, synchronizer)
+
+	return nil
+}
+
+// Expose exposes the detector to other services.
+// It exposes the Detector instance.
+func (s *Service) Expose() interface{} {
+	return s.detector
+}
+
+// Run starts the service.
+func (s *Service) Run(ctx context.Context, running, stopping func()) error {
+	running()
+
+	err := s.detector.run(ctx)
+	stopping()
+
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(ctx.Err())
+}
+
+// Migrator methods.
+
+// VersionKey is the version key.
+func (s *Service) VersionKey() string {
+	return "configuration_version"
+}
+
+// Migrations is the services migrations.
+func (s *Service) Migrations() []cfg.MigrateHandler {
+	return []cfg.MigrateHandler{
+		func(tree *cfg.Tree) error {
+			return tree.Set("store", "memorystore")
+		},
+	}
+}
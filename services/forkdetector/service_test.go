@@ -0,0 +1,296 @@
+package forkdetector_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-node/core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stratumn/go-connector/services/forkdetector"
+	"github.com/stratumn/go-connector/services/livesync"
+	"github.com/stratumn/go-connector/services/livesync/mocksynchronizer"
+	"github.com/stratumn/go-connector/services/memorystore/mockmemorystore"
+)
+
+func TestForkDetectorService(t *testing.T) {
+	link := func(priority float64, parent *cs.Link) *cs.Link {
+		b := cs.NewLinkBuilder("p", "map").WithPriority(priority).WithDegree(1)
+		if parent != nil {
+			lh, err := parent.Hash()
+			require.NoError(t, err)
+			b = b.WithParent(lh)
+		}
+		l, err := b.Build()
+		require.NoError(t, err)
+		return l
+	}
+	segment := func(l *cs.Link) *cs.Segment {
+		s, err := l.Segmentify()
+		require.NoError(t, err)
+		return s
+	}
+	hash := func(l *cs.Link) string {
+		lh, err := l.Hash()
+		require.NoError(t, err)
+		return hex.EncodeToString(lh)
+	}
+
+	root := link(1, nil)
+	child := link(2, root)
+	// a second child of the root.
+	sibling := link(2, root)
+	// the priority of the child is skipped.
+	skipped := link(4, child)
+	// the parent of the orphan is synced after it, and the orphan skips a
+	// priority too.
+	parent := link(3, sibling)
+	orphan := link(5, parent)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	store, err := db.NewMemDB(nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	synchronizer := mocksynchronizer.NewMockSynchronizer(ctrl)
+	synchronizer.EXPECT().Unsubscribe(gomock.Any()).Return(nil).AnyTimes()
+	batches := make(chan *livesync.Batch)
+	synchronizer.EXPECT().Subscribe(forkdetector.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
+	// the workflow has no past links.
+	synchronizer.EXPECT().Fetch(gomock.Any(), "1", "").Return(nil, "", nil).Times(1)
+
+	s := &forkdetector.Service{}
+	s.SetConfig(forkdetector.Config{Store: "memorystore"})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"livesync":    synchronizer,
+		"memorystore": store,
+	}))
+	detector := s.Expose().(forkdetector.Detector)
+
+	runCh := make(chan error)
+	go func() { runCh <- s.Run(ctx, func() {}, func() {}) }()
+
+	deliver := func(t *testing.T, links ...*cs.Link) {
+		segments := make([]*cs.Segment, len(links))
+		for i, l := range links {
+			segments[i] = segment(l)
+		}
+		batch, acks := livesync.NewBatch("1", "", segments, 1)
+		batches <- batch
+		require.NoError(t, <-acks)
+	}
+
+	type detected struct {
+		kind     string
+		linkHash string
+	}
+	kinds := func(t *testing.T) []detected {
+		events, err := detector.Events("map")
+		require.NoError(t, err)
+
+		found := make([]detected, len(events))
+		for i, e := range events {
+			assert.Equal(t, "1", e.WorkflowID)
+			assert.Equal(t, "map", e.MapID)
+			found[i] = detected{kind: e.Kind, linkHash: e.LinkHash}
+		}
+		return found
+	}
+
+	t.Run("Detects forks, orphans and out-of-order priorities", func(t *testing.T) {
+		deliver(t, root, child, sibling, skipped, orphan)
+
+		// the sibling follows the priority of its previous link.
+		assert.Equal(t, []detected{
+			{forkdetector.Fork, hash(sibling)},
+			{forkdetector.OutOfOrderPriority, hash(skipped)},
+			{forkdetector.Orphan, hash(orphan)},
+		}, kinds(t))
+
+		events, err := detector.Events("map")
+		require.NoError(t, err)
+		assert.Equal(t, []string{hash(child), hash(sibling)}, events[0].Children)
+		assert.Equal(t, float64(3), events[1].ExpectedPriority)
+	})
+
+	t.Run("Ignores the links delivered again", func(t *testing.T) {
+		deliver(t, root, child, sibling, skipped, orphan)
+		assert.Len(t, kinds(t), 3)
+	})
+
+	t.Run("Adopts the orphans of a link synced late", func(t *testing.T) {
+		deliver(t, parent)
+
+		// the priority of the orphan is checked once its parent is synced.
+		found := kinds(t)
+		require.Len(t, found, 4)
+		assert.Equal(t, detected{forkdetector.OutOfOrderPriority, hash(orphan)}, found[3])
+
+		events, err := detector.Events("map")
+		require.NoError(t, err)
+		assert.Equal(t, hash(parent), events[3].PrevLinkHash)
+		assert.Equal(t, float64(4), events[3].ExpectedPriority)
+
+		// a second child of the late parent is a fork.
+		deliver(t, link(4, parent))
+		found = kinds(t)
+		require.Len(t, found, 5)
+		assert.Equal(t, forkdetector.Fork, found[4].kind)
+	})
+
+	t.Run("Lists the events of all the traces", func(t *testing.T) {
+		events, err := detector.Events("")
+		require.NoError(t, err)
+		assert.Len(t, events, 5)
+
+		events, err = detector.Events("other")
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Saves the sequence number of the events", func(t *testing.T) {
+		// a restarted detector numbers its events after the saved ones.
+		seq, err := store.Get([]byte("forkdetector/seq"))
+		require.NoError(t, err)
+		assert.Equal(t, "5", string(seq))
+	})
+
+	cancel()
+	assert.EqualError(t, <-runCh, context.Canceled.Error())
+}
+
+func TestForkDetectorService_StoreFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	synchronizer := mocksynchronizer.NewMockSynchronizer(ctrl)
+	synchronizer.EXPECT().Unsubscribe(gomock.Any()).Return(nil).AnyTimes()
+	batches := make(chan *livesync.Batch)
+	synchronizer.EXPECT().Subscribe(forkdetector.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
+	synchronizer.EXPECT().Fetch(gomock.Any(), "1", "").Return(nil, "", nil).Times(1)
+
+	memDB, err := db.NewMemDB(nil)
+	require.NoError(t, err)
+	store := mockmemorystore.NewMockDB(ctrl)
+	store.EXPECT().Get(gomock.Any()).Return(nil, db.ErrNotFound).AnyTimes()
+	// the workflow mark is read before the batch is written.
+	store.EXPECT().Batch().Return(memDB.Batch()).Times(2)
+	store.EXPECT().Write(gomock.Any()).Return(errors.New("disk full")).Times(1)
+
+	s := &forkdetector.Service{}
+	s.SetConfig(forkdetector.Config{Store: "memorystore"})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"livesync":    synchronizer,
+		"memorystore": store,
+	}))
+
+	runCh := make(chan error)
+	go func() { runCh <- s.Run(ctx, func() {}, func() {}) }()
+
+	l, err := cs.NewLinkBuilder("p", "map").WithPriority(1).Build()
+	require.NoError(t, err)
+	seg, err := l.Segmentify()
+	require.NoError(t, err)
+
+	// the batch is not acknowledged so that livesync delivers it again.
+	batch, acks := livesync.NewBatch("1", "", []*cs.Segment{seg}, 1)
+	batches <- batch
+	assert.EqualError(t, <-acks, "disk full")
+
+	cancel()
+	assert.EqualError(t, <-runCh, context.Canceled.Error())
+}
+
+func TestForkDetectorService_Rebuild(t *testing.T) {
+	link := func(priority float64, parent *cs.Link) *cs.Link {
+		b := cs.NewLinkBuilder("p", "map").WithPriority(priority)
+		if parent != nil {
+			lh, err := parent.Hash()
+			require.NoError(t, err)
+			b = b.WithParent(lh)
+		}
+		l, err := b.Build()
+		require.NoError(t, err)
+		return l
+	}
+	segments := func(links ...*cs.Link) []*cs.Segment {
+		segments := make([]*cs.Segment, len(links))
+		for i, l := range links {
+			s, err := l.Segmentify()
+			require.NoError(t, err)
+			segments[i] = s
+		}
+		return segments
+	}
+
+	// the links synced before the restart are only in the trace.
+	root := link(1, nil)
+	child := link(2, root)
+	grandchild := link(3, child)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	store, err := db.NewMemDB(nil)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	synchronizer := mocksynchronizer.NewMockSynchronizer(ctrl)
+	synchronizer.EXPECT().Unsubscribe(gomock.Any()).Return(nil).AnyTimes()
+	batches := make(chan *livesync.Batch)
+	synchronizer.EXPECT().Subscribe(forkdetector.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
+	// the workflow is only fetched again before the first batch.
+	synchronizer.EXPECT().Fetch(gomock.Any(), "1", "").Return(segments(root, child), "cursor", nil).Times(1)
+	synchronizer.EXPECT().Fetch(gomock.Any(), "1", "cursor").Return(nil, "cursor", nil).Times(1)
+
+	s := &forkdetector.Service{}
+	s.SetConfig(forkdetector.Config{Store: "memorystore"})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"livesync":    synchronizer,
+		"memorystore": store,
+	}))
+	detector := s.Expose().(forkdetector.Detector)
+
+	runCh := make(chan error)
+	go func() { runCh <- s.Run(ctx, func() {}, func() {}) }()
+
+	// the subscription resumes after the links synced before the restart.
+	for _, l := range []*cs.Link{grandchild, link(4, grandchild)} {
+		batch, acks := livesync.NewBatch("1", "", segments(l), 1)
+		batches <- batch
+		require.NoError(t, <-acks)
+	}
+
+	events, err := detector.Events("map")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	cancel()
+	assert.EqualError(t, <-runCh, context.Canceled.Error())
+}
+
+func TestForkDetectorService_Plug(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	s := &forkdetector.Service{}
+	s.SetConfig(forkdetector.Config{Store: "memorystore"})
+
+	err := s.Plug(map[string]interface{}{
+		"livesync": mocksynchronizer.NewMockSynchronizer(ctrl),
+	})
+	assert.Equal(t, forkdetector.ErrNotStore, errors.Cause(err))
+
+	err = s.Plug(map[string]interface{}{
+		"memorystore": mockmemorystore.NewMockDB(ctrl),
+	})
+	assert.Equal(t, forkdetector.ErrNotSynchronizer, errors.Cause(err))
+}
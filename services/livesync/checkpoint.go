@@ -0,0 +1,244 @@
+package livesync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// CheckpointVersion is the version of the checkpoint file format.
+const CheckpointVersion = 1
+
+var (
+	// ErrCorruptedCheckpoint is returned when a checkpoint cannot be decoded
+	// or does not match its checksum.
+	ErrCorruptedCheckpoint = errors.New("the livesync checkpoint is corrupted")
+
+	// ErrUnsupportedCheckpoint is returned when a checkpoint was written with
+	// an unknown version of the file format.
+	ErrUnsupportedCheckpoint = errors.New("the livesync checkpoint version is not supported")
+)
+
+// Checkpointer persists the cursors of the synced workflows so that the
+// synchronization resumes where it stopped after a restart.
+type Checkpointer interface {
+	// Load returns the saved cursors. It returns no state when nothing was
+	// saved yet.
+	Load() (WorkflowStates, error)
+
+	// Save replaces the saved cursors.
+	Save(WorkflowStates) error
+
+	// LoadSubscriptions returns the acknowledged cursors of the
+	// subscriptions, by subscription name.
+	LoadSubscriptions() (map[string]WorkflowStates, error)
+
+	// SaveSubscription replaces the acknowledged cursors of a subscription.
+	SaveSubscription(name string, states WorkflowStates) error
+}
+
+// checkpoint is the content of a checkpoint file.
+type checkpoint struct {
+	Version int `json:"version"`
+	// Checksum is the hex encoded SHA-256 of the JSON encoded workflows and
+	// subscriptions.
+	Checksum      string                         `json:"checksum"`
+	Workflows     []checkpointedState            `json:"workflows"`
+	Subscriptions map[string][]checkpointedState `json:"subscriptions,omitempty"`
+}
+
+type checkpointedState struct {
+	ID     string `json:"id"`
+	Cursor string `json:"cursor"`
+}
+
+func (cp *checkpoint) checksum() (string, error) {
+	b, err := json.Marshal([]interface{}{cp.Workflows, cp.Subscriptions})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func toCheckpointed(states WorkflowStates) []checkpointedState {
+	saved := make([]checkpointedState, len(states))
+	for i, w := range states {
+		saved[i] = checkpointedState{ID: w.ID, Cursor: w.Cursor}
+	}
+	return saved
+}
+
+func fromCheckpointed(saved []checkpointedState) WorkflowStates {
+	states := make(WorkflowStates, len(saved))
+	for i, w := range saved {
+		states[i] = &WorkflowState{ID: w.ID, Cursor: w.Cursor}
+	}
+	return states
+}
+
+type fileCheckpointer struct {
+	path string
+
+	// mu protects the last loaded or saved checkpoint, which is completed
+	// and written by each save.
+	mu sync.Mutex
+	cp *checkpoint
+}
+
+// NewFileCheckpointer returns a Checkpointer saving the cursors in a JSON
+// file.
+func NewFileCheckpointer(path string) Checkpointer {
+	return &fileCheckpointer{path: path}
+}
+
+// Load reads the checkpoint file.
+func (c *fileCheckpointer) Load() (WorkflowStates, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	c.cp = cp
+
+	if len(cp.Workflows) == 0 {
+		return nil, nil
+	}
+	return fromCheckpointed(cp.Workflows), nil
+}
+
+// LoadSubscriptions reads the subscriptions from the checkpoint file.
+func (c *fileCheckpointer) LoadSubscriptions() (map[string]WorkflowStates, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	c.cp = cp
+
+	subscriptions := make(map[string]WorkflowStates, len(cp.Subscriptions))
+	for name, saved := range cp.Subscriptions {
+		subscriptions[name] = fromCheckpointed(saved)
+	}
+	return subscriptions, nil
+}
+
+// Save writes the cursors of the workflows to the checkpoint file.
+func (c *fileCheckpointer) Save(states WorkflowStates) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp := c.current()
+	cp.Workflows = toCheckpointed(states)
+	return c.write(cp)
+}
+
+// SaveSubscription writes the cursors of a subscription to the checkpoint
+// file.
+func (c *fileCheckpointer) SaveSubscription(name string, states WorkflowStates) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp := c.current()
+	if cp.Subscriptions == nil {
+		cp.Subscriptions = make(map[string][]checkpointedState)
+	}
+	cp.Subscriptions[name] = toCheckpointed(states)
+	return c.write(cp)
+}
+
+// current returns the checkpoint to update.
+// The file is read when it was neither loaded nor saved yet so that a save
+// does not discard the other cursors. It must be called with the lock held.
+func (c *fileCheckpointer) current() *checkpoint {
+	if c.cp == nil {
+		cp, err := c.read()
+		if err != nil {
+			log.Warnf("overwriting the livesync checkpoint: %s", err)
+			cp = &checkpoint{}
+		}
+		c.cp = cp
+	}
+	return c.cp
+}
+
+// read decodes the checkpoint file. It returns an empty checkpoint when the
+// file does not exist.
+func (c *fileCheckpointer) read() (*checkpoint, error) {
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return &checkpoint{}, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, errors.Wrap(ErrCorruptedCheckpoint, err.Error())
+	}
+
+	if cp.Version != CheckpointVersion {
+		return nil, errors.Wrapf(ErrUnsupportedCheckpoint, "version %d", cp.Version)
+	}
+
+	sum, err := cp.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if sum != cp.Checksum {
+		return nil, errors.Wrap(ErrCorruptedCheckpoint, "checksum mismatch")
+	}
+
+	return &cp, nil
+}
+
+// write writes the checkpoint file in the current version of the format.
+// The file is written to a temporary file first and then renamed so that a
+// crash never leaves a partially written checkpoint.
+func (c *fileCheckpointer) write(cp *checkpoint) error {
+	cp.Version = CheckpointVersion
+	if cp.Workflows == nil {
+		cp.Workflows = []checkpointedState{}
+	}
+
+	var err error
+	if cp.Checksum, err = cp.checksum(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(f.Name(), c.path))
+}
@@ -0,0 +1,411 @@
+package livesync
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// DefaultQueueSize is the default number of updates queued per listener.
+const DefaultQueueSize = 100
+
+// Overflow policies applied when the queue of a listener is full.
+const (
+	// Block waits for the listener to consume an update, which stalls the
+	// polling of all the workflows.
+	Block = "block"
+
+	// DropOldest discards the oldest queued update to make room for the new
+	// one. The listener misses the discarded segments.
+	DropOldest = "drop-oldest"
+
+	// Disconnect closes the channel of the listener.
+	Disconnect = "disconnect"
+)
+
+var (
+	// ErrBadOverflowPolicy is returned when the overflow policy is unknown.
+	ErrBadOverflowPolicy = errors.New("the overflow policy must be one of block, drop-oldest or disconnect")
+)
+
+// Delivery configures how updates are delivered to the listeners.
+// Each listener has its own bounded queue consumed by a dedicated goroutine
+// so that a slow listener does not delay the others.
+type Delivery struct {
+	// QueueSize is the maximum number of updates queued per listener.
+	QueueSize int
+
+	// OverflowPolicy is applied when the queue of a listener is full.
+	// Subscriptions have no queue: they fetch the links from their own
+	// cursors.
+	OverflowPolicy string
+
+	// MaxDeliveryAttempts is the number of times a batch is delivered to a
+	// subscription before it is moved to the dead letters.
+	MaxDeliveryAttempts int
+
+	// RedeliveryDelay is the time waited before delivering again a batch that
+	// was not acknowledged.
+	RedeliveryDelay time.Duration
+
+	// DeadLetters stores the batches that subscriptions failed to process.
+	// The batches are discarded when it is nil.
+	DeadLetters DeadLetterStore
+}
+
+// Validate checks that the delivery configuration is valid.
+func (d Delivery) Validate() error {
+	switch d.OverflowPolicy {
+	case "", Block, DropOldest, Disconnect:
+		return nil
+	default:
+		return errors.Wrap(ErrBadOverflowPolicy, d.OverflowPolicy)
+	}
+}
+
+var (
+	listenerKey, _ = tag.NewKey("listener")
+
+	queueDepth = stats.Int64(
+		"stratumn-connector/livesync/queue-depth",
+		"number of updates waiting to be consumed by a listener",
+		stats.UnitDimensionless,
+	)
+
+	droppedUpdates = stats.Int64(
+		"stratumn-connector/livesync/dropped-updates",
+		"number of updates dropped because a listener queue was full",
+		stats.UnitDimensionless,
+	)
+
+	// Views of the livesync metrics, registered when the service runs.
+	Views = []*view.View{
+		{
+			Name:        "stratumn-connector/views/livesync/queue-depth",
+			Description: "number of updates waiting to be consumed by a listener",
+			Measure:     queueDepth,
+			TagKeys:     []tag.Key{listenerKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/dropped-updates",
+			Description: "number of updates dropped because a listener queue was full",
+			Measure:     droppedUpdates,
+			TagKeys:     []tag.Key{listenerKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/listener-lag",
+			Description: "number of synced links not delivered to a listener yet",
+			Measure:     listenerLag,
+			TagKeys:     []tag.Key{listenerKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/workflow-links",
+			Description: "number of synced links of a workflow",
+			Measure:     workflowLinks,
+			TagKeys:     []tag.Key{workflowKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/workflow-lag",
+			Description: "number of links of a workflow that are not synced yet",
+			Measure:     workflowLag,
+			TagKeys:     []tag.Key{workflowKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/last-poll",
+			Description: "unix time of the last successful poll of a workflow",
+			Measure:     lastPoll,
+			TagKeys:     []tag.Key{workflowKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/poll-failures",
+			Description: "number of failed polls of a workflow",
+			Measure:     pollFailures,
+			TagKeys:     []tag.Key{workflowKey},
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "stratumn-connector/views/livesync/integrity-alerts",
+			Description: "number of synced links that failed the integrity verification",
+			Measure:     integrityAlerts,
+			TagKeys:     []tag.Key{workflowKey},
+			Aggregation: view.Sum(),
+		},
+	}
+)
+
+var listenerCount uint64
+
+type listener struct {
+	id     string
+	states WorkflowStates
+	filter *Filter
+	// all is set when the listener subscribed to all the workflows.
+	all bool
+
+	// listener is the channel returned to the subscriber.
+	listener chan []*cs.Segment
+	// sub is set instead of listener for subscriptions, which do not use the
+	// queue.
+	sub *subscription
+	// key is the channel returned to the subscriber, indexing the listener.
+	key interface{}
+
+	// queue buffers the updates not consumed yet.
+	queue  chan *Batch
+	policy string
+
+	// done is closed when the listener unregisters.
+	done     chan struct{}
+	stopOnce sync.Once
+	// closing is closed when the listener stops receiving updates. The
+	// queue itself is never closed since updates may be queued concurrently.
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// ctx is tagged with the ID of the listener for metrics.
+	ctx context.Context
+	// dropped counts the updates dropped because the queue was full.
+	dropped uint64
+
+	// progressMu protects the delivery progress of the workflows.
+	progressMu sync.Mutex
+	progress   map[string]*progress
+}
+
+// progress tracks the consumption of the updates of a workflow by a
+// listener.
+type progress struct {
+	// queued is the number of updates queued and not consumed yet.
+	queued int
+	// consumed is the cursor of the last consumed update.
+	consumed string
+}
+
+// newListener creates a listener and starts its delivery.
+func newListener(states WorkflowStates, filter *Filter, d Delivery) *listener {
+	l := makeListener(states, filter, d)
+	l.listener = make(chan []*cs.Segment)
+	l.key = (<-chan []*cs.Segment)(l.listener)
+
+	go l.deliver()
+
+	return l
+}
+
+// queueSize returns the number of updates queued per listener.
+func (d Delivery) queueSize() int {
+	if d.QueueSize <= 0 {
+		return DefaultQueueSize
+	}
+	return d.QueueSize
+}
+
+// makeListener creates a listener without a subscriber channel.
+func makeListener(states WorkflowStates, filter *Filter, d Delivery) *listener {
+	size := d.queueSize()
+
+	policy := d.OverflowPolicy
+	if policy == "" {
+		policy = Block
+	}
+
+	id := strconv.FormatUint(atomic.AddUint64(&listenerCount, 1), 10)
+	ctx, _ := tag.New(context.Background(), tag.Insert(listenerKey, id))
+
+	return &listener{
+		id:       id,
+		states:   states,
+		filter:   filter,
+		queue:    make(chan *Batch, size),
+		policy:   policy,
+		done:     make(chan struct{}),
+		closing:  make(chan struct{}),
+		ctx:      ctx,
+		progress: make(map[string]*progress),
+	}
+}
+
+// deliver forwards the queued updates to the subscriber.
+// It closes the subscriber channel once the listener is closed and the queue
+// drained, or as soon as the listener unregisters.
+func (l *listener) deliver() {
+	defer close(l.listener)
+
+	for {
+		select {
+		case b := <-l.queue:
+			if !l.forward(b) {
+				return
+			}
+		case <-l.closing:
+			// deliver the updates queued before the listener closed.
+			for {
+				select {
+				case b := <-l.queue:
+					if !l.forward(b) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// forward sends a queued update to the subscriber.
+// It returns false when the listener unregistered.
+func (l *listener) forward(b *Batch) bool {
+	stats.Record(l.ctx, queueDepth.M(int64(len(l.queue))))
+
+	select {
+	case l.listener <- b.Segments:
+		l.consumed(b)
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// enqueue queues an update according to the overflow policy.
+// from is the cursor of the listener before the update.
+// It returns false when the update was not queued: the listener must then be
+// disconnected or unregistered, unless the synchronizer stops.
+// A blocked update is given up when the listener closes or the synchronizer
+// stops.
+func (l *listener) enqueue(ctx context.Context, from string, b *Batch) bool {
+	defer func() { stats.Record(l.ctx, queueDepth.M(int64(len(l.queue)))) }()
+
+	l.track(b.WorkflowID, from)
+	if l.send(ctx, b) {
+		return true
+	}
+
+	l.untrack(b.WorkflowID)
+	return false
+}
+
+// send sends an update to the queue according to the overflow policy.
+func (l *listener) send(ctx context.Context, b *Batch) bool {
+	switch l.policy {
+	case DropOldest:
+		for {
+			select {
+			case l.queue <- b:
+				return true
+			default:
+			}
+
+			// The queue is full, make room for the update.
+			select {
+			case old := <-l.queue:
+				l.drop()
+				// the dropped update will never be consumed.
+				l.consumed(old)
+			default:
+			}
+		}
+	case Disconnect:
+		select {
+		case l.queue <- b:
+			return true
+		default:
+			l.drop()
+			return false
+		}
+	default:
+		select {
+		case l.queue <- b:
+			return true
+		case <-l.done:
+			return false
+		case <-l.closing:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// track records an update about to be queued.
+func (l *listener) track(workflowID, from string) {
+	l.progressMu.Lock()
+	defer l.progressMu.Unlock()
+
+	p, ok := l.progress[workflowID]
+	if !ok {
+		p = &progress{}
+		l.progress[workflowID] = p
+	}
+	if p.queued == 0 {
+		p.consumed = from
+	}
+	p.queued++
+}
+
+// untrack records an update that could not be queued.
+func (l *listener) untrack(workflowID string) {
+	l.progressMu.Lock()
+	defer l.progressMu.Unlock()
+
+	if p, ok := l.progress[workflowID]; ok && p.queued > 0 {
+		p.queued--
+	}
+}
+
+// consumed records an update consumed by the listener.
+func (l *listener) consumed(b *Batch) {
+	l.progressMu.Lock()
+	defer l.progressMu.Unlock()
+
+	if p, ok := l.progress[b.WorkflowID]; ok && p.queued > 0 {
+		p.queued--
+		p.consumed = b.Cursor
+	}
+}
+
+// consumedCursor returns the cursor up to which the listener consumed the
+// updates of a workflow, given its cursor.
+// The cursor of the listener is returned when no update is waiting to be
+// consumed.
+func (l *listener) consumedCursor(workflowID, cursor string) string {
+	l.progressMu.Lock()
+	defer l.progressMu.Unlock()
+
+	if p, ok := l.progress[workflowID]; ok && p.queued > 0 {
+		return p.consumed
+	}
+	return cursor
+}
+
+// drop records an update dropped because the queue was full.
+func (l *listener) drop() {
+	atomic.AddUint64(&l.dropped, 1)
+	stats.Record(l.ctx, droppedUpdates.M(1))
+}
+
+// close stops the delivery once the queued updates are consumed.
+func (l *listener) close() {
+	l.closeOnce.Do(func() { close(l.closing) })
+}
+
+// stop stops the delivery immediately, discarding the queued updates.
+func (l *listener) stop() {
+	l.stopOnce.Do(func() { close(l.done) })
+}
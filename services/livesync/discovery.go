@@ -0,0 +1,158 @@
+package livesync
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDiscoveryInterval is the default interval at which the workflows of the account are discovered (in milliseconds).
+const DefaultDiscoveryInterval = 60000
+
+var (
+	// ErrBadWorkflowPattern is returned when a workflow pattern is malformed.
+	ErrBadWorkflowPattern = errors.New("the workflow pattern is malformed")
+)
+
+// discoveryQuery is the query sent to list the workflows the account of the
+// connector has access to.
+const discoveryQuery = `query discoverWorkflows {
+	allWorkflows {
+	  nodes {
+		rowId
+		name
+	  }
+	}
+  }`
+
+type discoveryRsp struct {
+	AllWorkflows struct {
+		Nodes []struct {
+			RowID string
+			Name  string
+		}
+	}
+}
+
+// Discovery selects the discovered workflows to sync.
+// Patterns use the shell glob syntax (see path.Match) and are matched
+// against both the ID and the name of the workflows.
+type Discovery struct {
+	// Include selects the workflows to sync. All the workflows are selected
+	// when it is empty.
+	Include []string
+
+	// Exclude rejects workflows selected by Include.
+	Exclude []string
+}
+
+// Validate checks that the patterns are well formed.
+func (d Discovery) Validate() error {
+	for _, patterns := range [][]string{d.Include, d.Exclude} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return errors.Wrap(ErrBadWorkflowPattern, p)
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns whether a workflow is selected.
+func (d Discovery) Match(id, name string) bool {
+	if len(d.Include) > 0 && !matchPattern(d.Include, id, name) {
+		return false
+	}
+	return !matchPattern(d.Exclude, id, name)
+}
+
+// matchPattern returns whether the ID or the name of a workflow matches one
+// of the patterns.
+func matchPattern(patterns []string, id, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, id); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// discover lists the workflows of the account and starts syncing the ones
+// selected by the patterns.
+// It returns the IDs of the new workflows.
+func (s *synchronizer) discover(ctx context.Context, d Discovery) ([]string, error) {
+	rsp := discoveryRsp{}
+	if err := s.client.CallTraceGql(ctx, discoveryQuery, nil, &rsp); err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, w := range rsp.AllWorkflows.Nodes {
+		if d.Match(w.RowID, w.Name) {
+			selected = append(selected, w.RowID)
+		}
+	}
+
+	return s.watch(selected), nil
+}
+
+// watch starts syncing workflows that are not synced yet.
+// The listeners registered for all the workflows are subscribed to them.
+// It returns the IDs of the new workflows.
+func (s *synchronizer) watch(workflowIDs []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []string
+	for _, id := range workflowIDs {
+		if _, ok := s.workflowStates.Get(id); ok {
+			continue
+		}
+
+		// resume from the checkpoint when the workflow was synced before.
+		w := &WorkflowState{ID: id}
+		if saved, ok := s.saved.Get(id); ok {
+			w.Cursor = saved.Cursor
+		}
+		s.workflowStates = append(s.workflowStates, w)
+
+		for _, l := range s.registeredServices {
+			if !l.all {
+				continue
+			}
+
+			l.states = append(l.states, &WorkflowState{ID: id, Cursor: w.Cursor})
+		}
+
+		added = append(added, id)
+	}
+
+	return added
+}
+
+// runDiscovery discovers workflows until the context is done.
+// Failures are logged and the discovery is tried again at the next interval.
+func (s *synchronizer) runDiscovery(ctx context.Context, d Discovery, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		added, err := s.discover(ctx, d)
+		if err != nil {
+			log.Errorf("could not discover workflows: %s", err)
+		} else if len(added) > 0 {
+			log.Infof("Discovered workflows %v", added)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
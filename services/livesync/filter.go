@@ -0,0 +1,129 @@
+package livesync
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cs "github.com/stratumn/go-chainscript"
+)
+
+// Filter selects the segments delivered to a listener.
+// A segment is delivered when it matches all the criteria that are set.
+// A criterion listing values matches when the segment has one of them.
+// A nil filter delivers all the segments.
+type Filter struct {
+	// Workflows are the IDs of the workflows (the process name of the links).
+	Workflows []string `json:"workflows,omitempty"`
+
+	// Actions are the values of meta.action.
+	Actions []string `json:"actions,omitempty"`
+
+	// ProcessStates are the values of meta.process.state.
+	ProcessStates []string `json:"processStates,omitempty"`
+
+	// Tags are the values of meta.tags. A segment matches when it has at
+	// least one of them.
+	Tags []string `json:"tags,omitempty"`
+
+	// MapIDs are the values of meta.mapId.
+	MapIDs []string `json:"mapIds,omitempty"`
+
+	// Metadata is a predicate on the link metadata (the unmarshaled
+	// meta.data). Segments whose metadata is not a JSON object do not match.
+	Metadata func(metadata map[string]interface{}) bool `json:"-"`
+}
+
+// MetadataEquals returns a metadata predicate matching the links whose
+// metadata have the given values.
+// Values are compared to the string representation of the metadata values.
+func MetadataEquals(values map[string]string) func(map[string]interface{}) bool {
+	return func(metadata map[string]interface{}) bool {
+		for k, v := range values {
+			mv, ok := metadata[k]
+			if !ok || fmt.Sprint(mv) != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Match returns whether the filter selects a segment.
+func (f *Filter) Match(s *cs.Segment) bool {
+	if f == nil {
+		return true
+	}
+	if s == nil || s.Link == nil || s.Link.Meta == nil {
+		return false
+	}
+
+	meta := s.Link.Meta
+
+	var processName, processState string
+	if meta.Process != nil {
+		processName = meta.Process.Name
+		processState = meta.Process.State
+	}
+
+	if !matchOne(f.Workflows, processName) ||
+		!matchOne(f.Actions, meta.Action) ||
+		!matchOne(f.ProcessStates, processState) ||
+		!matchOne(f.MapIDs, meta.MapId) ||
+		!matchAny(f.Tags, meta.Tags) {
+		return false
+	}
+
+	if f.Metadata != nil {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal(meta.Data, &metadata); err != nil || metadata == nil {
+			return false
+		}
+		if !f.Metadata(metadata) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply returns the segments selected by the filter.
+func (f *Filter) Apply(segments []*cs.Segment) []*cs.Segment {
+	if f == nil {
+		return segments
+	}
+
+	var selected []*cs.Segment
+	for _, s := range segments {
+		if f.Match(s) {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+// matchOne returns whether a value is one of the accepted values.
+// Any value is accepted when there is none.
+func matchOne(accepted []string, value string) bool {
+	if len(accepted) == 0 {
+		return true
+	}
+	for _, a := range accepted {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny returns whether one of the values is accepted.
+func matchAny(accepted []string, values []string) bool {
+	if len(accepted) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if matchOne(accepted, v) {
+			return true
+		}
+	}
+	return false
+}
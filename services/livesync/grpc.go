@@ -0,0 +1,237 @@
+package livesync
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/stratumn/go-connector/services/livesync/grpc"
+)
+
+//go:generate protoc --proto_path=$GOPATH/src --go_out=plugins=grpc:$GOPATH/src github.com/stratumn/go-connector/services/livesync/grpc/livesync.proto
+
+var (
+	// ErrUnavailable is returned from gRPC methods when the service is not
+	// available.
+	ErrUnavailable = errors.New("the service is not available")
+)
+
+// AddToGRPCServer adds the service to a gRPC server.
+func (s *Service) AddToGRPCServer(gs *grpc.Server) {
+	pb.RegisterLivesyncServer(gs, grpcServer{
+		GetSynchronizer: func() Synchronizer {
+			if s.synchronizer == nil {
+				return nil
+			}
+			return s.synchronizer
+		},
+		Authenticate: func(ctx context.Context) error {
+			return s.grpcAuth.Authenticate(ctx)
+		},
+	})
+}
+
+// grpcServer is a gRPC server for the livesync service.
+type grpcServer struct {
+	GetSynchronizer func() Synchronizer
+	Authenticate    func(context.Context) error
+}
+
+// Sync streams the segments synced from Stratumn APIs.
+// Only the synced workflows can be streamed. The links preceding the current
+// cursors of the workflows are fetched for this stream only and sent first.
+// The stream ends when the client goes away or the service stops.
+func (s grpcServer) Sync(req *pb.SyncRequest, ss pb.Livesync_SyncServer) error {
+	synchronizer := s.GetSynchronizer()
+	if synchronizer == nil {
+		return status.Error(codes.Unavailable, ErrUnavailable.Error())
+	}
+	if err := s.Authenticate(ss.Context()); err != nil {
+		return err
+	}
+
+	// A nil WorkflowStates subscribes to all the synced workflows.
+	var states WorkflowStates
+	for _, w := range req.Workflows {
+		states = append(states, &WorkflowState{ID: w.Id, Cursor: w.Cursor})
+	}
+
+	send := func(segments []*cs.Segment) error {
+		msg, err := toProtoSegments(segments)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return ss.Send(msg)
+	}
+
+	ch, err := synchronizer.Follow(ss.Context(), states, toFilter(req), send)
+	if err != nil {
+		// the errors of send are returned as is.
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		switch errors.Cause(err) {
+		case ErrUnknownWorkflow:
+			return status.Error(codes.NotFound, err.Error())
+		case ErrBadCursor:
+			return status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return status.Error(codes.Unavailable, err.Error())
+		}
+	}
+
+	// Stop the updates when the client goes away so that the listener does
+	// not block the synchronizer.
+	defer synchronizer.Unregister(ch)
+
+	for {
+		select {
+		case segments, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			if err := send(segments); err != nil {
+				return err
+			}
+		case <-ss.Context().Done():
+			return ss.Context().Err()
+		}
+	}
+}
+
+// Status returns the status of the synced workflows and of the listeners.
+func (s grpcServer) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	synchronizer := s.GetSynchronizer()
+	if synchronizer == nil {
+		return nil, status.Error(codes.Unavailable, ErrUnavailable.Error())
+	}
+	if err := s.Authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	return toProtoStatus(synchronizer.Status()), nil
+}
+
+// Replay delivers the past links of a workflow to a single subscription.
+// It returns once the replayed links are processed.
+func (s grpcServer) Replay(ctx context.Context, req *pb.ReplayRequest) (*pb.ReplayResponse, error) {
+	synchronizer := s.GetSynchronizer()
+	if synchronizer == nil {
+		return nil, status.Error(codes.Unavailable, ErrUnavailable.Error())
+	}
+	if err := s.Authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	from := ReplayFrom{Cursor: req.Cursor, LinkHash: req.LinkHash}
+	if req.Since != 0 {
+		from.Time = time.Unix(0, req.Since*int64(time.Millisecond))
+	}
+
+	links, err := synchronizer.Replay(ctx, req.Subscription, req.WorkflowId, from)
+	switch errors.Cause(err) {
+	case nil:
+		return &pb.ReplayResponse{Links: uint64(links)}, nil
+	case ErrBadReplayStart:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case ErrUnknownSubscription, ErrReplayStartNotFound:
+		return nil, status.Error(codes.NotFound, err.Error())
+	case context.Canceled:
+		return nil, status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return nil, status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+}
+
+// toFilter returns the filter of a sync request, or nil when it has no
+// criterion.
+func toFilter(req *pb.SyncRequest) *Filter {
+	if len(req.Actions) == 0 && len(req.ProcessStates) == 0 && len(req.Tags) == 0 && len(req.MapIds) == 0 && len(req.Metadata) == 0 {
+		return nil
+	}
+
+	filter := &Filter{
+		Actions:       req.Actions,
+		ProcessStates: req.ProcessStates,
+		Tags:          req.Tags,
+		MapIDs:        req.MapIds,
+	}
+
+	if len(req.Metadata) > 0 {
+		values := make(map[string]string, len(req.Metadata))
+		for _, c := range req.Metadata {
+			values[c.Key] = c.Value
+		}
+		filter.Metadata = MetadataEquals(values)
+	}
+
+	return filter
+}
+
+func toProtoSegments(segments []*cs.Segment) (*pb.Segments, error) {
+	msg := &pb.Segments{Segments: make([]*pb.Segment, len(segments))}
+	for i, s := range segments {
+		raw, err := json.Marshal(s)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		var linkHash []byte
+		if s.Meta != nil {
+			linkHash = s.Meta.LinkHash
+		}
+
+		msg.Segments[i] = &pb.Segment{LinkHash: linkHash, Raw: string(raw)}
+	}
+
+	return msg, nil
+}
+
+func toProtoStatus(st *Status) *pb.StatusResponse {
+	rsp := &pb.StatusResponse{
+		Workflows: make([]*pb.WorkflowStatus, len(st.Workflows)),
+		Listeners: make([]*pb.ListenerStatus, len(st.Listeners)),
+	}
+
+	for i, w := range st.Workflows {
+		var lastPoll int64
+		if !w.LastPoll.IsZero() {
+			lastPoll = w.LastPoll.UnixNano() / int64(time.Millisecond)
+		}
+
+		rsp.Workflows[i] = &pb.WorkflowStatus{
+			Id:              w.ID,
+			Cursor:          w.Cursor,
+			LastPoll:        lastPoll,
+			LastError:       w.LastError,
+			Failures:        uint32(w.Failures),
+			Links:           w.Links,
+			Lag:             w.Lag,
+			IntegrityAlerts: w.IntegrityAlerts,
+		}
+	}
+
+	for i, l := range st.Listeners {
+		ls := &pb.ListenerStatus{
+			Id:        l.ID,
+			Workflows: make([]*pb.ListenerWorkflowStatus, len(l.Workflows)),
+			Queued:    uint32(l.Queued),
+			Dropped:   l.Dropped,
+		}
+		for j, w := range l.Workflows {
+			ls.Workflows[j] = &pb.ListenerWorkflowStatus{Id: w.ID, Cursor: w.Cursor, Lag: w.Lag}
+		}
+		rsp.Listeners[i] = ls
+	}
+
+	return rsp
+}
@@ -0,0 +1,35 @@
+package livesync
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
+)
+
+type handler struct {
+	synchronizer Synchronizer
+}
+
+// NewHandler returns an HTTP handler serving the status endpoint.
+// GET /status returns the Status of the synchronizer.
+// Every route is guarded by the authentication middleware.
+func NewHandler(synchronizer Synchronizer, m auth.Middleware) http.Handler {
+	h := &handler{synchronizer: synchronizer}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", m.WithAuth(h.status))
+
+	return mux
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpapi.WriteError(w, http.StatusMethodNotAllowed, errors.New("status requests must use the GET method"))
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, h.synchronizer.Status())
+}
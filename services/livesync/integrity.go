@@ -0,0 +1,279 @@
+package livesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Integrity policies applied when a synced link fails the verification.
+const (
+	// Quarantine withholds the link from the listeners.
+	Quarantine = "quarantine"
+
+	// PassThrough delivers the link to the listeners anyway.
+	PassThrough = "pass-through"
+)
+
+// maxIntegrityAlerts is the number of recent alerts reported by the status.
+const maxIntegrityAlerts = 100
+
+// maxSeenLinks is the number of verified links remembered to check the
+// previous link of the following ones. The oldest ones are forgotten first.
+const maxSeenLinks = 100000
+
+var (
+	// ErrBadIntegrityPolicy is returned when the integrity policy is unknown.
+	ErrBadIntegrityPolicy = errors.New("the integrity policy must be one of quarantine or pass-through")
+
+	// ErrMissingLink is reported when the API returned an edge without link.
+	ErrMissingLink = errors.New("the link is missing")
+
+	// ErrLinkHashMismatch is reported when the hash of a link differs from
+	// the link hash returned by the API.
+	ErrLinkHashMismatch = errors.New("the link hash does not match the link")
+
+	// ErrBadSignature is reported when a signature of a link is invalid.
+	ErrBadSignature = errors.New("a signature of the link is invalid")
+
+	// ErrUnknownPrevLink is reported when the previous link of a link was not
+	// synced before it in the same map.
+	ErrUnknownPrevLink = errors.New("the previous link was not synced before the link")
+)
+
+// Integrity configures the verification of the synced links before they are
+// delivered to the listeners.
+type Integrity struct {
+	// Verify enables the verification.
+	Verify bool
+
+	// Policy is applied to the links failing the verification.
+	Policy string
+}
+
+// Validate checks that the integrity configuration is valid.
+func (i Integrity) Validate() error {
+	switch i.Policy {
+	case "", Quarantine, PassThrough:
+		return nil
+	default:
+		return errors.Wrap(ErrBadIntegrityPolicy, i.Policy)
+	}
+}
+
+// IntegrityAlert reports a synced link that failed the verification.
+type IntegrityAlert struct {
+	Time       time.Time `json:"time"`
+	WorkflowID string    `json:"workflowId"`
+	MapID      string    `json:"mapId,omitempty"`
+	LinkHash   string    `json:"linkHash"`
+	Reason     string    `json:"reason"`
+
+	// Quarantined is set when the link was withheld from the listeners.
+	Quarantined bool `json:"quarantined"`
+}
+
+var integrityAlerts = stats.Int64(
+	"stratumn-connector/livesync/integrity-alerts",
+	"number of synced links that failed the integrity verification",
+	stats.UnitDimensionless,
+)
+
+// verifier checks the hash, the signatures and the chaining of the synced
+// links.
+type verifier struct {
+	quarantine bool
+
+	mu sync.Mutex
+	// seen indexes the hex encoded hashes of the verified links by map ID.
+	seen map[string]map[string]struct{}
+	// order lists the verified links from the oldest one, to forget them
+	// once more than maxSeenLinks are remembered.
+	order []seenLink
+	// rejected lists the hex encoded hashes of the links that failed the
+	// verification, which are reported once even when fetched again.
+	rejected map[string]struct{}
+	// complete lists the workflows verified from their first link. The
+	// previous links of the other workflows may have been synced before the
+	// start or forgotten, so a missing previous link is not reported for
+	// them.
+	complete map[string]bool
+}
+
+// seenLink is a verified link remembered by the verifier.
+type seenLink struct {
+	workflowID string
+	mapID      string
+	hash       string
+}
+
+// newVerifier returns the verifier of an integrity configuration, or nil
+// when the verification is disabled.
+func newVerifier(integrity Integrity) *verifier {
+	if !integrity.Verify {
+		return nil
+	}
+
+	return &verifier{
+		quarantine: integrity.Policy != PassThrough,
+		seen:       make(map[string]map[string]struct{}),
+		rejected:   make(map[string]struct{}),
+		complete:   make(map[string]bool),
+	}
+}
+
+// verify checks a page of links fetched from a cursor and returns the
+// alerts of the links failing the verification. Depending on the policy,
+// these links are marked as quarantined.
+// Only the links passing the verification can be the previous link of the
+// following ones.
+func (v *verifier) verify(workflowID, from string, edges linkEdges) []*IntegrityAlert {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if from == "" {
+		v.complete[workflowID] = true
+	}
+
+	var alerts []*IntegrityAlert
+	for i := range edges {
+		edge := &edges[i]
+		// the response is decoded in place, reset the flag of a previous page.
+		edge.Quarantined = false
+		key := strings.ToLower(edge.Node.LinkHash)
+		if _, ok := v.rejected[key]; ok {
+			edge.Quarantined = v.quarantine
+			continue
+		}
+
+		alert := &IntegrityAlert{
+			Time:       time.Now(),
+			WorkflowID: workflowID,
+			LinkHash:   edge.Node.LinkHash,
+		}
+		if link := edge.Node.Raw; link != nil && link.Meta != nil {
+			alert.MapID = link.Meta.MapId
+		}
+
+		if err := v.check(workflowID, edge); err != nil {
+			alert.Reason = err.Error()
+			alert.Quarantined = v.quarantine
+			edge.Quarantined = v.quarantine
+			v.rejected[key] = struct{}{}
+			alerts = append(alerts, alert)
+			continue
+		}
+
+		v.remember(seenLink{workflowID: workflowID, mapID: alert.MapID, hash: key})
+	}
+
+	return alerts
+}
+
+// remember records a verified link and forgets the oldest ones when too many
+// links are remembered.
+// It must be called with the lock held.
+func (v *verifier) remember(l seenLink) {
+	hashes, ok := v.seen[l.mapID]
+	if !ok {
+		hashes = make(map[string]struct{})
+		v.seen[l.mapID] = hashes
+	}
+	if _, ok := hashes[l.hash]; ok {
+		return
+	}
+	hashes[l.hash] = struct{}{}
+	v.order = append(v.order, l)
+
+	for len(v.order) > maxSeenLinks {
+		old := v.order[0]
+		v.order = v.order[1:]
+
+		delete(v.seen[old.mapID], old.hash)
+		if len(v.seen[old.mapID]) == 0 {
+			delete(v.seen, old.mapID)
+		}
+		// the following links of the workflow may refer to the forgotten
+		// link.
+		v.complete[old.workflowID] = false
+	}
+}
+
+// check verifies a link.
+// It must be called with the lock held.
+func (v *verifier) check(workflowID string, edge *linkEdge) error {
+	link := edge.Node.Raw
+	if link == nil || link.Meta == nil {
+		return ErrMissingLink
+	}
+
+	expected, err := hex.DecodeString(edge.Node.LinkHash)
+	if err != nil {
+		return errors.Wrap(ErrLinkHashMismatch, err.Error())
+	}
+	lh, err := link.Hash()
+	if err != nil {
+		return errors.Wrap(ErrLinkHashMismatch, err.Error())
+	}
+	if !bytes.Equal(lh, expected) {
+		return ErrLinkHashMismatch
+	}
+	// the links fetched again, for instance by a subscription catching up,
+	// were already verified.
+	if _, ok := v.seen[link.Meta.MapId][hex.EncodeToString(lh)]; ok {
+		return nil
+	}
+
+	for _, sig := range link.Signatures {
+		if err := sig.Validate(link); err != nil {
+			return errors.Wrap(ErrBadSignature, err.Error())
+		}
+	}
+
+	if prev := link.Meta.PrevLinkHash; len(prev) > 0 {
+		_, ok := v.seen[link.Meta.MapId][hex.EncodeToString(prev)]
+		if !ok && v.complete[workflowID] {
+			return errors.Wrap(ErrUnknownPrevLink, hex.EncodeToString(prev))
+		}
+	}
+
+	return nil
+}
+
+// verify checks a fetched page of a workflow when the verification is
+// enabled and reports the alerts.
+// The links must be verified before they are decrypted, since their hashes
+// and signatures cover the encrypted data.
+func (s *synchronizer) verify(workflowID, from string, edges linkEdges) {
+	if s.verifier == nil {
+		return
+	}
+
+	alerts := s.verifier.verify(workflowID, from, edges)
+	if len(alerts) == 0 {
+		return
+	}
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(workflowKey, workflowID))
+	stats.Record(ctx, integrityAlerts.M(int64(len(alerts))))
+
+	for _, a := range alerts {
+		log.Warnf("Link %s of workflow %s failed the integrity verification (quarantined: %t): %s", a.LinkHash, a.WorkflowID, a.Quarantined, a.Reason)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pollStatus(workflowID).alerts += uint64(len(alerts))
+	s.alerts = append(s.alerts, alerts...)
+	if len(s.alerts) > maxIntegrityAlerts {
+		s.alerts = s.alerts[len(s.alerts)-maxIntegrityAlerts:]
+	}
+}
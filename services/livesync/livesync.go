@@ -3,7 +3,10 @@ package livesync
 import (
 	"context"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	cs "github.com/stratumn/go-chainscript"
 
 	"github.com/stratumn/go-connector/services/client"
@@ -14,18 +17,106 @@ import (
 // DefaultPagination is the number of links fetched by API call.
 const DefaultPagination = 50
 
+var (
+	// ErrUnknownListener is returned when unregistering a channel that was
+	// not returned by Register or was already unregistered.
+	ErrUnknownListener = errors.New("the channel is not registered")
+
+	// ErrUnknownWorkflow is returned when following a workflow that is not
+	// synced.
+	ErrUnknownWorkflow = errors.New("the workflow is not synced")
+
+	// ErrBadCursor is returned when following a workflow from a malformed
+	// cursor.
+	ErrBadCursor = errors.New("the cursor is malformed")
+)
+
 // Synchronizer is the type exposed by the livesync service.
+// It is safe for concurrent use.
 type Synchronizer interface {
-	Register(WorkflowStates) (<-chan []*cs.Segment, error)
+	// Register subscribes a listener to the updates of the given workflows
+	// that match a filter.
+	Register(WorkflowStates, *Filter) (<-chan []*cs.Segment, error)
+
+	// Follow subscribes a listener to the updates of synced workflows that
+	// match a filter, without changing the sync. The past links following
+	// the cursors of the listener are fetched for this listener only and
+	// passed to send before the channel is returned.
+	Follow(ctx context.Context, states WorkflowStates, filter *Filter, send func([]*cs.Segment) error) (<-chan []*cs.Segment, error)
+
+	// Unregister stops the updates sent to a channel returned by Register
+	// or Follow and closes it. The updates not consumed yet are discarded.
+	Unregister(<-chan []*cs.Segment) error
+
+	// Subscribe starts a durable subscription to the updates of all the
+	// workflows that match a filter. The batches must be acknowledged: the
+	// subscription resumes from the last acknowledged batch of the same name,
+	// including after a restart.
+	Subscribe(name string, filter *Filter) (<-chan *Batch, error)
+
+	// Unsubscribe stops a subscription and closes its channel. The batches
+	// not acknowledged yet will be delivered again.
+	Unsubscribe(<-chan *Batch) error
+
+	// Replay delivers the past links of a workflow to a single
+	// subscription, without changing the cursors of the workflow and of the
+	// subscription. It returns the number of replayed links once they are
+	// processed.
+	Replay(ctx context.Context, subscription, workflowID string, from ReplayFrom) (int, error)
+
+	// Workflows returns the IDs of the synced workflows.
+	Workflows() []string
+
+	// Fetch returns the page of segments of a workflow following a cursor,
+	// without notifying the listeners.
+	Fetch(ctx context.Context, workflowID, cursor string) ([]*cs.Segment, string, error)
+
+	// Status returns the status of the synced workflows and of the listeners.
+	Status() *Status
 }
 
 type synchronizer struct {
 	client client.StratumnClient
 
+	// checkpointer persists the cursors of the synced workflows.
+	// It is nil when the cursors are kept in memory only.
+	checkpointer Checkpointer
+	// saved are the cursors restored from the checkpoint, used when a
+	// workflow is discovered after the start.
+	saved WorkflowStates
+	// delivery configures the queues of the listeners.
+	delivery Delivery
+	// verifier checks the integrity of the synced links. It is nil when
+	// the verification is disabled.
+	verifier *verifier
+
+	// mu protects the workflow states and the registered services.
+	mu sync.Mutex
 	// The syncing state of the watched workflows.
 	workflowStates WorkflowStates
+	// The outcome of the last polls of the workflows.
+	statuses map[string]*pollStatus
+	// The most recent integrity alerts.
+	alerts []*IntegrityAlert
 	// Services subscribing to links updates.
 	registeredServices []*listener
+	// listeners indexes the registered services and the subscriptions by
+	// channel so that Unregister does not wait for a poll holding the lock.
+	listeners sync.Map
+	// subscriptions indexes the active subscriptions by name. They are
+	// delivered from their own goroutine and are not registered services.
+	subscriptions map[string]*listener
+
+	// cpMu protects the cursors recorded by the last checkpoint, which are
+	// saved without holding the lock. cpWake is signaled when they change.
+	cpMu     sync.Mutex
+	cpStates WorkflowStates
+	cpWake   chan struct{}
+
+	// ackMu protects the acknowledged cursors of the subscriptions, which
+	// are committed without holding the lock.
+	ackMu sync.Mutex
+	acked map[string]WorkflowStates
 }
 
 // WorkflowState maps the ID of the workflow to the cursor of the last synced link.
@@ -47,117 +138,686 @@ func (wfStates WorkflowStates) Get(id string) (*WorkflowState, bool) {
 	return nil, false
 }
 
-type listener struct {
-	states   WorkflowStates
-	listener chan<- []*cs.Segment
-}
-
 // NewSycnhronizer returns a new Synchronizer.
 // It takes a stratumn client and a list of workflows to sync with.
-func NewSycnhronizer(client client.StratumnClient, watchedWorkflows []string) Synchronizer {
+// When a checkpointer is given, the cursors of the watched workflows are
+// restored from the last checkpoint and saved after the polls, up to the
+// segments consumed by the listeners and at most once per
+// CheckpointInterval.
+// When enabled, the integrity of the synced links is verified before they are
+// delivered.
+func NewSycnhronizer(client client.StratumnClient, watchedWorkflows []string, checkpointer Checkpointer, delivery Delivery, integrity Integrity) (Synchronizer, error) {
+	if err := delivery.Validate(); err != nil {
+		return nil, err
+	}
+	if err := integrity.Validate(); err != nil {
+		return nil, err
+	}
+
+	var saved WorkflowStates
+	acked := make(map[string]WorkflowStates)
+	if checkpointer != nil {
+		var err error
+		if saved, err = checkpointer.Load(); err != nil {
+			return nil, err
+		}
+		if acked, err = checkpointer.LoadSubscriptions(); err != nil {
+			return nil, err
+		}
+	}
+
 	states := make(WorkflowStates, len(watchedWorkflows))
 	for i, wfID := range watchedWorkflows {
 		states[i] = &WorkflowState{ID: wfID, Cursor: ""}
+		if w, ok := saved.Get(wfID); ok {
+			states[i].Cursor = w.Cursor
+		}
 	}
 
 	return &synchronizer{
 		client:         client,
+		checkpointer:   checkpointer,
+		saved:          saved,
+		delivery:       delivery,
+		verifier:       newVerifier(integrity),
 		workflowStates: states,
-	}
+		statuses:       make(map[string]*pollStatus),
+		subscriptions:  make(map[string]*listener),
+		cpWake:         make(chan struct{}, 1),
+		acked:          acked,
+	}, nil
 }
 
 // Register subscribes a listener to future updates.
 // The listener may pass a WorkflowStates object to specify which workflows it
 // wants to receive updates from and from which cursor it should receive updates.
 // The livesync automatically subscribe to the workflow if it is not already the case.
-// If nil is passed, the listener will be notified of updates for all synced workflows.
-func (s *synchronizer) Register(states WorkflowStates) (<-chan []*cs.Segment, error) {
+// If nil is passed, the listener will be notified of updates for all synced workflows,
+// including the ones discovered later.
+// The listener only receives the segments selected by the filter, or all of
+// them when the filter is nil.
+func (s *synchronizer) Register(states WorkflowStates, filter *Filter) (<-chan []*cs.Segment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.lowerCursors(states); err != nil {
+		return nil, err
+	}
+
+	// a listener registered for all the workflows starts from the current
+	// cursors.
+	all := states == nil
+	if all {
+		states = make(WorkflowStates, len(s.workflowStates))
+		for i, w := range s.workflowStates {
+			states[i] = &WorkflowState{ID: w.ID, Cursor: w.Cursor}
+		}
+	}
+
+	l := newListener(states, filter, s.delivery)
+	l.all = all
+	s.addListener(l)
+	return l.listener, nil
+}
+
+// lowerCursors syncs the workflows listeners register to and lowers their
+// cursors so that the updates following the cursors of the listeners are
+// fetched again.
+// It must be called with the lock held.
+func (s *synchronizer) lowerCursors(states WorkflowStates) error {
 	for _, w := range states {
 		if livesyncState, ok := s.workflowStates.Get(w.ID); !ok {
 			s.workflowStates = append(s.workflowStates, &WorkflowState{ID: w.ID, Cursor: w.Cursor})
 		} else if ok && strings.Compare(w.Cursor, livesyncState.Cursor) == -1 {
 			gap, err := CompareCursors(w.Cursor, livesyncState.Cursor)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			// if a service register for updates in the past, lower the current end cursor.
 			if gap < 0 {
+				log.Infof("Rewinding workflow %s for a listener registered in the past, use Replay to deliver past links to a single subscription", w.ID)
 				livesyncState.Cursor = w.Cursor
 			}
 		}
 	}
-	if states == nil {
-		states = make(WorkflowStates, len(s.workflowStates))
-		for i, w := range s.workflowStates {
-			states[i] = &WorkflowState{ID: w.ID, Cursor: ""}
+	return nil
+}
+
+// Follow subscribes a listener to the updates of synced workflows, like
+// Register, but neither syncs new workflows nor rewinds the synced ones:
+// ErrUnknownWorkflow is returned for a workflow that is not synced.
+// The listener receives the updates following the current cursors of the
+// workflows. The links between its own cursors and the current ones are
+// fetched for it only, and passed to send before the channel is returned.
+// The live updates are queued meanwhile.
+// If nil is passed, the listener follows all the synced workflows from
+// their current cursors.
+func (s *synchronizer) Follow(ctx context.Context, states WorkflowStates, filter *Filter, send func([]*cs.Segment) error) (<-chan []*cs.Segment, error) {
+	l, past, err := s.follow(states, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range past {
+		if err := s.catchUpListener(ctx, r, filter, send); err != nil {
+			_ = s.stopListener(l.key)
+			return nil, err
 		}
 	}
 
-	newCh := make(chan []*cs.Segment)
-	s.registeredServices = append(s.registeredServices, &listener{
-		listener: newCh,
-		states:   states,
-	})
-	return newCh, nil
+	return l.listener, nil
+}
+
+// linkRange is a range of links of a workflow, between two cursors.
+type linkRange struct {
+	workflowID string
+	from, to   string
 }
 
-// pollAndNotify fetches all the missing links from the given workflows.
-func (s *synchronizer) pollAndNotify(ctx context.Context) error {
+// follow registers the listener of Follow at the current cursors of the
+// workflows. It returns the listener and the past links it must catch up
+// with.
+func (s *synchronizer) follow(states WorkflowStates, filter *Filter) (*listener, []linkRange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := make(WorkflowStates, 0, len(s.workflowStates))
+	var past []linkRange
 	for _, w := range s.workflowStates {
-		variables := map[string]interface{}{
-			"id":    w.ID,
-			"limit": DefaultPagination,
+		if states == nil {
+			start = append(start, &WorkflowState{ID: w.ID, Cursor: w.Cursor})
+		}
+	}
+	for _, w := range states {
+		synced, ok := s.workflowStates.Get(w.ID)
+		if !ok {
+			return nil, nil, errors.Wrap(ErrUnknownWorkflow, w.ID)
 		}
 
-		rsp := rspData{}
-		rsp.WorkflowByRowID.Links.PageInfo.HasNextPage = true
-		for rsp.WorkflowByRowID.Links.PageInfo.HasNextPage {
-			// the cursor acts as an offset to fetch links from.
-			if w.Cursor != "" {
-				variables["cursor"] = w.Cursor
-			}
+		gap, err := CompareCursors(w.Cursor, synced.Cursor)
+		if err != nil {
+			return nil, nil, errors.Wrap(ErrBadCursor, err.Error())
+		}
+		if gap < 0 {
+			past = append(past, linkRange{workflowID: w.ID, from: w.Cursor, to: synced.Cursor})
+			start = append(start, &WorkflowState{ID: w.ID, Cursor: synced.Cursor})
+		} else {
+			start = append(start, &WorkflowState{ID: w.ID, Cursor: w.Cursor})
+		}
+	}
 
-			err := s.client.CallTraceGql(ctx, pollQuery, variables, &rsp)
-			if err != nil {
-				log.Errorf("API returned error %s, keeping running...", err)
+	l := newListener(start, filter, s.delivery)
+	l.all = states == nil
+	s.addListener(l)
+
+	return l, past, nil
+}
+
+// catchUpListener fetches the past links of a listener and passes them to
+// send. Quarantined links are left out.
+func (s *synchronizer) catchUpListener(ctx context.Context, r linkRange, filter *Filter, send func([]*cs.Segment) error) error {
+	cursor := r.from
+	for cursor != r.to {
+		rsp, err := s.fetch(ctx, r.workflowID, cursor)
+		if err != nil {
+			return err
+		}
+
+		links := rsp.WorkflowByRowID.Links
+		reached := false
+		var segments []*cs.Segment
+		for i := range links.Edges {
+			e := &links.Edges[i]
+			if !e.Quarantined {
+				segments = append(segments, e.segment())
+			}
+			if e.Cursor == r.to {
+				reached = true
 				break
 			}
+		}
 
-			segments, err := rsp.WorkflowByRowID.Links.Edges.Segments()
-			if err != nil {
-				s.closeListeners()
+		if segments = filter.Apply(segments); len(segments) > 0 {
+			if err := send(segments); err != nil {
 				return err
 			}
+		}
+		if reached || len(links.Edges) == 0 || !links.PageInfo.HasNextPage {
+			return nil
+		}
+		cursor = links.PageInfo.EndCursor
+	}
+	return nil
+}
+
+// addListener starts notifying a listener.
+// It must be called with the lock held.
+func (s *synchronizer) addListener(l *listener) {
+	s.registeredServices = append(s.registeredServices, l)
+	s.listeners.Store(l.key, l)
+}
+
+// Unregister stops the updates sent to a channel returned by Register and
+// closes it. The updates not consumed yet are discarded.
+func (s *synchronizer) Unregister(ch <-chan []*cs.Segment) error {
+	return s.stopListener(ch)
+}
+
+// Subscribe starts a durable subscription to the updates of all the
+// workflows, including the ones discovered later, that match a filter.
+// The subscription resumes from the cursors acknowledged by the previous
+// subscription of the same name, which are saved with the checkpoint.
+// Batches are delivered one at a time: the next batch is delivered once the
+// previous one is acknowledged. A batch that is not acknowledged is
+// delivered again, and moved to the dead letters after too many attempts.
+// Each subscription delivers the pages synced by the poller in its own
+// goroutine, so that a subscription slow to acknowledge its batches delays
+// neither the sync nor the other listeners. The links synced before the
+// subscription started are fetched again from its acknowledged cursors.
+// The acknowledged cursors are only saved when a checkpointer is given:
+// otherwise a subscription started after a restart receives the workflows
+// again from their first link.
+// Only one subscription of a given name can be active at a time.
+func (s *synchronizer) Subscribe(name string, filter *Filter) (<-chan *Batch, error) {
+	if name == "" {
+		return nil, ErrMissingSubscriptionName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[name]; ok {
+		return nil, errors.Wrap(ErrSubscribed, name)
+	}
+
+	l := makeListener(nil, filter, s.delivery)
+	l.sub = newSubscription(name, s.delivery, func(workflowID, cursor string) {
+		s.commit(name, workflowID, cursor)
+	})
+	l.key = (<-chan *Batch)(l.sub.batches)
+	go s.runSubscription(l)
+
+	s.listeners.Store(l.key, l)
+	s.subscriptions[name] = l
+	return l.sub.batches, nil
+}
+
+// Unsubscribe stops a subscription and closes its channel.
+// The batches not acknowledged yet will be delivered again to the next
+// subscription of the same name.
+func (s *synchronizer) Unsubscribe(ch <-chan *Batch) error {
+	return s.stopListener(ch)
+}
+
+// commit saves the cursor of an acknowledged batch.
+// A failed checkpoint is logged and retried after the next acknowledgement.
+func (s *synchronizer) commit(name, workflowID, cursor string) {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	states := s.acked[name]
+	if w, ok := states.Get(workflowID); ok {
+		w.Cursor = cursor
+	} else {
+		s.acked[name] = append(states, &WorkflowState{ID: workflowID, Cursor: cursor})
+	}
+
+	if s.checkpointer == nil {
+		return
+	}
+	if err := s.checkpointer.SaveSubscription(name, s.acked[name]); err != nil {
+		log.Errorf("could not save the cursors of subscription %s: %s", name, err)
+	}
+}
+
+// ackedCursor returns the acknowledged cursor of a workflow for a
+// subscription.
+func (s *synchronizer) ackedCursor(name, workflowID string) string {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	if w, ok := s.acked[name].Get(workflowID); ok {
+		return w.Cursor
+	}
+	return ""
+}
+
+// stopListener stops the delivery to a listener given its channel.
+func (s *synchronizer) stopListener(ch interface{}) error {
+	v, ok := s.listeners.Load(ch)
+	if !ok {
+		return ErrUnknownListener
+	}
+
+	// Stop the listener first to unblock a poll waiting for it.
+	l := v.(*listener)
+	l.stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeListener(l)
+
+	return nil
+}
+
+// Workflows returns the IDs of the synced workflows.
+func (s *synchronizer) Workflows() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, len(s.workflowStates))
+	for i, w := range s.workflowStates {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+// Fetch returns the page of segments of a workflow following a cursor,
+// without notifying the listeners. An empty cursor fetches the first page.
+// It also returns the cursor of the last segment of the page, to be passed
+// to the next call. No segment is returned once the whole workflow is fetched.
+// The links quarantined by the integrity verification are left out, and the
+// pages containing only quarantined links are skipped.
+func (s *synchronizer) Fetch(ctx context.Context, workflowID, cursor string) ([]*cs.Segment, string, error) {
+	for {
+		rsp, err := s.fetch(ctx, workflowID, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		links := rsp.WorkflowByRowID.Links
+		if len(links.Edges) == 0 {
+			return nil, cursor, nil
+		}
+
+		segments, err := links.Edges.Segments()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(segments) > 0 || !links.PageInfo.HasNextPage {
+			return segments, links.PageInfo.EndCursor, nil
+		}
+		cursor = links.PageInfo.EndCursor
+	}
+}
+
+// fetch calls the API for the page of links of a workflow following a
+// cursor.
+func (s *synchronizer) fetch(ctx context.Context, workflowID, cursor string) (*rspData, error) {
+	variables := map[string]interface{}{
+		"id":    workflowID,
+		"limit": DefaultPagination,
+	}
+	if cursor != "" {
+		variables["cursor"] = cursor
+	}
+
+	rsp := &rspData{}
+	if err := s.call(ctx, workflowID, cursor, variables, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// call calls the API for a page of links of a workflow following a cursor.
+// When the verification is enabled, the links are verified as returned by
+// the API and only decrypted afterwards.
+func (s *synchronizer) call(ctx context.Context, workflowID, cursor string, variables map[string]interface{}, rsp *rspData) error {
+	if s.verifier == nil {
+		return s.client.CallTraceGql(ctx, pollQuery, variables, rsp)
+	}
+
+	if err := s.client.CallTraceGqlRaw(ctx, pollQuery, variables, rsp); err != nil {
+		return err
+	}
+	s.verify(workflowID, cursor, rsp.WorkflowByRowID.Links.Edges)
+	s.client.DecryptLinks(ctx, rsp)
+
+	return nil
+}
+
+// pollWorkflow fetches all the missing links of a workflow and sends them to
+// the registered services.
+// It returns the number of synced segments. API errors are returned as
+// errAPI so that the workflow is polled again later, or as errRejected when
+// the API rejected the poll. Other errors are fatal.
+// The API is called without holding the lock so that listeners can register
+// while a poll is running.
+func (s *synchronizer) pollWorkflow(ctx context.Context, workflowID string) (int, error) {
+	cursor := s.cursor(workflowID)
+	variables := map[string]interface{}{
+		"id":    workflowID,
+		"limit": DefaultPagination,
+	}
+
+	synced := 0
+	rsp := rspData{}
+	rsp.WorkflowByRowID.Links.PageInfo.HasNextPage = true
+	for rsp.WorkflowByRowID.Links.PageInfo.HasNextPage {
+		// the cursor acts as an offset to fetch links from.
+		if cursor != "" {
+			variables["cursor"] = cursor
+		} else {
+			delete(variables, "cursor")
+		}
+
+		err := s.call(ctx, workflowID, cursor, variables, &rsp)
+		if err != nil {
+			s.recordFailure(workflowID, err)
+			switch errors.Cause(err) {
+			case client.ErrUnauthorized, client.ErrNotFound, client.ErrValidation:
+				// polling again soon will not help.
+				log.Errorf("API rejected the poll of workflow %s: %s", workflowID, err)
+				return synced, errRejected
+			default:
+				log.Warnf("API returned error %s, keeping running...", err)
+				return synced, errAPI
+			}
+		}
+
+		segments, err := rsp.WorkflowByRowID.Links.Edges.Segments()
+		if err != nil {
+			return synced, err
+		}
+		// the cursor also moves past the quarantined links.
+		if len(rsp.WorkflowByRowID.Links.Edges) > 0 {
+			log.Infof("Synced %d links\n", len(segments))
+			synced += len(segments)
+			cursor = s.notify(ctx, workflowID, cursor, rsp.WorkflowByRowID.Links.PageInfo.EndCursor, rsp.WorkflowByRowID.Links.Edges)
+		}
+	}
+	s.recordPoll(workflowID, rsp.WorkflowByRowID.Links.TotalCount)
+
+	return synced, nil
+}
+
+// cursor returns the current cursor of a workflow.
+func (s *synchronizer) cursor(workflowID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.workflowStates.Get(workflowID); ok {
+		return w.Cursor
+	}
+	return ""
+}
+
+// notify moves the cursor of a workflow to the end of a synced page and
+// sends the page to the registered services.
+// It returns the cursor to fetch the next page from.
+func (s *synchronizer) notify(ctx context.Context, workflowID, from, to string, edges linkEdges) string {
+	s.mu.Lock()
+
+	w, ok := s.workflowStates.Get(workflowID)
+	if !ok {
+		s.mu.Unlock()
+		return to
+	}
+	// A service registered for past updates while the page was fetched:
+	// fetch again from its cursor.
+	if w.Cursor != from {
+		s.mu.Unlock()
+		return w.Cursor
+	}
+
+	w.Cursor = to
+	// the subscriptions deliver the page from their own goroutine once
+	// woken up after the poll.
+	for _, l := range s.subscriptions {
+		l.sub.push(w.ID, from, to, edges)
+	}
+	// send the synced segments to the registered services.
+	// compare the current cursor to the cursor specified by each service:
+	// - if the current cursor is anterior or equal, do not send any updates.
+	// - else send all the segments starting from the service's cursor.
+	// The segments are queued, so a slow service does not delay the others.
+	var pending []*pendingBatch
+	for _, service := range s.registeredServices {
+		serviceState, ok := service.states.Get(w.ID)
+		// if the service does not subscribe to this workflow, skip it.
+		if !ok {
+			continue
+		}
+		gap, err := CompareCursors(w.Cursor, serviceState.Cursor)
+		if err != nil {
+			log.Errorf("error comparing cursors: %s", err)
+		}
+		if gap > 0 {
+			// the cursor of the service moves on even when the filter
+			// selects none of the segments.
+			segments := service.filter.Apply(edges.Slice(serviceState.Cursor))
 			if len(segments) > 0 {
-				log.Infof("Synced %d links\n", len(segments))
-				w.Cursor = rsp.WorkflowByRowID.Links.PageInfo.EndCursor
-				// send the synced segments to the registered services.
-				// compare the current cursor to the cursor specified by each service:
-				// - if the current cursor is anterior or equal, do not send any updates.
-				// - else send all the segments starting from the service's cursor.
-				for _, service := range s.registeredServices {
-					serviceState, ok := service.states.Get(w.ID)
-					// if the service does not subscribe to this workflow, skip it.
-					if !ok {
-						continue
-					}
-					gap, err := CompareCursors(w.Cursor, serviceState.Cursor)
-					if err != nil {
-						log.Errorf("error comparing cursors: %s", err)
-					}
-					if gap > 0 {
-						service.listener <- rsp.WorkflowByRowID.Links.Edges.Slice(serviceState.Cursor)
-						serviceState.Cursor = w.Cursor
-					}
-				}
+				pending = append(pending, &pendingBatch{
+					listener: service,
+					state:    serviceState,
+					from:     serviceState.Cursor,
+					batch:    &Batch{WorkflowID: w.ID, Cursor: w.Cursor, Segments: segments},
+				})
+				continue
 			}
+			serviceState.Cursor = w.Cursor
+		}
+	}
+
+	// The lock is released while queuing so that a blocked listener does
+	// not prevent the others from registering or unregistering.
+	s.mu.Unlock()
+	for _, p := range pending {
+		p.queued = p.listener.enqueue(ctx, p.from, p.batch)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range pending {
+		if p.queued {
+			if p.state.Cursor == p.from {
+				p.state.Cursor = p.batch.Cursor
+			}
+			continue
+		}
+		// the updates given up because the synchronizer stops are fetched
+		// again on the next start.
+		if ctx.Err() != nil {
+			continue
+		}
+		if p.listener.policy == Disconnect {
+			log.Warnf("Disconnecting a listener whose queue is full")
+		}
+		s.removeListener(p.listener)
+	}
+	s.checkpoint()
+
+	return to
+}
+
+// pendingBatch is a batch waiting to be queued for a listener.
+type pendingBatch struct {
+	listener *listener
+	// state is the state of the workflow for the listener.
+	state *WorkflowState
+	// from is the cursor of the listener before the batch.
+	from   string
+	batch  *Batch
+	queued bool
+}
+
+// checkpoint records the cursors up to which the synced segments were
+// consumed by all the registered services, so that the segments still
+// queued are delivered again after a restart. The subscriptions are left
+// out since they resume from their acknowledged cursors.
+// The cursors are saved by runCheckpoints, without holding the lock.
+// It must be called with the lock held.
+func (s *synchronizer) checkpoint() {
+	if s.checkpointer == nil {
+		return
+	}
+
+	states := make(WorkflowStates, len(s.workflowStates))
+	for i, w := range s.workflowStates {
+		cursor := w.Cursor
+		for _, l := range s.registeredServices {
+			lw, ok := l.states.Get(w.ID)
+			if !ok {
+				continue
+			}
+			consumed := l.consumedCursor(w.ID, lw.Cursor)
+			if gap, err := CompareCursors(consumed, cursor); err == nil && gap < 0 {
+				cursor = consumed
+			}
+		}
+		states[i] = &WorkflowState{ID: w.ID, Cursor: cursor}
+	}
+
+	s.cpMu.Lock()
+	s.cpStates = states
+	s.cpMu.Unlock()
+
+	select {
+	case s.cpWake <- struct{}{}:
+	default:
+	}
+}
+
+// runCheckpoints saves the cursors recorded by checkpoint, at most once per
+// interval, until stopped. The last recorded cursors are saved before it
+// returns.
+// A failed checkpoint is logged and retried after the next poll.
+func (s *synchronizer) runCheckpoints(stop <-chan struct{}, interval time.Duration) {
+	if s.checkpointer == nil {
+		return
+	}
+	defer s.saveCheckpoint()
+
+	for {
+		select {
+		case <-s.cpWake:
+		case <-stop:
+			return
+		}
+
+		s.saveCheckpoint()
+
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// saveCheckpoint saves the cursors recorded by checkpoint, if any.
+func (s *synchronizer) saveCheckpoint() {
+	s.cpMu.Lock()
+	states := s.cpStates
+	s.cpStates = nil
+	s.cpMu.Unlock()
+
+	if states == nil {
+		return
+	}
+	if err := s.checkpointer.Save(states); err != nil {
+		log.Errorf("could not save the livesync checkpoint: %s", err)
+	}
+}
+
+// removeListener stops the delivery to a listener and closes its channel.
+// It must be called with the lock held.
+func (s *synchronizer) removeListener(l *listener) {
+	if l.sub != nil {
+		if s.subscriptions[l.sub.name] == l {
+			delete(s.subscriptions, l.sub.name)
+			s.listeners.Delete(l.key)
+		}
+		// the subscription closes its channel once stopped.
+		l.stop()
+		return
+	}
+
+	for i, service := range s.registeredServices {
+		if service == l {
+			s.registeredServices = append(s.registeredServices[:i], s.registeredServices[i+1:]...)
+			s.listeners.Delete(l.key)
+			l.close()
+			return
 		}
 	}
-	return nil
 }
 
 func (s *synchronizer) closeListeners() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for _, l := range s.registeredServices {
-		close(l.listener)
+		s.listeners.Delete(l.key)
+		l.close()
+	}
+	s.registeredServices = nil
+
+	// the batches not acknowledged yet are delivered again after a restart.
+	for _, l := range s.subscriptions {
+		s.listeners.Delete(l.key)
+		l.stop()
 	}
+	s.subscriptions = make(map[string]*listener)
 }
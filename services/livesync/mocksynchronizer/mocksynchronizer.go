@@ -5,6 +5,7 @@
 package mocksynchronizer
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	go_chainscript "github.com/stratumn/go-chainscript"
 	livesync "github.com/stratumn/go-connector/services/livesync"
@@ -34,15 +35,116 @@ func (m *MockSynchronizer) EXPECT() *MockSynchronizerMockRecorder {
 	return m.recorder
 }
 
+// Fetch mocks base method
+func (m *MockSynchronizer) Fetch(arg0 context.Context, arg1, arg2 string) ([]*go_chainscript.Segment, string, error) {
+	ret := m.ctrl.Call(m, "Fetch", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*go_chainscript.Segment)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Fetch indicates an expected call of Fetch
+func (mr *MockSynchronizerMockRecorder) Fetch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockSynchronizer)(nil).Fetch), arg0, arg1, arg2)
+}
+
+// Follow mocks base method
+func (m *MockSynchronizer) Follow(arg0 context.Context, arg1 livesync.WorkflowStates, arg2 *livesync.Filter, arg3 func([]*go_chainscript.Segment) error) (<-chan []*go_chainscript.Segment, error) {
+	ret := m.ctrl.Call(m, "Follow", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(<-chan []*go_chainscript.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Follow indicates an expected call of Follow
+func (mr *MockSynchronizerMockRecorder) Follow(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Follow", reflect.TypeOf((*MockSynchronizer)(nil).Follow), arg0, arg1, arg2, arg3)
+}
+
 // Register mocks base method
-func (m *MockSynchronizer) Register(arg0 livesync.WorkflowStates) (<-chan []*go_chainscript.Segment, error) {
-	ret := m.ctrl.Call(m, "Register", arg0)
+func (m *MockSynchronizer) Register(arg0 livesync.WorkflowStates, arg1 *livesync.Filter) (<-chan []*go_chainscript.Segment, error) {
+	ret := m.ctrl.Call(m, "Register", arg0, arg1)
 	ret0, _ := ret[0].(<-chan []*go_chainscript.Segment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Register indicates an expected call of Register
-func (mr *MockSynchronizerMockRecorder) Register(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockSynchronizer)(nil).Register), arg0)
+func (mr *MockSynchronizerMockRecorder) Register(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockSynchronizer)(nil).Register), arg0, arg1)
+}
+
+// Replay mocks base method
+func (m *MockSynchronizer) Replay(arg0 context.Context, arg1, arg2 string, arg3 livesync.ReplayFrom) (int, error) {
+	ret := m.ctrl.Call(m, "Replay", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replay indicates an expected call of Replay
+func (mr *MockSynchronizerMockRecorder) Replay(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replay", reflect.TypeOf((*MockSynchronizer)(nil).Replay), arg0, arg1, arg2, arg3)
+}
+
+// Status mocks base method
+func (m *MockSynchronizer) Status() *livesync.Status {
+	ret := m.ctrl.Call(m, "Status")
+	ret0, _ := ret[0].(*livesync.Status)
+	return ret0
+}
+
+// Status indicates an expected call of Status
+func (mr *MockSynchronizerMockRecorder) Status() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockSynchronizer)(nil).Status))
+}
+
+// Subscribe mocks base method
+func (m *MockSynchronizer) Subscribe(arg0 string, arg1 *livesync.Filter) (<-chan *livesync.Batch, error) {
+	ret := m.ctrl.Call(m, "Subscribe", arg0, arg1)
+	ret0, _ := ret[0].(<-chan *livesync.Batch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe
+func (mr *MockSynchronizerMockRecorder) Subscribe(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockSynchronizer)(nil).Subscribe), arg0, arg1)
+}
+
+// Unregister mocks base method
+func (m *MockSynchronizer) Unregister(arg0 <-chan []*go_chainscript.Segment) error {
+	ret := m.ctrl.Call(m, "Unregister", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unregister indicates an expected call of Unregister
+func (mr *MockSynchronizerMockRecorder) Unregister(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unregister", reflect.TypeOf((*MockSynchronizer)(nil).Unregister), arg0)
+}
+
+// Unsubscribe mocks base method
+func (m *MockSynchronizer) Unsubscribe(arg0 <-chan *livesync.Batch) error {
+	ret := m.ctrl.Call(m, "Unsubscribe", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe
+func (mr *MockSynchronizerMockRecorder) Unsubscribe(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockSynchronizer)(nil).Unsubscribe), arg0)
+}
+
+// Workflows mocks base method
+func (m *MockSynchronizer) Workflows() []string {
+	ret := m.ctrl.Call(m, "Workflows")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Workflows indicates an expected call of Workflows
+func (mr *MockSynchronizerMockRecorder) Workflows() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Workflows", reflect.TypeOf((*MockSynchronizer)(nil).Workflows))
 }
@@ -0,0 +1,283 @@
+package livesync
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMinPollInterval is the default interval at which busy workflows are polled (in milliseconds).
+	DefaultMinPollInterval = 1000
+
+	// DefaultMaxPollInterval is the default interval at which idle workflows are polled (in milliseconds).
+	DefaultMaxPollInterval = 60000
+
+	// DefaultMaxConcurrentPolls is the default number of workflows polled at the same time.
+	DefaultMaxConcurrentPolls = 8
+)
+
+var (
+	// errAPI is returned when a poll failed because of the Stratumn API.
+	// The workflow is polled again after a backoff.
+	errAPI = errors.New("the Stratumn API returned an error")
+
+	// errRejected is returned when the Stratumn API rejected a poll because
+	// the workflow does not exist, its access was revoked or the query is
+	// invalid. The workflow is polled again after the maximum interval, in
+	// case the rejection is lifted.
+	errRejected = errors.New("the Stratumn API rejected the poll")
+)
+
+// schedule is the polling state of a workflow.
+type schedule struct {
+	// interval is the current interval between two successful polls.
+	interval time.Duration
+	// failures is the number of consecutive failed polls.
+	failures uint
+	// next is when the workflow is due.
+	next time.Time
+	// polling is set while a poll is running.
+	polling bool
+	// woken is set when new links are notified during a poll.
+	woken bool
+}
+
+type pollResult struct {
+	workflowID string
+	synced     int
+	err        error
+}
+
+// poller polls the synced workflows concurrently.
+// Each workflow has its own interval: it is halved after a poll syncing new
+// links and doubled after a poll syncing nothing, within the configured
+// bounds. A failed poll is retried after an exponential backoff with jitter.
+// With the push transport, the workflows are polled as soon as new links are
+// notified, and at the maximum interval otherwise.
+type poller struct {
+	synchronizer *synchronizer
+
+	interval    time.Duration
+	minInterval time.Duration
+	maxInterval time.Duration
+	concurrency int
+
+	schedules map[string]*schedule
+	results   chan pollResult
+	inFlight  int
+
+	// push enables the subscription to the new links.
+	push bool
+	// pushing is set while the subscription is active.
+	pushing bool
+	// wake receives the workflows with new links.
+	wake chan string
+	// pushState receives the changes of the subscription state.
+	pushState chan bool
+	// added is signaled when workflows are added, so that the subscription
+	// includes them.
+	added chan struct{}
+}
+
+// newPoller creates a poller from the service configuration.
+// The poll interval is the initial interval of the workflows. The interval
+// stays fixed when no bounds are configured.
+func newPoller(synchronizer *synchronizer, config *Config) *poller {
+	interval := time.Millisecond * config.PollInterval
+	if interval <= 0 {
+		interval = time.Millisecond * DefaultPollInterval
+	}
+
+	minInterval := time.Millisecond * config.MinPollInterval
+	if minInterval <= 0 || minInterval > interval {
+		minInterval = interval
+	}
+
+	maxInterval := time.Millisecond * config.MaxPollInterval
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+
+	concurrency := config.MaxConcurrentPolls
+	if concurrency <= 0 {
+		concurrency = DefaultMaxConcurrentPolls
+	}
+
+	return &poller{
+		synchronizer: synchronizer,
+		interval:     interval,
+		minInterval:  minInterval,
+		maxInterval:  maxInterval,
+		concurrency:  concurrency,
+		schedules:    make(map[string]*schedule),
+		results:      make(chan pollResult, concurrency),
+		push:         config.Transport == PushTransport,
+		wake:         make(chan string),
+		pushState:    make(chan bool),
+		added:        make(chan struct{}, 1),
+	}
+}
+
+// run polls the workflows until the context is done or a poll fails with an
+// error that is not an API error.
+// The listeners are closed once the running polls returned.
+func (p *poller) run(ctx context.Context) error {
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if p.push {
+		go p.listen(pollCtx)
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(p.dispatch(pollCtx)))
+
+		select {
+		case r := <-p.results:
+			p.inFlight--
+			if err := p.reschedule(r); err != nil {
+				timer.Stop()
+				cancel()
+				p.stop()
+				return err
+			}
+		case id := <-p.wake:
+			p.due(id)
+		case pushing := <-p.pushState:
+			p.switchTransport(pushing)
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			p.stop()
+			return errors.WithStack(ctx.Err())
+		}
+
+		timer.Stop()
+	}
+}
+
+// dispatch starts polling the due workflows, the ones that have waited the
+// longest first, as long as the concurrency limit allows it.
+// It returns when the next workflow is due. Workflows added by listeners
+// are scheduled no later than the minimum interval.
+func (p *poller) dispatch(ctx context.Context) time.Time {
+	now := time.Now()
+	wakeUp := now.Add(p.minInterval)
+
+	var due []string
+	for _, id := range p.synchronizer.Workflows() {
+		sched, ok := p.schedules[id]
+		if !ok {
+			sched = &schedule{interval: p.interval, next: now.Add(p.interval)}
+			p.schedules[id] = sched
+			p.workflowAdded()
+		}
+		if sched.polling {
+			continue
+		}
+		if sched.next.After(now) {
+			if sched.next.Before(wakeUp) {
+				wakeUp = sched.next
+			}
+			continue
+		}
+		due = append(due, id)
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return p.schedules[due[i]].next.Before(p.schedules[due[j]].next)
+	})
+
+	for _, id := range due {
+		if p.inFlight >= p.concurrency {
+			// the next due workflow starts when a running poll returns.
+			break
+		}
+		p.schedules[id].polling = true
+		p.inFlight++
+
+		go func(id string) {
+			synced, err := p.synchronizer.pollWorkflow(ctx, id)
+			p.results <- pollResult{workflowID: id, synced: synced, err: err}
+		}(id)
+	}
+
+	return wakeUp
+}
+
+// reschedule adapts the interval of a polled workflow.
+// It returns the error of the poll when the sync cannot go on.
+func (p *poller) reschedule(r pollResult) error {
+	sched := p.schedules[r.workflowID]
+	sched.polling = false
+	woken := sched.woken
+	sched.woken = false
+
+	switch {
+	case r.err == errAPI:
+		sched.failures++
+		sched.next = time.Now().Add(p.backoff(sched.failures))
+		return nil
+	case r.err == errRejected:
+		sched.failures++
+		sched.next = time.Now().Add(p.maxInterval)
+		return nil
+	case r.err != nil:
+		return r.err
+	case r.synced > 0:
+		sched.interval /= 2
+		if sched.interval < p.minInterval {
+			sched.interval = p.minInterval
+		}
+	default:
+		sched.interval *= 2
+		if sched.interval > p.maxInterval {
+			sched.interval = p.maxInterval
+		}
+	}
+
+	sched.failures = 0
+	switch {
+	case woken:
+		sched.next = time.Now()
+	case p.pushing:
+		// the notifications replace the polls, which only guard against a
+		// lost notification.
+		sched.next = time.Now().Add(p.maxInterval)
+	default:
+		sched.next = time.Now().Add(sched.interval)
+	}
+
+	return nil
+}
+
+// backoff returns the delay before polling again a workflow after
+// consecutive failures. The delay doubles after each failure up to the
+// maximum interval, and a random jitter spreads the retries of the
+// workflows failing together.
+func (p *poller) backoff(failures uint) time.Duration {
+	delay := p.maxInterval
+	if failures < 32 {
+		if d := p.minInterval << (failures - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// stop waits for the running polls and closes the listeners.
+func (p *poller) stop() {
+	for ; p.inFlight > 0; p.inFlight-- {
+		<-p.results
+	}
+	p.synchronizer.closeListeners()
+}
@@ -0,0 +1,220 @@
+package livesync
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Transports used to learn about the new links of the synced workflows.
+const (
+	// PollTransport polls each workflow at an interval adapting to its
+	// activity.
+	PollTransport = "poll"
+
+	// PushTransport subscribes to the new links through a GraphQL
+	// subscription, and polls the workflows as soon as they have new links.
+	PushTransport = "push"
+)
+
+var (
+	// ErrBadTransport is returned when the transport is unknown.
+	ErrBadTransport = errors.New("the transport must be one of poll or push")
+)
+
+// pushQuery is the subscription sent to be notified of the new links of the
+// synced workflows. It is opened again when workflows are discovered.
+const pushQuery = `subscription workflowsLinks($ids: [BigInt!]!) {
+	linkCreated(workflowRowIds: $ids) {
+	  workflowRowId
+	}
+  }`
+
+type pushRsp struct {
+	LinkCreated struct {
+		WorkflowRowID string
+	}
+}
+
+// ValidateTransport checks that a transport is known. An empty transport
+// is the poll transport.
+func ValidateTransport(transport string) error {
+	switch transport {
+	case "", PollTransport, PushTransport:
+		return nil
+	default:
+		return errors.Wrap(ErrBadTransport, transport)
+	}
+}
+
+// listen subscribes to the new links and makes the notified workflows due.
+// The links themselves are still fetched by polling from the cursor of the
+// workflow, so that a notification never skips or duplicates a link.
+// When the subscription is lost, the poller falls back to polling at the
+// usual intervals and the subscription is opened again after a backoff.
+// Once connected again, every workflow is polled to catch up with the links
+// created while disconnected.
+// It returns when the context is done.
+func (p *poller) listen(ctx context.Context) {
+	var failures uint
+	for {
+		workflows := p.synchronizer.Workflows()
+		unsubscribe, events, err := p.subscribe(ctx, workflows)
+		if err == nil {
+			log.Infof("Subscribed to the new links, polling the workflows as soon as they change")
+			failures = 0
+			p.setPushing(ctx, true)
+
+			for p.forward(ctx, events) {
+				// the workflows changed: the new subscription is opened
+				// before the previous one is closed so that no link is
+				// missed in between.
+				changed := p.synchronizer.Workflows()
+				if sameWorkflows(workflows, changed) {
+					continue
+				}
+				nextUnsubscribe, nextEvents, err := p.subscribe(ctx, changed)
+				if err != nil {
+					log.Errorf("Could not subscribe to the new links of the discovered workflows: %s", err)
+					break
+				}
+				unsubscribe()
+				workflows, unsubscribe, events = changed, nextUnsubscribe, nextEvents
+			}
+			unsubscribe()
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Warnf("Lost the subscription to the new links, falling back to polling")
+			p.setPushing(ctx, false)
+		} else if ctx.Err() == nil {
+			log.Errorf("Could not subscribe to the new links: %s", err)
+		}
+
+		failures++
+		timer := time.NewTimer(p.backoff(failures))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// subscribe opens a subscription to the new links of the given workflows.
+// The subscription is closed by the returned function.
+func (p *poller) subscribe(ctx context.Context, workflows []string) (context.CancelFunc, <-chan json.RawMessage, error) {
+	subCtx, unsubscribe := context.WithCancel(ctx)
+	events, err := p.synchronizer.client.SubscribeTraceGql(subCtx, pushQuery, map[string]interface{}{"ids": workflows})
+	if err != nil {
+		unsubscribe()
+		return nil, nil, err
+	}
+	return unsubscribe, events, nil
+}
+
+// forward sends the workflows of the notifications to the poller until the
+// subscription ends or workflows are added. It returns whether workflows
+// were added.
+func (p *poller) forward(ctx context.Context, events <-chan json.RawMessage) bool {
+	for {
+		var data json.RawMessage
+		select {
+		case d, ok := <-events:
+			if !ok {
+				return false
+			}
+			data = d
+		case <-p.added:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+
+		var rsp pushRsp
+		if err := json.Unmarshal(data, &rsp); err != nil {
+			log.Warnf("Ignoring a malformed notification: %s", err)
+			continue
+		}
+		if rsp.LinkCreated.WorkflowRowID == "" {
+			continue
+		}
+
+		select {
+		case p.wake <- rsp.LinkCreated.WorkflowRowID:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// sameWorkflows returns whether two lists of workflows are equal.
+func sameWorkflows(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// setPushing tells the poller whether the subscription is active.
+func (p *poller) setPushing(ctx context.Context, pushing bool) {
+	select {
+	case p.pushState <- pushing:
+	case <-ctx.Done():
+	}
+}
+
+// workflowAdded tells the subscription that a workflow was added, without
+// waiting for it.
+func (p *poller) workflowAdded() {
+	if !p.push {
+		return
+	}
+	select {
+	case p.added <- struct{}{}:
+	default:
+	}
+}
+
+// due makes a notified workflow due now. A workflow that is being polled is
+// polled again once the running poll returns, since the notified link may
+// have been created after the running poll fetched its last page.
+func (p *poller) due(workflowID string) {
+	sched, ok := p.schedules[workflowID]
+	if !ok {
+		return
+	}
+	if sched.polling {
+		sched.woken = true
+		return
+	}
+	sched.next = time.Now()
+}
+
+// switchTransport switches between the push and the poll schedules.
+func (p *poller) switchTransport(pushing bool) {
+	p.pushing = pushing
+
+	now := time.Now()
+	for id, sched := range p.schedules {
+		if pushing {
+			// catch up with the links created while disconnected.
+			p.due(id)
+			continue
+		}
+		// resume polling at the initial interval.
+		sched.interval = p.interval
+		if next := now.Add(p.interval); sched.next.After(next) {
+			sched.next = next
+		}
+	}
+}
@@ -2,6 +2,7 @@ package livesync
 
 import (
 	"encoding/hex"
+	"time"
 
 	"github.com/pkg/errors"
 	cs "github.com/stratumn/go-chainscript"
@@ -17,10 +18,12 @@ const pollQuery = `query workflowLinks(
 	  id
 	  name
 	  links(after: $cursor, first: $limit) {
+		totalCount
 		edges {
 			cursor
 			node {
 				linkHash
+				createdAt
 				raw
 			}
 		}
@@ -36,7 +39,8 @@ type rspData struct {
 	WorkflowByRowID struct {
 		Name  string
 		Links struct {
-			PageInfo struct {
+			TotalCount int
+			PageInfo   struct {
 				EndCursor   string
 				HasNextPage bool
 			}
@@ -45,28 +49,40 @@ type rspData struct {
 	}
 }
 
-type linkEdges []struct {
+type linkEdges []linkEdge
+
+type linkEdge struct {
 	Cursor string
 	Node   struct {
-		Raw      *cs.Link
-		LinkHash string
+		Raw       *cs.Link
+		LinkHash  string
+		CreatedAt time.Time
 	}
+
+	// Quarantined is set when the link failed the integrity verification
+	// and must not be delivered.
+	Quarantined bool `json:"-"`
 }
 
-// Segments returns the list of Segments from the linkEdges object
+// Segments returns the list of Segments from the linkEdges object.
+// Quarantined links are left out.
 func (edges linkEdges) Segments() ([]*cs.Segment, error) {
-	segments := make([]*cs.Segment, len(edges))
-	for i, link := range edges {
+	segments := make([]*cs.Segment, 0, len(edges))
+	for _, link := range edges {
 		lh, err := hex.DecodeString(link.Node.LinkHash)
 		if err != nil {
 			return nil, errors.Wrap(err, "bad linkHash")
 		}
-		segments[i] = &cs.Segment{Link: link.Node.Raw, Meta: &cs.SegmentMeta{LinkHash: lh}}
+		if link.Quarantined {
+			continue
+		}
+		segments = append(segments, &cs.Segment{Link: link.Node.Raw, Meta: &cs.SegmentMeta{LinkHash: lh}})
 	}
 	return segments, nil
 }
 
 // Slice returns the list of link for which the cursor is positioned after the provided one.
+// Quarantined links are left out.
 // It assumes the linkEdges are ordered by ascending cursor.
 func (edges linkEdges) Slice(cursor string) []*cs.Segment {
 	segments := make([]*cs.Segment, 0, len(edges))
@@ -74,13 +90,19 @@ func (edges linkEdges) Slice(cursor string) []*cs.Segment {
 		if edges[i].Cursor == cursor {
 			return segments
 		}
-		lh, _ := hex.DecodeString(edges[i].Node.LinkHash)
-		segments = append([]*cs.Segment{
-			&cs.Segment{
-				Link: edges[i].Node.Raw,
-				Meta: &cs.SegmentMeta{LinkHash: lh},
-			},
-		}, segments...)
+		if edges[i].Quarantined {
+			continue
+		}
+		segments = append([]*cs.Segment{edges[i].segment()}, segments...)
 	}
 	return segments
 }
+
+// segment returns the segment of an edge.
+func (e *linkEdge) segment() *cs.Segment {
+	lh, _ := hex.DecodeString(e.Node.LinkHash)
+	return &cs.Segment{
+		Link: e.Node.Raw,
+		Meta: &cs.SegmentMeta{LinkHash: lh},
+	}
+}
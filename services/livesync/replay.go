@@ -0,0 +1,163 @@
+package livesync
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+)
+
+var (
+	// ErrUnknownSubscription is returned when replaying links to a
+	// subscription that is not active.
+	ErrUnknownSubscription = errors.New("no active subscription has this name")
+
+	// ErrBadReplayStart is returned when the start of a replay is given in
+	// several ways.
+	ErrBadReplayStart = errors.New("the replay must start from a cursor, a link hash or a time, not several of them")
+
+	// ErrReplayStartNotFound is returned when the link a replay starts from
+	// is not in the workflow.
+	ErrReplayStartNotFound = errors.New("the link to replay from was not found in the workflow")
+
+	// ErrReplayInterrupted is returned when the subscription stops during a
+	// replay.
+	ErrReplayInterrupted = errors.New("the subscription stopped during the replay")
+)
+
+// ReplayFrom selects the first link of a replay. At most one of the fields
+// is set; the whole workflow is replayed when none is.
+type ReplayFrom struct {
+	// Cursor replays the links following a cursor.
+	Cursor string
+
+	// LinkHash replays the links from the link with this hex encoded hash,
+	// included.
+	LinkHash string
+
+	// Time replays the links created at or after a time.
+	Time time.Time
+}
+
+// Validate checks that the start of the replay is given at most once.
+func (f ReplayFrom) Validate() error {
+	set := 0
+	if f.Cursor != "" {
+		set++
+	}
+	if f.LinkHash != "" {
+		set++
+	}
+	if !f.Time.IsZero() {
+		set++
+	}
+	if set > 1 {
+		return ErrBadReplayStart
+	}
+	return nil
+}
+
+// starts returns whether the replay starts at an edge.
+func (f ReplayFrom) starts(e *linkEdge) bool {
+	switch {
+	case f.LinkHash != "":
+		return strings.EqualFold(e.Node.LinkHash, f.LinkHash)
+	case !f.Time.IsZero():
+		return !e.Node.CreatedAt.Before(f.Time)
+	default:
+		return true
+	}
+}
+
+// Replay delivers the past links of a workflow to a single subscription.
+// The replayed batches are delivered one at a time with the live ones and
+// are marked as Replayed. The cursors of the workflow and of the
+// subscription are left unchanged, and the other listeners receive nothing.
+// The links quarantined by the integrity verification and the ones rejected
+// by the filter of the subscription are not replayed.
+// It blocks until every batch is acknowledged or moved to the dead letters,
+// and returns the number of replayed links.
+func (s *synchronizer) Replay(ctx context.Context, subscription, workflowID string, from ReplayFrom) (int, error) {
+	if err := from.Validate(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	l, ok := s.subscriptions[subscription]
+	s.mu.Unlock()
+	if !ok {
+		return 0, errors.Wrap(ErrUnknownSubscription, subscription)
+	}
+
+	// the delivery stops when the replay is canceled or the subscription
+	// stops.
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-l.done:
+		case <-stop:
+		}
+		close(done)
+	}()
+
+	log.Infof("Replaying workflow %s to subscription %s", workflowID, subscription)
+
+	replayed := 0
+	started := from.LinkHash == "" && from.Time.IsZero()
+	cursor := from.Cursor
+	for {
+		rsp, err := s.fetch(ctx, workflowID, cursor)
+		if err != nil {
+			return replayed, err
+		}
+
+		links := rsp.WorkflowByRowID.Links
+		if len(links.Edges) == 0 {
+			break
+		}
+
+		var segments []*cs.Segment
+		for i := range links.Edges {
+			e := &links.Edges[i]
+			if !started && !from.starts(e) {
+				continue
+			}
+			started = true
+			if !e.Quarantined {
+				segments = append(segments, e.segment())
+			}
+		}
+
+		b := &Batch{
+			WorkflowID: workflowID,
+			Cursor:     links.PageInfo.EndCursor,
+			Segments:   l.filter.Apply(segments),
+			Replayed:   true,
+		}
+		if !l.sub.replay(b, done) {
+			if ctx.Err() != nil {
+				return replayed, errors.WithStack(ctx.Err())
+			}
+			return replayed, ErrReplayInterrupted
+		}
+		replayed += len(b.Segments)
+
+		if !links.PageInfo.HasNextPage {
+			break
+		}
+		cursor = links.PageInfo.EndCursor
+	}
+
+	if !started && from.LinkHash != "" {
+		return replayed, errors.Wrap(ErrReplayStartNotFound, from.LinkHash)
+	}
+
+	log.Infof("Replayed %d links of workflow %s to subscription %s", replayed, workflowID, subscription)
+
+	return replayed, nil
+}
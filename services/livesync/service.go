@@ -2,18 +2,30 @@ package livesync
 
 import (
 	"context"
+	"net"
+	"net/http"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stratumn/go-node/core/cfg"
+	"go.opencensus.io/stats/view"
 
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
 	"github.com/stratumn/go-connector/services/client"
 )
 
 // DefaultPollInterval is the default interval at which the livesync service calls Startumn APIs (in milliseconds).
 const DefaultPollInterval = 10000
 
+// CheckpointInterval is the minimum interval between two writes of the
+// checkpoint file.
+const CheckpointInterval = time.Second
+
+// DefaultStatusAddress is the default address of the HTTP status endpoint.
+const DefaultStatusAddress = "/ip4/127.0.0.1/tcp/8909"
+
 var log = logrus.WithField("service", "livesync")
 
 var (
@@ -26,6 +38,10 @@ type Service struct {
 	config *Config
 
 	synchronizer *synchronizer
+	discovery    Discovery
+
+	handler  http.Handler
+	grpcAuth *auth.GRPCAuth
 }
 
 // Config contains configuration options for the Livesync service.
@@ -33,8 +49,65 @@ type Config struct {
 	// ConfigVersion is the version of the configuration file.
 	ConfigVersion int `toml:"configuration_version" comment:"The version of the service configuration."`
 
-	PollInterval     time.Duration `toml:"poll_interval" comment:"The frenquency (in milliseconds) at which the livesync service polls data from Stratumn APIs."`
+	PollInterval     time.Duration `toml:"poll_interval" comment:"The interval (in milliseconds) at which the livesync service starts polling each workflow from Stratumn APIs."`
 	WatchedWorkflows []string      `toml:"watched_workflows" comment:"The IDs of the workflows to synchronize data from."`
+
+	// Discovery enables the sync of the workflows the account has access to.
+	Discovery         bool          `toml:"discovery" comment:"Whether to also sync the workflows the account of the connector has access to. New workflows are synced as soon as they are discovered."`
+	DiscoveryInterval time.Duration `toml:"discovery_interval" comment:"The frequency (in milliseconds) at which the workflows of the account are discovered."`
+
+	// IncludeWorkflows and ExcludeWorkflows select the discovered workflows.
+	IncludeWorkflows []string `toml:"include_workflows" comment:"The glob patterns of the IDs or names of the discovered workflows to sync. Leave empty to sync all of them."`
+	ExcludeWorkflows []string `toml:"exclude_workflows" comment:"The glob patterns of the IDs or names of the discovered workflows not to sync."`
+
+	// MinPollInterval and MaxPollInterval bound the interval of each workflow,
+	// which adapts to its activity.
+	MinPollInterval time.Duration `toml:"min_poll_interval" comment:"The interval (in milliseconds) at which busy workflows are polled."`
+	MaxPollInterval time.Duration `toml:"max_poll_interval" comment:"The interval (in milliseconds) at which idle workflows are polled. Failed polls are retried with a backoff up to this interval."`
+
+	// Transport is how the new links of the workflows are learned about.
+	Transport string `toml:"transport" comment:"How the new links are learned about: poll (poll the workflows at adaptive intervals) or push (subscribe to the new links through the Trace websocket endpoint, falling back to polling while disconnected)."`
+
+	// MaxConcurrentPolls is the number of workflows polled at the same time.
+	MaxConcurrentPolls int `toml:"max_concurrent_polls" comment:"The maximum number of workflows polled at the same time."`
+
+	// CheckpointFile is the file the cursors of the synced workflows are saved to.
+	// It must only be set when the listeners store the synced links durably:
+	// after a restart, they only receive the links following the checkpoint.
+	// The acknowledged cursors of the subscriptions are saved to it too.
+	CheckpointFile string `toml:"checkpoint_file" comment:"The file the sync cursors are saved to so that a restart resumes where the sync stopped. Only set it when all the listeners store the synced links durably, since the links synced before a restart are not delivered again. The subscriptions also save their acknowledged cursors to it: without it, they receive all the links again after a restart. Leave empty to sync from scratch on every start."`
+
+	// QueueSize is the number of updates queued per listener.
+	QueueSize int `toml:"queue_size" comment:"The maximum number of updates queued for each listener."`
+
+	// OverflowPolicy is applied when the queue of a listener is full.
+	OverflowPolicy string `toml:"overflow_policy" comment:"What to do when the queue of a listener is full: block (wait for the listener), drop-oldest (drop the oldest queued update) or disconnect (close the listener)."`
+
+	// MaxDeliveryAttempts is the number of times a batch is delivered to a
+	// subscription before it is moved to the dead letters.
+	MaxDeliveryAttempts int `toml:"max_delivery_attempts" comment:"The number of times a batch is delivered to a subscription before it is moved to the dead letters."`
+
+	// RedeliveryDelay is the time waited before delivering again a batch.
+	RedeliveryDelay time.Duration `toml:"redelivery_delay" comment:"The time (in milliseconds) waited before delivering again a batch that a subscription failed to process."`
+
+	// DeadLetterFile is the file the batches that could not be processed are appended to.
+	DeadLetterFile string `toml:"dead_letter_file" comment:"The file the batches that subscriptions failed to process are appended to. Leave empty to discard them."`
+
+	// VerifyIntegrity enables the verification of the synced links.
+	VerifyIntegrity bool `toml:"verify_integrity" comment:"Whether to verify the hash, the signatures and the previous link of the synced links before they are delivered."`
+
+	// IntegrityPolicy is applied to the links failing the verification.
+	IntegrityPolicy string `toml:"integrity_policy" comment:"What to do with the links failing the integrity verification: quarantine (withhold them from the listeners) or pass-through (deliver them anyway). Failures are reported as alerts in both cases."`
+
+	// StatusAddress is the address the HTTP status endpoint binds to.
+	StatusAddress string `toml:"status_address" comment:"Address of the HTTP status endpoint. Leave empty to disable it."`
+
+	// AccountURL is the URL of the Account API used to authenticate requests.
+	// Without it, the gRPC API only accepts the calls of local clients.
+	AccountURL string `toml:"account_url" comment:"The URL of Stratumn Account APIs used to authenticate status requests and gRPC calls. Required when the status address is not a loopback address. Without it, the gRPC API only accepts local clients."`
+
+	// AuthorizedAccounts restricts the access to a list of accounts.
+	AuthorizedAccounts []string `toml:"authorized_accounts" comment:"The IDs of the accounts allowed to get the status. Leave empty to allow any authenticated account."`
 }
 
 // ID returns the unique identifier of the service.
@@ -60,7 +133,19 @@ func (s *Service) Config() interface{} {
 	}
 
 	return Config{
-		PollInterval: DefaultPollInterval,
+		PollInterval:        DefaultPollInterval,
+		DiscoveryInterval:   DefaultDiscoveryInterval,
+		MinPollInterval:     DefaultMinPollInterval,
+		MaxPollInterval:     DefaultMaxPollInterval,
+		MaxConcurrentPolls:  DefaultMaxConcurrentPolls,
+		Transport:           PollTransport,
+		QueueSize:           DefaultQueueSize,
+		OverflowPolicy:      Block,
+		MaxDeliveryAttempts: DefaultMaxDeliveryAttempts,
+		RedeliveryDelay:     DefaultRedeliveryDelay,
+		DeadLetterFile:      DefaultDeadLetterFile,
+		IntegrityPolicy:     Quarantine,
+		StatusAddress:       DefaultStatusAddress,
 	}
 }
 
@@ -86,7 +171,60 @@ func (s *Service) Plug(exposed map[string]interface{}) error {
 		return errors.Wrap(ErrNotClient, "stratumnClient")
 	}
 
-	s.synchronizer = NewSycnhronizer(stratumnClient, s.config.WatchedWorkflows).(*synchronizer)
+	if err := ValidateTransport(s.config.Transport); err != nil {
+		return err
+	}
+
+	var checkpointer Checkpointer
+	if s.config.CheckpointFile != "" {
+		checkpointer = NewFileCheckpointer(s.config.CheckpointFile)
+	}
+
+	delivery := Delivery{
+		QueueSize:           s.config.QueueSize,
+		OverflowPolicy:      s.config.OverflowPolicy,
+		MaxDeliveryAttempts: s.config.MaxDeliveryAttempts,
+		RedeliveryDelay:     time.Millisecond * s.config.RedeliveryDelay,
+	}
+	if s.config.DeadLetterFile != "" {
+		delivery.DeadLetters = NewFileDeadLetterStore(s.config.DeadLetterFile)
+	}
+
+	discovery := Discovery{
+		Include: s.config.IncludeWorkflows,
+		Exclude: s.config.ExcludeWorkflows,
+	}
+	if err := discovery.Validate(); err != nil {
+		return err
+	}
+	s.discovery = discovery
+
+	integrity := Integrity{
+		Verify: s.config.VerifyIntegrity,
+		Policy: s.config.IntegrityPolicy,
+	}
+
+	sync, err := NewSycnhronizer(stratumnClient, s.config.WatchedWorkflows, checkpointer, delivery, integrity)
+	if err != nil {
+		return err
+	}
+	s.synchronizer = sync.(*synchronizer)
+
+	// The status is only served without authentication to local clients.
+	var middleware auth.Middleware = auth.NoAuthMiddleware{}
+	if s.config.StatusAddress != "" {
+		middleware, err = auth.NewEndpointMiddleware(s.config.StatusAddress, s.config.AccountURL, s.config.AuthorizedAccounts)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.handler = NewHandler(s.synchronizer, middleware)
+
+	// The gRPC API streams decrypted links.
+	if s.grpcAuth, err = auth.NewGRPCAuth(s.config.AccountURL, s.config.AuthorizedAccounts); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -98,29 +236,77 @@ func (s *Service) Expose() interface{} {
 }
 
 // Run starts the service.
+// It serves the HTTP status endpoint if an address is configured.
 func (s *Service) Run(ctx context.Context, running, stopping func()) error {
-	ticker := time.NewTicker(time.Millisecond * s.config.PollInterval)
-	running()
+	var lis net.Listener
+	if s.config.StatusAddress != "" {
+		var err error
+		if lis, err = httpapi.Listen(s.config.StatusAddress); err != nil {
+			return err
+		}
+	}
+
+	if err := view.Register(Views...); err != nil {
+		if lis != nil {
+			lis.Close()
+		}
+		return errors.WithStack(err)
+	}
+	defer view.Unregister(Views...)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-RUN_LOOP:
-	for {
-		select {
-		case <-ticker.C:
-			err := s.synchronizer.pollAndNotify(ctx)
+	serveErr := make(chan error, 1)
+	if lis != nil {
+		go func() {
+			err := httpapi.Serve(runCtx, lis, s.handler)
 			if err != nil {
-				stopping()
-				return err
+				// stop the sync when the status endpoint fails.
+				cancel()
 			}
-		case <-ctx.Done():
-			s.synchronizer.closeListeners()
-			break RUN_LOOP
+			serveErr <- err
+		}()
+	} else {
+		serveErr <- nil
+	}
+
+	discoveryDone := make(chan struct{})
+	if s.config.Discovery {
+		interval := time.Millisecond * s.config.DiscoveryInterval
+		if interval <= 0 {
+			interval = time.Millisecond * DefaultDiscoveryInterval
 		}
+		go func() {
+			s.synchronizer.runDiscovery(runCtx, s.discovery, interval)
+			close(discoveryDone)
+		}()
+	} else {
+		close(discoveryDone)
 	}
 
-	ticker.Stop()
+	// the checkpoints are saved until the last poll is done.
+	stopCheckpoints := make(chan struct{})
+	checkpointsDone := make(chan struct{})
+	go func() {
+		s.synchronizer.runCheckpoints(stopCheckpoints, CheckpointInterval)
+		close(checkpointsDone)
+	}()
+
+	poller := newPoller(s.synchronizer, s.config)
+	running()
+
+	err := poller.run(runCtx)
+	cancel()
+	close(stopCheckpoints)
+	<-checkpointsDone
+	<-discoveryDone
+	if e := <-serveErr; e != nil {
+		err = e
+	}
 	stopping()
 
-	return errors.WithStack(ctx.Err())
+	return err
 }
 
 // Migrator methods.
@@ -136,5 +322,62 @@ func (s *Service) Migrations() []cfg.MigrateHandler {
 		func(tree *cfg.Tree) error {
 			return tree.Set("poll_interval", 1)
 		},
+		func(tree *cfg.Tree) error {
+			if err := tree.Set("checkpoint_file", ""); err != nil {
+				return err
+			}
+			if err := tree.Set("queue_size", DefaultQueueSize); err != nil {
+				return err
+			}
+			if err := tree.Set("overflow_policy", Block); err != nil {
+				return err
+			}
+			if err := tree.Set("min_poll_interval", DefaultMinPollInterval); err != nil {
+				return err
+			}
+			if err := tree.Set("max_poll_interval", DefaultMaxPollInterval); err != nil {
+				return err
+			}
+			if err := tree.Set("max_concurrent_polls", DefaultMaxConcurrentPolls); err != nil {
+				return err
+			}
+			if err := tree.Set("discovery", false); err != nil {
+				return err
+			}
+			if err := tree.Set("discovery_interval", DefaultDiscoveryInterval); err != nil {
+				return err
+			}
+			if err := tree.Set("include_workflows", []string{}); err != nil {
+				return err
+			}
+			if err := tree.Set("exclude_workflows", []string{}); err != nil {
+				return err
+			}
+			if err := tree.Set("status_address", DefaultStatusAddress); err != nil {
+				return err
+			}
+			if err := tree.Set("account_url", ""); err != nil {
+				return err
+			}
+			if err := tree.Set("authorized_accounts", []string{}); err != nil {
+				return err
+			}
+			if err := tree.Set("max_delivery_attempts", DefaultMaxDeliveryAttempts); err != nil {
+				return err
+			}
+			if err := tree.Set("redelivery_delay", DefaultRedeliveryDelay); err != nil {
+				return err
+			}
+			if err := tree.Set("dead_letter_file", DefaultDeadLetterFile); err != nil {
+				return err
+			}
+			if err := tree.Set("verify_integrity", false); err != nil {
+				return err
+			}
+			if err := tree.Set("integrity_policy", Quarantine); err != nil {
+				return err
+			}
+			return tree.Set("transport", PollTransport)
+		},
 	}
 }
@@ -2,10 +2,19 @@ package livesync_test
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,10 +23,20 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	cs "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-crypto/keys"
 	"github.com/stretchr/testify/assert"
-
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	csutils "github.com/stratumn/go-connector/lib/chainscript"
+	"github.com/stratumn/go-connector/services/client"
 	"github.com/stratumn/go-connector/services/client/mockclient"
+	"github.com/stratumn/go-connector/services/decryption"
 	"github.com/stratumn/go-connector/services/livesync"
+	pb "github.com/stratumn/go-connector/services/livesync/grpc"
 )
 
 var (
@@ -82,7 +101,7 @@ func TestLivesyncService(t *testing.T) {
 			}).AnyTimes()
 
 		synchronizer := s.Expose().(livesync.Synchronizer)
-		updates, err := synchronizer.Register(nil)
+		updates, err := synchronizer.Register(nil, nil)
 		require.NoError(t, err)
 
 		go func() {
@@ -157,7 +176,7 @@ func TestLivesyncService(t *testing.T) {
 		// subscribe to the first workflow
 		updates, err := synchronizer.Register(livesync.WorkflowStates{
 			&livesync.WorkflowState{ID: watchedWorkflows[0]},
-		})
+		}, nil)
 		require.NoError(t, err)
 
 		go func() {
@@ -214,7 +233,7 @@ func TestLivesyncService(t *testing.T) {
 		synchronizer := s.Expose().(livesync.Synchronizer)
 		updates, err := synchronizer.Register(livesync.WorkflowStates{
 			&livesync.WorkflowState{ID: watchedWorkflows[0]},
-		})
+		}, nil)
 		require.NoError(t, err)
 
 		go func() {
@@ -278,7 +297,7 @@ func TestLivesyncService(t *testing.T) {
 		synchronizer := s.Expose().(livesync.Synchronizer)
 		updates, err := synchronizer.Register(livesync.WorkflowStates{
 			&livesync.WorkflowState{ID: watchedWorkflows[0], Cursor: cursor1},
-		})
+		}, nil)
 		require.NoError(t, err)
 
 		go func() {
@@ -343,7 +362,7 @@ func TestLivesyncService(t *testing.T) {
 
 		subscriber1, err := synchronizer.Register(livesync.WorkflowStates{
 			&livesync.WorkflowState{ID: watchedWorkflows[0], Cursor: cursor1},
-		})
+		}, nil)
 		require.NoError(t, err)
 
 		go func() {
@@ -356,7 +375,7 @@ func TestLivesyncService(t *testing.T) {
 			// therefore it should receive 2 updates.
 			subscriber2, err := synchronizer.Register(livesync.WorkflowStates{
 				&livesync.WorkflowState{ID: watchedWorkflows[0]},
-			})
+			}, nil)
 			require.NoError(t, err)
 			segments = <-subscriber2
 			assert.Len(t, segments, 2)
@@ -419,9 +438,11 @@ func TestLivesyncService(t *testing.T) {
 		defer cancel()
 
 		client := mockclient.NewMockStratumnClient(ctrl)
+		// the order is only guaranteed when polling one workflow at a time.
 		config := livesync.Config{
-			PollInterval:     20,
-			WatchedWorkflows: watchedWorkflows,
+			PollInterval:       20,
+			WatchedWorkflows:   watchedWorkflows,
+			MaxConcurrentPolls: 1,
 		}
 		s := &livesync.Service{}
 		s.SetConfig(config)
@@ -456,7 +477,7 @@ func TestLivesyncService(t *testing.T) {
 			&livesync.WorkflowState{ID: "1"},
 			&livesync.WorkflowState{ID: "2"},
 			&livesync.WorkflowState{ID: "3"},
-		})
+		}, nil)
 		require.NoError(t, err)
 
 		go func() {
@@ -536,3 +557,1860 @@ func TestCompareCursors(t *testing.T) {
 		assert.EqualError(t, err, `["wow", "amazing"]: cursor does not have an index`)
 	})
 }
+
+func TestLivesyncService_GRPC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	client := mockclient.NewMockStratumnClient(ctrl)
+	config := livesync.Config{
+		PollInterval:     10,
+		WatchedWorkflows: watchedWorkflows[:1],
+	}
+	s := &livesync.Service{}
+	s.SetConfig(config)
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"stratumnClient": client,
+	}))
+
+	// the first page is fetched by the poll and again for the stream.
+	client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+			err := json.Unmarshal([]byte(rspLastPage), rsp)
+			assert.NoError(t, err)
+			return nil
+		}).MinTimes(2)
+	client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+			err := json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			assert.NoError(t, err)
+			return nil
+		}).AnyTimes()
+
+	runCh := make(chan error)
+	go func() { runCh <- s.Run(ctx, func() {}, func() {}) }()
+
+	synchronizer := s.Expose().(livesync.Synchronizer)
+	cursor := func() string {
+		for _, w := range synchronizer.Status().Workflows {
+			if w.ID == watchedWorkflows[0] {
+				return w.Cursor
+			}
+		}
+		return ""
+	}
+	for cursor() != cursor3 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	gs := grpc.NewServer()
+	s.AddToGRPCServer(gs)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("Streams the past links without rewinding the workflow", func(t *testing.T) {
+		stream, err := pb.NewLivesyncClient(conn).Sync(ctx, &pb.SyncRequest{
+			Workflows: []*pb.WorkflowState{&pb.WorkflowState{Id: watchedWorkflows[0]}},
+		})
+		require.NoError(t, err)
+
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, msg.Segments, 1)
+
+		var segment cs.Segment
+		require.NoError(t, json.Unmarshal([]byte(msg.Segments[0].Raw), &segment))
+		assert.Equal(t, "Initialization", segment.Link.Meta.Action)
+
+		assert.Equal(t, cursor3, cursor())
+	})
+
+	t.Run("Rejects the workflows that are not synced", func(t *testing.T) {
+		stream, err := pb.NewLivesyncClient(conn).Sync(ctx, &pb.SyncRequest{
+			Workflows: []*pb.WorkflowState{&pb.WorkflowState{Id: "42"}},
+		})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, watchedWorkflows[:1], synchronizer.Workflows())
+	})
+
+	t.Run("Rejects malformed cursors", func(t *testing.T) {
+		stream, err := pb.NewLivesyncClient(conn).Sync(ctx, &pb.SyncRequest{
+			Workflows: []*pb.WorkflowState{&pb.WorkflowState{Id: watchedWorkflows[0], Cursor: "plap"}},
+		})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	cancel()
+	assert.EqualError(t, <-runCh, context.Canceled.Error())
+}
+
+func TestLivesyncService_GRPCAuth(t *testing.T) {
+	accountMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"accountId":"1"}`))
+	}))
+	defer accountMock.Close()
+
+	s := &livesync.Service{}
+	s.SetConfig(livesync.Config{
+		WatchedWorkflows: watchedWorkflows,
+		AccountURL:       accountMock.URL,
+	})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+	}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	gs := grpc.NewServer()
+	s.AddToGRPCServer(gs)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewLivesyncClient(conn)
+
+	t.Run("Rejects the calls without a valid token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer bad token")
+
+		_, err := client.Status(ctx, &pb.StatusRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = client.Replay(ctx, &pb.ReplayRequest{Subscription: "parser", WorkflowId: watchedWorkflows[0]})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		stream, err := client.Sync(context.Background(), &pb.SyncRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Accepts the calls with a valid token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+
+		rsp, err := client.Status(ctx, &pb.StatusRequest{})
+		require.NoError(t, err)
+		assert.Len(t, rsp.Workflows, len(watchedWorkflows))
+	})
+}
+
+func TestLivesyncService_Status(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// the first workflow has five links, three of them in the last page.
+	rspWithTotal := strings.Replace(rspLastPage, `"links":{`, `"links":{"totalCount":5,`, 1)
+	apiError := errors.New("unavailable")
+
+	client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+	client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+			if variables["id"] == watchedWorkflows[1] {
+				return apiError
+			}
+			if _, ok := variables["cursor"]; ok {
+				return json.Unmarshal([]byte(strings.Replace(rspWithoutLinks, `"links":{`, `"links":{"totalCount":5,`, 1)), rsp)
+			}
+			return json.Unmarshal([]byte(rspWithTotal), rsp)
+		}).AnyTimes()
+
+	s := &livesync.Service{}
+	s.SetConfig(livesync.Config{
+		PollInterval:     5,
+		WatchedWorkflows: watchedWorkflows,
+	})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"stratumnClient": client,
+	}))
+
+	synchronizer := s.Expose().(livesync.Synchronizer)
+	// this listener only subscribes to the first workflow and never consumes
+	// its updates.
+	_, err := synchronizer.Register(livesync.WorkflowStates{{ID: watchedWorkflows[0], Cursor: ""}}, nil)
+	require.NoError(t, err)
+
+	runCh := make(chan error)
+	go func() { runCh <- s.Run(ctx, func() {}, func() {}) }()
+
+	var status *livesync.Status
+	for {
+		status = synchronizer.Status()
+		if !status.Workflows[0].LastPoll.IsZero() && status.Workflows[1].Failures >= 2 {
+			break
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			require.Fail(t, "the workflows were not polled")
+		}
+	}
+
+	t.Run("Reports the workflows", func(t *testing.T) {
+		require.Len(t, status.Workflows, 2)
+
+		synced := status.Workflows[0]
+		assert.Equal(t, cursor3, synced.Cursor)
+		assert.Equal(t, uint64(3), synced.Links)
+		assert.Equal(t, uint64(2), synced.Lag)
+		assert.Empty(t, synced.LastError)
+		assert.Equal(t, 0, synced.Failures)
+
+		failing := status.Workflows[1]
+		assert.True(t, failing.LastPoll.IsZero())
+		assert.Equal(t, apiError.Error(), failing.LastError)
+		assert.Empty(t, failing.Cursor)
+	})
+
+	t.Run("Reports the listeners", func(t *testing.T) {
+		require.Len(t, status.Listeners, 1)
+
+		l := status.Listeners[0]
+		require.Len(t, l.Workflows, 1)
+		assert.Equal(t, cursor3, l.Workflows[0].Cursor)
+		assert.Equal(t, uint64(0), l.Workflows[0].Lag)
+		assert.Equal(t, uint64(0), l.Dropped)
+	})
+
+	t.Run("Serves the status over HTTP", func(t *testing.T) {
+		handler := livesync.NewHandler(synchronizer, auth.NoAuthMiddleware{})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rsp livesync.Status
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&rsp))
+		require.Len(t, rsp.Workflows, 2)
+		assert.Equal(t, watchedWorkflows[0], rsp.Workflows[0].ID)
+		assert.Equal(t, uint64(3), rsp.Workflows[0].Links)
+		assert.Len(t, rsp.Listeners, 1)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/status", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+
+	t.Run("Serves the status over gRPC", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		gs := grpc.NewServer()
+		s.AddToGRPCServer(gs)
+		go gs.Serve(lis)
+		defer gs.Stop()
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		rsp, err := pb.NewLivesyncClient(conn).Status(ctx, &pb.StatusRequest{})
+		require.NoError(t, err)
+		require.Len(t, rsp.Workflows, 2)
+		assert.Equal(t, uint64(3), rsp.Workflows[0].Links)
+		assert.Equal(t, uint64(2), rsp.Workflows[0].Lag)
+		assert.NotZero(t, rsp.Workflows[0].LastPoll)
+		assert.Equal(t, apiError.Error(), rsp.Workflows[1].LastError)
+		assert.NotZero(t, rsp.Workflows[1].Failures)
+		require.Len(t, rsp.Listeners, 1)
+	})
+
+	cancel()
+	assert.EqualError(t, <-runCh, context.Canceled.Error())
+}
+
+func TestLivesyncService_MissingAccountURL(t *testing.T) {
+	plug := func(address string) error {
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			WatchedWorkflows: watchedWorkflows,
+			StatusAddress:    address,
+		})
+		return s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+		})
+	}
+
+	t.Run("Serves the status without authentication on loopback addresses", func(t *testing.T) {
+		assert.NoError(t, plug("/ip4/127.0.0.1/tcp/8909"))
+	})
+
+	t.Run("Requires an account URL on other addresses", func(t *testing.T) {
+		err := plug("/ip4/0.0.0.0/tcp/8909")
+		assert.Equal(t, auth.ErrMissingAccountURL, errors.Cause(err))
+	})
+}
+
+func TestLivesyncService_Checkpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "livesync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("Saves and loads the cursors", func(t *testing.T) {
+		c := livesync.NewFileCheckpointer(filepath.Join(dir, "saved.json"))
+
+		states, err := c.Load()
+		require.NoError(t, err)
+		assert.Empty(t, states)
+
+		saved := livesync.WorkflowStates{{ID: "1", Cursor: cursor2}, {ID: "2", Cursor: ""}}
+		require.NoError(t, c.Save(saved))
+
+		states, err = c.Load()
+		require.NoError(t, err)
+		assert.Equal(t, saved, states)
+	})
+
+	t.Run("Detects corrupted checkpoints", func(t *testing.T) {
+		path := filepath.Join(dir, "corrupted.json")
+		c := livesync.NewFileCheckpointer(path)
+		require.NoError(t, c.Save(livesync.WorkflowStates{{ID: "1", Cursor: cursor2}}))
+
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		tampered := strings.Replace(string(b), cursor2, cursor3, 1)
+		require.NoError(t, ioutil.WriteFile(path, []byte(tampered), 0600))
+
+		_, err = c.Load()
+		assert.Equal(t, livesync.ErrCorruptedCheckpoint, errors.Cause(err))
+
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"version":1,"workf`), 0600))
+		_, err = c.Load()
+		assert.Equal(t, livesync.ErrCorruptedCheckpoint, errors.Cause(err))
+	})
+
+	t.Run("Rejects unknown versions", func(t *testing.T) {
+		path := filepath.Join(dir, "version.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"version":42,"checksum":"","workflows":[]}`), 0600))
+
+		_, err := livesync.NewFileCheckpointer(path).Load()
+		assert.Equal(t, livesync.ErrUnsupportedCheckpoint, errors.Cause(err))
+	})
+
+	t.Run("Resumes the sync from the checkpoint", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		path := filepath.Join(dir, "resume.json")
+		c := livesync.NewFileCheckpointer(path)
+		require.NoError(t, c.Save(livesync.WorkflowStates{{ID: watchedWorkflows[0], Cursor: cursor2}}))
+
+		ctrl := gomock.NewController(t)
+		client := mockclient.NewMockStratumnClient(ctrl)
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     10,
+			WatchedWorkflows: watchedWorkflows,
+			CheckpointFile:   path,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "cursor": cursor2, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspLastPage), rsp)
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "cursor": cursor3, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}).AnyTimes()
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[1], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}).AnyTimes()
+
+		err := s.Run(ctx, func() {}, func() {})
+		assert.EqualError(t, err, context.DeadlineExceeded.Error())
+
+		states, err := c.Load()
+		require.NoError(t, err)
+		state, ok := states.Get(watchedWorkflows[0])
+		require.True(t, ok)
+		assert.Equal(t, cursor3, state.Cursor)
+	})
+
+	t.Run("Keeps the cursors of the updates not consumed yet", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		path := filepath.Join(dir, "consumed.json")
+
+		ctrl := gomock.NewController(t)
+		client := mockclient.NewMockStratumnClient(ctrl)
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     10,
+			WatchedWorkflows: watchedWorkflows,
+			CheckpointFile:   path,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspLastPage), rsp)
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}).AnyTimes()
+
+		// the listener never consumes the synced segments.
+		_, err := s.Expose().(livesync.Synchronizer).Register(nil, nil)
+		require.NoError(t, err)
+
+		err = s.Run(ctx, func() {}, func() {})
+		assert.EqualError(t, err, context.DeadlineExceeded.Error())
+
+		states, err := livesync.NewFileCheckpointer(path).Load()
+		require.NoError(t, err)
+		state, ok := states.Get(watchedWorkflows[0])
+		require.True(t, ok)
+		assert.Empty(t, state.Cursor)
+	})
+
+	t.Run("Fails to start with a corrupted checkpoint", func(t *testing.T) {
+		path := filepath.Join(dir, "start.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte("not a checkpoint"), 0600))
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     10,
+			WatchedWorkflows: watchedWorkflows,
+			CheckpointFile:   path,
+		})
+		err := s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+		})
+		assert.Equal(t, livesync.ErrCorruptedCheckpoint, errors.Cause(err))
+	})
+}
+
+func TestLivesyncService_Delivery(t *testing.T) {
+	// the second workflow syncs a single link with a distinct action.
+	rspOtherWorkflow := strings.Replace(rspLastPage, "Initialization", "Completion", 1)
+
+	// sync polls three updates while a slow listener does not consume them.
+	sync := func(t *testing.T, policy string) (fast, slow [][]*cs.Segment) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		ctrl := gomock.NewController(t)
+		client := mockclient.NewMockStratumnClient(ctrl)
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     10,
+			WatchedWorkflows: watchedWorkflows,
+			QueueSize:        1,
+			OverflowPolicy:   policy,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		respond := func(rsp string) func(context.Context, string, map[string]interface{}, interface{}) error {
+			return func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+				return json.Unmarshal([]byte(rsp), r)
+			}
+		}
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(respond(rspWithNextPage)).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "cursor": cursor2, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(respond(rspLastPage)).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[1], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(respond(rspOtherWorkflow)).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(respond(rspWithoutLinks)).AnyTimes()
+
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		fastCh, err := synchronizer.Register(nil, nil)
+		require.NoError(t, err)
+		slowCh, err := synchronizer.Register(nil, nil)
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		for len(fast) < 3 {
+			select {
+			case segments := <-fastCh:
+				fast = append(fast, segments)
+			case <-ctx.Done():
+				require.Fail(t, "the fast listener did not receive all the updates")
+			}
+		}
+
+		// the slow listener only starts consuming once the sync stopped.
+		<-ctx.Done()
+		for segments := range slowCh {
+			slow = append(slow, segments)
+		}
+
+		return fast, slow
+	}
+
+	t.Run("Disconnects a slow listener without delaying the others", func(t *testing.T) {
+		fast, slow := sync(t, livesync.Disconnect)
+		require.Len(t, fast, 3)
+		assert.Equal(t, "Completion", fast[2][0].Link.Meta.Action)
+
+		// the last update overflowed the queue.
+		assert.NotEmpty(t, slow)
+		assert.True(t, len(slow) < 3, "the slow listener must be disconnected")
+		for _, segments := range slow {
+			assert.NotEqual(t, "Completion", segments[0].Link.Meta.Action)
+		}
+	})
+
+	t.Run("Drops the oldest updates of a slow listener", func(t *testing.T) {
+		fast, slow := sync(t, livesync.DropOldest)
+		require.Len(t, fast, 3)
+
+		require.NotEmpty(t, slow)
+		assert.True(t, len(slow) < 3, "updates must be dropped")
+		assert.Equal(t, "Completion", slow[len(slow)-1][0].Link.Meta.Action)
+	})
+
+	t.Run("Rejects unknown overflow policies", func(t *testing.T) {
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{OverflowPolicy: "ignore"})
+		err := s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+		})
+		assert.Equal(t, livesync.ErrBadOverflowPolicy, errors.Cause(err))
+	})
+}
+
+func TestLivesyncService_Subscription(t *testing.T) {
+	dir, err := ioutil.TempDir("", "livesync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	respond := func(rsp string) func(context.Context, string, map[string]interface{}, interface{}) error {
+		return func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+			return json.Unmarshal([]byte(rsp), r)
+		}
+	}
+
+	// start syncs the first workflow with the given config, from the
+	// beginning or from the second link.
+	start := func(t *testing.T, config livesync.Config, from string) (*livesync.Service, *mockclient.MockStratumnClient) {
+		ctrl := gomock.NewController(t)
+		client := mockclient.NewMockStratumnClient(ctrl)
+		config.PollInterval = 10
+		config.WatchedWorkflows = watchedWorkflows[:1]
+		s := &livesync.Service{}
+		s.SetConfig(config)
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		// the subscriptions only fetch the pages synced before they started.
+		if from == "" {
+			client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+				DoAndReturn(respond(rspWithNextPage)).MinTimes(1)
+		}
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "cursor": cursor2, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(respond(rspLastPage)).MinTimes(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(respond(rspWithoutLinks)).AnyTimes()
+
+		return s, client
+	}
+
+	receive := func(t *testing.T, ctx context.Context, batches <-chan *livesync.Batch) *livesync.Batch {
+		select {
+		case b := <-batches:
+			require.NotNil(t, b)
+			return b
+		case <-ctx.Done():
+			require.FailNow(t, "no batch was delivered")
+			return nil
+		}
+	}
+
+	t.Run("Saves the acknowledged cursor", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		path := filepath.Join(dir, "ack.json")
+		s, _ := start(t, livesync.Config{CheckpointFile: path}, "")
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		batches, err := synchronizer.Subscribe("indexer", nil)
+		require.NoError(t, err)
+
+		done := make(chan error)
+		go func() { done <- s.Run(ctx, func() {}, func() {}) }()
+
+		b := receive(t, ctx, batches)
+		assert.Equal(t, cursor2, b.Cursor)
+		assert.Len(t, b.Segments, 2)
+		assert.Equal(t, 1, b.Attempt)
+		b.Ack()
+
+		// a batch that is not acknowledged is delivered again.
+		b = receive(t, ctx, batches)
+		assert.Equal(t, cursor3, b.Cursor)
+		b.Nack(errors.New("index unavailable"))
+
+		b = receive(t, ctx, batches)
+		assert.Equal(t, cursor3, b.Cursor)
+		assert.Equal(t, 2, b.Attempt)
+		b.Ack()
+
+		cancel()
+		<-done
+
+		subscriptions, err := livesync.NewFileCheckpointer(path).LoadSubscriptions()
+		require.NoError(t, err)
+		state, ok := subscriptions["indexer"].Get(watchedWorkflows[0])
+		require.True(t, ok)
+		assert.Equal(t, cursor3, state.Cursor)
+	})
+
+	t.Run("Moves failing batches to the dead letters", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		path := filepath.Join(dir, "dead_letters.jsonl")
+		s, _ := start(t, livesync.Config{
+			MaxDeliveryAttempts: 2,
+			RedeliveryDelay:     1,
+			DeadLetterFile:      path,
+		}, "")
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		batches, err := synchronizer.Subscribe("indexer", nil)
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		for attempt := 1; attempt <= 2; attempt++ {
+			b := receive(t, ctx, batches)
+			assert.Equal(t, cursor2, b.Cursor)
+			assert.Equal(t, attempt, b.Attempt)
+			b.Nack(errors.New("index unavailable"))
+		}
+
+		// the next batch is delivered once the first one is dead.
+		b := receive(t, ctx, batches)
+		assert.Equal(t, cursor3, b.Cursor)
+		b.Ack()
+
+		content, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		require.Len(t, lines, 1)
+
+		var letter livesync.DeadLetter
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &letter))
+		assert.Equal(t, "indexer", letter.Subscription)
+		assert.Equal(t, watchedWorkflows[0], letter.WorkflowID)
+		assert.Equal(t, cursor2, letter.Cursor)
+		assert.Equal(t, 2, letter.Attempts)
+		assert.Equal(t, "index unavailable", letter.Error)
+		assert.Len(t, letter.Segments, 2)
+	})
+
+	t.Run("Resumes from the acknowledged cursor", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// the workflow was synced further than the subscription acknowledged.
+		path := filepath.Join(dir, "resume.json")
+		c := livesync.NewFileCheckpointer(path)
+		require.NoError(t, c.Save(livesync.WorkflowStates{{ID: watchedWorkflows[0], Cursor: cursor3}}))
+		require.NoError(t, c.SaveSubscription("indexer", livesync.WorkflowStates{{ID: watchedWorkflows[0], Cursor: cursor2}}))
+
+		s, _ := start(t, livesync.Config{CheckpointFile: path}, cursor2)
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		batches, err := synchronizer.Subscribe("indexer", nil)
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		b := receive(t, ctx, batches)
+		assert.Equal(t, cursor3, b.Cursor)
+		assert.Len(t, b.Segments, 1)
+		b.Ack()
+	})
+
+	t.Run("Delivers to a subscription while another one does not acknowledge", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		s, _ := start(t, livesync.Config{}, "")
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		stuck, err := synchronizer.Subscribe("stuck", nil)
+		require.NoError(t, err)
+		batches, err := synchronizer.Subscribe("indexer", nil)
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		// the first batch of this subscription is never acknowledged.
+		b := receive(t, ctx, stuck)
+		assert.Equal(t, cursor2, b.Cursor)
+
+		b = receive(t, ctx, batches)
+		assert.Equal(t, cursor2, b.Cursor)
+		b.Ack()
+
+		b = receive(t, ctx, batches)
+		assert.Equal(t, cursor3, b.Cursor)
+		b.Ack()
+
+		select {
+		case b := <-stuck:
+			assert.Fail(t, "a batch was delivered before the previous one was acknowledged", b.Cursor)
+		default:
+		}
+	})
+
+	t.Run("Delivers the synced pages without fetching them again", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		var mu sync.Mutex
+		calls := make(map[interface{}]int)
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls[variables["cursor"]]++
+
+				switch variables["cursor"] {
+				case nil:
+					return json.Unmarshal([]byte(rspWithNextPage), r)
+				case cursor2:
+					return json.Unmarshal([]byte(rspLastPage), r)
+				default:
+					return json.Unmarshal([]byte(rspWithoutLinks), r)
+				}
+			}).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{PollInterval: 10, WatchedWorkflows: watchedWorkflows[:1]})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		indexer, err := synchronizer.Subscribe("indexer", nil)
+		require.NoError(t, err)
+		archiver, err := synchronizer.Subscribe("archiver", nil)
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		for _, batches := range []<-chan *livesync.Batch{indexer, archiver} {
+			b := receive(t, ctx, batches)
+			assert.Equal(t, cursor2, b.Cursor)
+			b.Ack()
+			b = receive(t, ctx, batches)
+			assert.Equal(t, cursor3, b.Cursor)
+			b.Ack()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, calls[nil])
+		assert.Equal(t, 1, calls[cursor2])
+	})
+
+	t.Run("Rejects duplicate subscriptions", func(t *testing.T) {
+		s, _ := start(t, livesync.Config{}, "")
+		synchronizer := s.Expose().(livesync.Synchronizer)
+
+		batches, err := synchronizer.Subscribe("indexer", nil)
+		require.NoError(t, err)
+
+		_, err = synchronizer.Subscribe("indexer", nil)
+		assert.Equal(t, livesync.ErrSubscribed, errors.Cause(err))
+
+		_, err = synchronizer.Subscribe("", nil)
+		assert.Equal(t, livesync.ErrMissingSubscriptionName, errors.Cause(err))
+
+		// the name is available again once unsubscribed.
+		require.NoError(t, synchronizer.Unsubscribe(batches))
+		_, open := <-batches
+		assert.False(t, open)
+		_, err = synchronizer.Subscribe("indexer", nil)
+		assert.NoError(t, err)
+	})
+}
+
+func TestLivesyncService_Replay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "livesync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the links of the first page are created before the ones of the last page.
+	rspFirstPage := strings.Replace(rspWithNextPage, `"node":{"linkHash"`, `"node":{"createdAt":"2019-01-01T00:00:00Z","linkHash"`, 1)
+	rspFirstPage = strings.Replace(rspFirstPage, `"node":{"raw"`, `"node":{"createdAt":"2019-06-01T00:00:00Z","raw"`, 1)
+
+	client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+	client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+			switch variables["cursor"] {
+			case nil:
+				return json.Unmarshal([]byte(rspFirstPage), rsp)
+			case cursor2:
+				return json.Unmarshal([]byte(rspLastPage), rsp)
+			default:
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}
+		}).AnyTimes()
+
+	path := filepath.Join(dir, "replay.json")
+	s := &livesync.Service{}
+	s.SetConfig(livesync.Config{
+		WatchedWorkflows: watchedWorkflows[:1],
+		CheckpointFile:   path,
+	})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"stratumnClient": client,
+	}))
+
+	synchronizer := s.Expose().(livesync.Synchronizer)
+	batches, err := synchronizer.Subscribe("indexer", nil)
+	require.NoError(t, err)
+
+	// replay returns the number of links of each replayed batch.
+	replay := func(t *testing.T, from livesync.ReplayFrom) ([]int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		type result struct {
+			links int
+			err   error
+		}
+		resCh := make(chan result)
+		go func() {
+			links, err := synchronizer.Replay(ctx, "indexer", watchedWorkflows[0], from)
+			resCh <- result{links, err}
+		}()
+
+		var received []int
+		for {
+			select {
+			case b := <-batches:
+				assert.True(t, b.Replayed)
+				received = append(received, len(b.Segments))
+				b.Ack()
+			case res := <-resCh:
+				total := 0
+				for _, n := range received {
+					total += n
+				}
+				assert.Equal(t, total, res.links)
+				return received, res.err
+			case <-ctx.Done():
+				require.FailNow(t, "the replay did not end")
+			}
+		}
+	}
+
+	t.Run("Replays the whole workflow", func(t *testing.T) {
+		received, err := replay(t, livesync.ReplayFrom{})
+		require.NoError(t, err)
+		assert.Equal(t, []int{2, 1}, received)
+	})
+
+	t.Run("Replays from a cursor", func(t *testing.T) {
+		received, err := replay(t, livesync.ReplayFrom{Cursor: cursor2})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, received)
+	})
+
+	t.Run("Replays from a link hash", func(t *testing.T) {
+		received, err := replay(t, livesync.ReplayFrom{LinkHash: "DEADBEEFDEADBEEF"})
+		require.NoError(t, err)
+		assert.Equal(t, []int{2, 1}, received)
+
+		_, err = replay(t, livesync.ReplayFrom{LinkHash: "0123"})
+		assert.Equal(t, livesync.ErrReplayStartNotFound, errors.Cause(err))
+	})
+
+	t.Run("Replays from a time", func(t *testing.T) {
+		received, err := replay(t, livesync.ReplayFrom{Time: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 1}, received)
+	})
+
+	t.Run("Leaves the cursors unchanged", func(t *testing.T) {
+		status := synchronizer.Status()
+		assert.Empty(t, status.Workflows[0].Cursor)
+
+		subscriptions, err := livesync.NewFileCheckpointer(path).LoadSubscriptions()
+		require.NoError(t, err)
+		assert.Empty(t, subscriptions["indexer"])
+	})
+
+	t.Run("Rejects bad replays", func(t *testing.T) {
+		ctx := context.Background()
+
+		_, err := synchronizer.Replay(ctx, "unknown", watchedWorkflows[0], livesync.ReplayFrom{})
+		assert.Equal(t, livesync.ErrUnknownSubscription, errors.Cause(err))
+
+		_, err = synchronizer.Replay(ctx, "indexer", watchedWorkflows[0], livesync.ReplayFrom{Cursor: cursor2, LinkHash: "deadbeef"})
+		assert.Equal(t, livesync.ErrBadReplayStart, errors.Cause(err))
+	})
+}
+
+func TestLivesyncService_Integrity(t *testing.T) {
+	_, key, err := keys.GenerateKey(x509.ECDSA)
+	require.NoError(t, err)
+
+	link := func(mapID string, parent []byte) *cs.Link {
+		b := cs.NewLinkBuilder("p", mapID).WithAction("verify")
+		if parent != nil {
+			b = b.WithParent(parent)
+		}
+		l, err := b.Build()
+		require.NoError(t, err)
+		require.NoError(t, l.Sign(key, "[version,data,meta]"))
+		return l
+	}
+	hash := func(l *cs.Link) []byte {
+		lh, err := l.Hash()
+		require.NoError(t, err)
+		return lh
+	}
+
+	// the first two links are valid, the other ones fail the verification.
+	l1 := link("map", nil)
+	l2 := link("map", hash(l1))
+	l3 := link("map", hash(l2))
+	l4 := link("map", hash(l2))
+	l4.Meta.Action = "tampered"
+	l5 := link("map", []byte("unknown"))
+
+	type edge struct {
+		Cursor string `json:"cursor"`
+		Node   struct {
+			LinkHash string   `json:"linkHash"`
+			Raw      *cs.Link `json:"raw"`
+		} `json:"node"`
+	}
+	edges := make([]edge, 5)
+	for i, l := range []*cs.Link{l1, l2, l3, l4, l5} {
+		edges[i].Cursor = makeCursor(i + 1)
+		edges[i].Node.LinkHash = hex.EncodeToString(hash(l))
+		edges[i].Node.Raw = l
+	}
+	edges[2].Node.LinkHash = hex.EncodeToString([]byte("mismatch"))
+
+	var rsp struct {
+		WorkflowByRowID struct {
+			Links struct {
+				Edges    []edge `json:"edges"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+			} `json:"links"`
+		} `json:"workflowByRowId"`
+	}
+	rsp.WorkflowByRowID.Links.Edges = edges
+	rsp.WorkflowByRowID.Links.PageInfo.EndCursor = makeCursor(5)
+	rspLinks, err := json.Marshal(rsp)
+	require.NoError(t, err)
+
+	// sync returns the actions of the delivered links and the status once the
+	// page is delivered.
+	sync := func(t *testing.T, policy string) ([]string, *livesync.Status) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// the links are verified before they are decrypted.
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().CallTraceGqlRaw(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+				return json.Unmarshal(rspLinks, r)
+			}).Times(1)
+		client.EXPECT().CallTraceGqlRaw(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+				return json.Unmarshal([]byte(rspWithoutLinks), r)
+			}).AnyTimes()
+		client.EXPECT().DecryptLinks(gomock.Any(), gomock.Any()).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     5,
+			WatchedWorkflows: watchedWorkflows[:1],
+			VerifyIntegrity:  true,
+			IntegrityPolicy:  policy,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		ch, err := synchronizer.Register(nil, nil)
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		var actions []string
+		select {
+		case segments := <-ch:
+			for _, segment := range segments {
+				actions = append(actions, segment.Link.Meta.Action)
+			}
+		case <-ctx.Done():
+			require.FailNow(t, "the links were not delivered")
+		}
+
+		return actions, synchronizer.Status()
+	}
+
+	checkAlerts := func(t *testing.T, status *livesync.Status, quarantined bool) {
+		require.Len(t, status.Alerts, 3)
+		assert.Equal(t, uint64(3), status.Workflows[0].IntegrityAlerts)
+
+		reasons := []error{livesync.ErrLinkHashMismatch, livesync.ErrBadSignature, livesync.ErrUnknownPrevLink}
+		for i, alert := range status.Alerts {
+			assert.Equal(t, watchedWorkflows[0], alert.WorkflowID)
+			assert.Equal(t, "map", alert.MapID)
+			assert.Equal(t, edges[i+2].Node.LinkHash, alert.LinkHash)
+			assert.Contains(t, alert.Reason, reasons[i].Error())
+			assert.Equal(t, quarantined, alert.Quarantined)
+		}
+	}
+
+	t.Run("Quarantines the links failing the verification", func(t *testing.T) {
+		actions, status := sync(t, livesync.Quarantine)
+		assert.Equal(t, []string{"verify", "verify"}, actions)
+		checkAlerts(t, status, true)
+	})
+
+	t.Run("Passes through the links failing the verification", func(t *testing.T) {
+		actions, status := sync(t, livesync.PassThrough)
+		assert.Equal(t, []string{"verify", "verify", "verify", "tampered", "verify"}, actions)
+		checkAlerts(t, status, false)
+	})
+
+	t.Run("Leaves the quarantined links out of the fetched pages", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// the first page only contains links failing the verification.
+		page := func(edges []edge, hasNextPage bool) []byte {
+			rsp.WorkflowByRowID.Links.Edges = edges
+			rsp.WorkflowByRowID.Links.PageInfo.HasNextPage = hasNextPage
+			rsp.WorkflowByRowID.Links.PageInfo.EndCursor = edges[len(edges)-1].Cursor
+			b, err := json.Marshal(rsp)
+			require.NoError(t, err)
+			return b
+		}
+		quarantined := page(edges[2:], true)
+		valid := page(edges[:2], false)
+
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().CallTraceGqlRaw(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+				return json.Unmarshal(quarantined, r)
+			}).Times(1)
+		client.EXPECT().CallTraceGqlRaw(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination, "cursor": makeCursor(5)}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, r interface{}) error {
+				return json.Unmarshal(valid, r)
+			}).Times(1)
+		client.EXPECT().DecryptLinks(gomock.Any(), gomock.Any()).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			WatchedWorkflows: watchedWorkflows[:1],
+			VerifyIntegrity:  true,
+			IntegrityPolicy:  livesync.Quarantine,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		segments, cursor, err := synchronizer.Fetch(ctx, watchedWorkflows[0], "")
+		require.NoError(t, err)
+		assert.Equal(t, makeCursor(2), cursor)
+		require.Len(t, segments, 2)
+		assert.Equal(t, hash(l1), segments[0].Meta.LinkHash)
+		assert.Equal(t, hash(l2), segments[1].Meta.LinkHash)
+	})
+
+	t.Run("Rejects unknown integrity policies", func(t *testing.T) {
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{VerifyIntegrity: true, IntegrityPolicy: "ignore"})
+		err := s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+		})
+		assert.Equal(t, livesync.ErrBadIntegrityPolicy, errors.Cause(err))
+	})
+}
+
+func TestLivesyncService_EncryptedIntegrity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	encryptionPub, encryptionKey, err := keys.GenerateKey(x509.RSA)
+	require.NoError(t, err)
+	_, signingKey, err := keys.GenerateKey(x509.ECDSA)
+	require.NoError(t, err)
+
+	// the link is encrypted for the connector before it is signed.
+	data := map[string]interface{}{"secret": "plap"}
+	link, err := cs.NewLinkBuilder("p", "map").WithAction("verify").WithData(data).Build()
+	require.NoError(t, err)
+	require.NoError(t, csutils.EncryptLink(ctx, link, []*csutils.PublicKeyInfo{{ID: "1", PublicKey: encryptionPub}}))
+	require.NoError(t, link.Sign(signingKey, "[version,data,meta]"))
+	lh, err := link.Hash()
+	require.NoError(t, err)
+	encrypted := link.Data
+
+	linkJSON, err := json.Marshal(link)
+	require.NoError(t, err)
+	rspEncrypted := fmt.Sprintf(`{"workflowByRowId":{"links":{"edges":[{"cursor":"%s","node":{"linkHash":"%s","raw":%s}}],"pageInfo":{"endCursor":"%s"}}}}`,
+		cursor1, hex.EncodeToString(lh), linkJSON, cursor1)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `{"token":"token"}`)
+		case "/graphql":
+			var req struct {
+				Variables map[string]interface{}
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if _, ok := req.Variables["cursor"]; ok {
+				fmt.Fprintf(w, `{"data":%s}`, rspWithoutLinks)
+				return
+			}
+			fmt.Fprintf(w, `{"data":%s}`, rspEncrypted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+
+	// run starts a service and waits until it is running.
+	run := func(s interface {
+		Run(context.Context, func(), func()) error
+	}) {
+		running := make(chan struct{})
+		go s.Run(ctx, func() { close(running) }, func() {})
+		select {
+		case <-running:
+		case <-ctx.Done():
+			require.FailNow(t, "the service did not start")
+		}
+	}
+
+	decryptionService := &decryption.Service{}
+	decryptionService.SetConfig(decryption.Config{EncryptionPrivateKey: string(encryptionKey)})
+	run(decryptionService)
+
+	clientService := &client.Service{}
+	config := clientService.Config().(client.Config)
+	config.TraceURL = api.URL
+	config.AccountURL = api.URL
+	config.SigningPrivateKey = string(signingKey)
+	clientService.SetConfig(config)
+	require.NoError(t, clientService.Plug(map[string]interface{}{
+		"decryption": decryptionService.Expose(),
+	}))
+	run(clientService)
+
+	s := &livesync.Service{}
+	s.SetConfig(livesync.Config{
+		PollInterval:     5,
+		WatchedWorkflows: watchedWorkflows[:1],
+		VerifyIntegrity:  true,
+		IntegrityPolicy:  livesync.Quarantine,
+	})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"stratumnClient": clientService.Expose(),
+	}))
+
+	synchronizer := s.Expose().(livesync.Synchronizer)
+	ch, err := synchronizer.Register(nil, nil)
+	require.NoError(t, err)
+
+	go s.Run(ctx, func() {}, func() {})
+
+	select {
+	case segments := <-ch:
+		require.Len(t, segments, 1)
+		assert.NotEqual(t, encrypted, segments[0].Link.Data)
+
+		var decrypted map[string]interface{}
+		require.NoError(t, json.Unmarshal(segments[0].Link.Data, &decrypted))
+		assert.Equal(t, data, decrypted)
+	case <-ctx.Done():
+		require.FailNow(t, "the encrypted link was not delivered")
+	}
+
+	status := synchronizer.Status()
+	assert.Empty(t, status.Alerts)
+	assert.Zero(t, status.Workflows[0].IntegrityAlerts)
+}
+
+func TestLivesyncService_Concurrency(t *testing.T) {
+	// respond returns the last page to the first call of a workflow and then
+	// no new link, so that only the services registering from cursor zero
+	// get updates.
+	respond := func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+		if _, ok := variables["cursor"]; ok {
+			return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+		}
+		return json.Unmarshal([]byte(rspLastPage), rsp)
+	}
+
+	newService := func(t *testing.T, queueSize int) *livesync.Service {
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(respond).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     1,
+			WatchedWorkflows: watchedWorkflows,
+			QueueSize:        queueSize,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+		return s
+	}
+
+	t.Run("Registers and unregisters while polling", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		s := newService(t, 1)
+		synchronizer := s.Expose().(livesync.Synchronizer)
+
+		runDone := make(chan struct{})
+		go func() {
+			s.Run(ctx, func() {}, func() {})
+			close(runDone)
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					states := livesync.WorkflowStates{{ID: watchedWorkflows[i%2], Cursor: ""}}
+					ch, err := synchronizer.Register(states, nil)
+					if !assert.NoError(t, err) {
+						return
+					}
+					assert.NotEmpty(t, synchronizer.Workflows())
+
+					select {
+					case <-ch:
+					case <-time.After(5 * time.Millisecond):
+					}
+
+					assert.NoError(t, synchronizer.Unregister(ch))
+					// the channel is closed once unregistered.
+					for range ch {
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		cancel()
+		<-runDone
+	})
+
+	t.Run("Unregisters a listener blocking the poll", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		s := newService(t, 1)
+		synchronizer := s.Expose().(livesync.Synchronizer)
+
+		// this listener never consumes its updates: the first update is
+		// waiting to be received, the second one fills the queue and the
+		// third one blocks the poll.
+		blocking, err := synchronizer.Register(livesync.WorkflowStates{
+			{ID: watchedWorkflows[0], Cursor: ""},
+			{ID: watchedWorkflows[1], Cursor: ""},
+			{ID: "3", Cursor: ""},
+		}, nil)
+		require.NoError(t, err)
+
+		runDone := make(chan struct{})
+		go func() {
+			s.Run(ctx, func() {}, func() {})
+			close(runDone)
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		unregistered := make(chan error)
+		go func() { unregistered <- synchronizer.Unregister(blocking) }()
+
+		select {
+		case err := <-unregistered:
+			assert.NoError(t, err)
+		case <-ctx.Done():
+			require.Fail(t, "unregistering a blocking listener must not wait for the poll")
+		}
+
+		// the poll resumes.
+		ch, err := synchronizer.Register(livesync.WorkflowStates{{ID: watchedWorkflows[0], Cursor: ""}}, nil)
+		require.NoError(t, err)
+		select {
+		case segments := <-ch:
+			assert.Len(t, segments, 1)
+		case <-ctx.Done():
+			require.Fail(t, "the poll did not resume")
+		}
+
+		cancel()
+		<-runDone
+	})
+
+	t.Run("Registers while a listener blocks the poll", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		s := newService(t, 1)
+		synchronizer := s.Expose().(livesync.Synchronizer)
+
+		// this listener never consumes its updates and blocks the poll.
+		_, err := synchronizer.Register(livesync.WorkflowStates{
+			{ID: watchedWorkflows[0], Cursor: ""},
+			{ID: watchedWorkflows[1], Cursor: ""},
+			{ID: "3", Cursor: ""},
+		}, nil)
+		require.NoError(t, err)
+
+		runDone := make(chan struct{})
+		go func() {
+			s.Run(ctx, func() {}, func() {})
+			close(runDone)
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		registered := make(chan error)
+		go func() {
+			assert.NotEmpty(t, synchronizer.Workflows())
+			_, err := synchronizer.Register(nil, nil)
+			registered <- err
+		}()
+
+		select {
+		case err := <-registered:
+			assert.NoError(t, err)
+		case <-ctx.Done():
+			require.Fail(t, "registering must not wait for a blocking listener")
+		}
+
+		// the blocked update is given up when the synchronizer stops.
+		cancel()
+		select {
+		case <-runDone:
+		case <-time.After(time.Second):
+			require.Fail(t, "the synchronizer did not stop")
+		}
+	})
+
+	t.Run("Rejects unknown channels", func(t *testing.T) {
+		synchronizer := newService(t, 1).Expose().(livesync.Synchronizer)
+
+		ch, err := synchronizer.Register(nil, nil)
+		require.NoError(t, err)
+		require.NoError(t, synchronizer.Unregister(ch))
+
+		_, ok := <-ch
+		assert.False(t, ok)
+		assert.Equal(t, livesync.ErrUnknownListener, synchronizer.Unregister(ch))
+		assert.Equal(t, livesync.ErrUnknownListener, synchronizer.Unregister(make(chan []*cs.Segment)))
+	})
+}
+
+func TestLivesyncService_Polling(t *testing.T) {
+	run := func(t *testing.T, config livesync.Config, timeout time.Duration, call func(map[string]interface{}, interface{}) error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return call(variables, rsp)
+			}).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(config)
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		err := s.Run(ctx, func() {}, func() {})
+		assert.EqualError(t, err, context.DeadlineExceeded.Error())
+	}
+
+	t.Run("Polls workflows concurrently", func(t *testing.T) {
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		polled := map[string]bool{}
+
+		run(t, livesync.Config{
+			PollInterval:       5,
+			WatchedWorkflows:   []string{"1", "2", "3", "4", "5"},
+			MaxConcurrentPolls: 2,
+		}, 100*time.Millisecond, func(variables map[string]interface{}, rsp interface{}) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			polled[variables["id"].(string)] = true
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+		})
+
+		assert.Equal(t, 2, maxRunning)
+		assert.Len(t, polled, 5)
+	})
+
+	t.Run("Backs off after errors", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := 0
+
+		run(t, livesync.Config{
+			PollInterval:     2,
+			MaxPollInterval:  200,
+			WatchedWorkflows: []string{"1"},
+		}, 100*time.Millisecond, func(variables map[string]interface{}, rsp interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return errors.New("unavailable")
+		})
+
+		// a fixed interval would poll about fifty times.
+		assert.True(t, calls > 1, "failed polls must be retried")
+		assert.True(t, calls < 15, "failed polls must back off, got %d calls", calls)
+	})
+
+	t.Run("Waits the maximum interval after a rejected poll", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := 0
+
+		run(t, livesync.Config{
+			PollInterval:     2,
+			MaxPollInterval:  200,
+			WatchedWorkflows: []string{"1"},
+		}, 100*time.Millisecond, func(variables map[string]interface{}, rsp interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return &client.Error{Kind: client.ErrNotFound, Status: http.StatusNotFound}
+		})
+
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Polls busy workflows faster than idle ones", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := map[string]int{}
+
+		run(t, livesync.Config{
+			PollInterval:     10,
+			MinPollInterval:  2,
+			MaxPollInterval:  40,
+			WatchedWorkflows: watchedWorkflows,
+		}, 100*time.Millisecond, func(variables map[string]interface{}, rsp interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			id := variables["id"].(string)
+			calls[id]++
+			// the first workflow gets a new link on every poll.
+			if id == watchedWorkflows[0] {
+				return json.Unmarshal([]byte(rspLastPage), rsp)
+			}
+			return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+		})
+
+		busy, idle := calls[watchedWorkflows[0]], calls[watchedWorkflows[1]]
+		assert.True(t, idle > 0)
+		assert.True(t, busy > 2*idle, "busy: %d calls, idle: %d calls", busy, idle)
+	})
+}
+
+func TestLivesyncService_Push(t *testing.T) {
+	t.Run("Rejects unknown transports", func(t *testing.T) {
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{Transport: "carrier-pigeon"})
+		err := s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+		})
+		assert.Equal(t, livesync.ErrBadTransport, errors.Cause(err))
+	})
+
+	t.Run("Polls the notified workflows and falls back to polling on disconnect", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		cursor4 := makeCursor(4)
+		rspNewLink := strings.Replace(rspLastPage, cursor3, cursor4, -1)
+
+		// the subscriptions stand in for the Trace websocket endpoint.
+		first, second := make(chan json.RawMessage), make(chan json.RawMessage)
+		subscriptions := make(chan chan json.RawMessage, 2)
+		subscriptions <- first
+		subscriptions <- second
+		subscribed := make(chan struct{}, 2)
+		caughtUp := make(chan struct{}, 10)
+
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().SubscribeTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"ids": []string{"1"}})).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}) (<-chan json.RawMessage, error) {
+				select {
+				case events := <-subscriptions:
+					subscribed <- struct{}{}
+					return events, nil
+				default:
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+			}).MinTimes(2)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": "1", "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspLastPage), rsp)
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": "1", "cursor": cursor3, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspNewLink), rsp)
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": "1", "cursor": cursor4, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				caughtUp <- struct{}{}
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}).MinTimes(2)
+
+		// the workflows are never due within the test without notification.
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     10000,
+			MinPollInterval:  5,
+			MaxPollInterval:  10000,
+			WatchedWorkflows: []string{"1"},
+			Transport:        livesync.PushTransport,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		updates, err := synchronizer.Register(nil, nil)
+		require.NoError(t, err)
+
+		runDone := make(chan error)
+		go func() { runDone <- s.Run(ctx, func() {}, func() {}) }()
+
+		receive := func(t *testing.T) []*cs.Segment {
+			select {
+			case segments := <-updates:
+				return segments
+			case <-ctx.Done():
+				require.Fail(t, "no update received")
+				return nil
+			}
+		}
+
+		// the workflows are polled once subscribed to catch up.
+		<-subscribed
+		segments := receive(t)
+		require.Len(t, segments, 1)
+
+		// a notification polls the workflow from its cursor.
+		first <- json.RawMessage(`{"linkCreated":{"workflowRowId":"1"}}`)
+		segments = receive(t)
+		require.Len(t, segments, 1)
+
+		// the notifications of the workflows that are not synced are ignored.
+		first <- json.RawMessage(`{"linkCreated":{"workflowRowId":"42"}}`)
+
+		// the poller subscribes again after a disconnection and polls the
+		// workflows from their cursor.
+		close(first)
+		select {
+		case <-subscribed:
+		case <-ctx.Done():
+			require.Fail(t, "the poller did not subscribe again")
+		}
+		wait := func(t *testing.T) {
+			select {
+			case <-caughtUp:
+			case <-ctx.Done():
+				require.Fail(t, "the workflow was not polled")
+			}
+		}
+		wait(t)
+
+		second <- json.RawMessage(`{"linkCreated":{"workflowRowId":"1"}}`)
+		wait(t)
+
+		cancel()
+		assert.EqualError(t, <-runDone, context.Canceled.Error())
+	})
+
+	t.Run("Subscribes again to include the discovered workflows", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		previous := make(chan context.Context, 1)
+		subscribed := make(chan struct{})
+		resubscribed := make(chan struct{}, 1)
+
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().SubscribeTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"ids": []string{"1"}})).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}) (<-chan json.RawMessage, error) {
+				previous <- ctx
+				close(subscribed)
+				return make(chan json.RawMessage), nil
+			}).Times(1)
+		client.EXPECT().SubscribeTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"ids": []string{"1", "2"}})).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}) (<-chan json.RawMessage, error) {
+				resubscribed <- struct{}{}
+				return make(chan json.RawMessage), nil
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				if variables != nil {
+					return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+				}
+				// the workflow is discovered once the poller subscribed.
+				select {
+				case <-subscribed:
+					return json.Unmarshal([]byte(`{"allWorkflows":{"nodes":[{"rowId":"2","name":"Onboarding"}]}}`), rsp)
+				default:
+					return json.Unmarshal([]byte(`{"allWorkflows":{"nodes":[]}}`), rsp)
+				}
+			}).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:      10000,
+			MinPollInterval:   5,
+			MaxPollInterval:   10000,
+			WatchedWorkflows:  []string{"1"},
+			Discovery:         true,
+			DiscoveryInterval: 10,
+			Transport:         livesync.PushTransport,
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		runDone := make(chan error)
+		go func() { runDone <- s.Run(ctx, func() {}, func() {}) }()
+
+		var first context.Context
+		select {
+		case first = <-previous:
+		case <-ctx.Done():
+			require.Fail(t, "the poller did not subscribe")
+		}
+		select {
+		case <-resubscribed:
+		case <-ctx.Done():
+			require.Fail(t, "the poller did not subscribe to the discovered workflow")
+		}
+
+		// the previous subscription is closed once the new one is open.
+		select {
+		case <-first.Done():
+		case <-ctx.Done():
+			require.Fail(t, "the previous subscription was not closed")
+		}
+
+		cancel()
+		assert.EqualError(t, <-runDone, context.Canceled.Error())
+	})
+}
+
+func TestLivesyncService_Discovery(t *testing.T) {
+	t.Run("Selects workflows matching the patterns", func(t *testing.T) {
+		d := livesync.Discovery{
+			Include: []string{"Claims *", "42"},
+			Exclude: []string{"*staging*"},
+		}
+		require.NoError(t, d.Validate())
+
+		assert.True(t, d.Match("1", "Claims management"))
+		assert.True(t, d.Match("42", "Onboarding"))
+		assert.False(t, d.Match("2", "Onboarding"))
+		assert.False(t, d.Match("3", "Claims staging"))
+		assert.True(t, livesync.Discovery{}.Match("4", "Onboarding"))
+	})
+
+	t.Run("Rejects malformed patterns", func(t *testing.T) {
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{ExcludeWorkflows: []string{"[a-"}})
+		err := s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(gomock.NewController(t)),
+		})
+		assert.Equal(t, livesync.ErrBadWorkflowPattern, errors.Cause(err))
+	})
+
+	t.Run("Syncs the discovered workflows", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		client := mockclient.NewMockStratumnClient(gomock.NewController(t))
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Nil(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(`{"allWorkflows":{"nodes":[{"rowId":"1","name":"Claims management"},{"rowId":"2","name":"Claims staging"}]}}`), rsp)
+			}).MinTimes(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": "1", "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspLastPage), rsp)
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": "1", "cursor": cursor3, "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}).AnyTimes()
+
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:      5,
+			Discovery:         true,
+			DiscoveryInterval: 10,
+			ExcludeWorkflows:  []string{"*staging*"},
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		// the listener registered for all the workflows receives the
+		// updates of the discovered ones.
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		updates, err := synchronizer.Register(nil, nil)
+		require.NoError(t, err)
+
+		runDone := make(chan error)
+		go func() { runDone <- s.Run(ctx, func() {}, func() {}) }()
+
+		select {
+		case segments := <-updates:
+			assert.Len(t, segments, 1)
+		case <-ctx.Done():
+			require.Fail(t, "the discovered workflow was not synced")
+		}
+		assert.Equal(t, []string{"1"}, synchronizer.Workflows())
+
+		assert.EqualError(t, <-runDone, context.DeadlineExceeded.Error())
+	})
+}
+
+func TestLivesyncService_Filter(t *testing.T) {
+	l, err := cs.NewLinkBuilder("211", "map1").
+		WithAction("Consent request").
+		WithProcessState("FREE").
+		WithTags("USER-ID-23", "urgent").
+		WithMetadata(map[string]interface{}{"formId": "2945", "ownerId": 164}).
+		Build()
+	require.NoError(t, err)
+	segment, err := l.Segmentify()
+	require.NoError(t, err)
+
+	t.Run("Matches segments", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			filter *livesync.Filter
+			match  bool
+		}{
+			{"nil filter", nil, true},
+			{"empty filter", &livesync.Filter{}, true},
+			{"workflow", &livesync.Filter{Workflows: []string{"212", "211"}}, true},
+			{"other workflow", &livesync.Filter{Workflows: []string{"212"}}, false},
+			{"action", &livesync.Filter{Actions: []string{"Consent request"}}, true},
+			{"other action", &livesync.Filter{Actions: []string{"Initialization"}}, false},
+			{"process state", &livesync.Filter{ProcessStates: []string{"FREE"}}, true},
+			{"other process state", &livesync.Filter{ProcessStates: []string{"DONE"}}, false},
+			{"tag", &livesync.Filter{Tags: []string{"urgent", "late"}}, true},
+			{"other tag", &livesync.Filter{Tags: []string{"late"}}, false},
+			{"map", &livesync.Filter{MapIDs: []string{"map1"}}, true},
+			{"other map", &livesync.Filter{MapIDs: []string{"map2"}}, false},
+			{"metadata", &livesync.Filter{Metadata: livesync.MetadataEquals(map[string]string{"formId": "2945", "ownerId": "164"})}, true},
+			{"other metadata", &livesync.Filter{Metadata: livesync.MetadataEquals(map[string]string{"formId": "2944"})}, false},
+			{"all criteria", &livesync.Filter{Workflows: []string{"211"}, Actions: []string{"Consent request"}, Tags: []string{"urgent"}}, true},
+			{"one failing criterion", &livesync.Filter{Workflows: []string{"211"}, Actions: []string{"Initialization"}}, false},
+		}
+		for _, tt := range tests {
+			assert.Equal(t, tt.match, tt.filter.Match(segment), tt.name)
+		}
+	})
+
+	t.Run("Delivers the matching segments only", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		ctrl := gomock.NewController(t)
+		client := mockclient.NewMockStratumnClient(ctrl)
+		s := &livesync.Service{}
+		s.SetConfig(livesync.Config{
+			PollInterval:     10,
+			WatchedWorkflows: watchedWorkflows[:1],
+		})
+		require.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": client,
+		}))
+
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{"id": watchedWorkflows[0], "limit": livesync.DefaultPagination}), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspWithNextPage), rsp)
+			}).Times(1)
+		client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(rspWithoutLinks), rsp)
+			}).AnyTimes()
+
+		synchronizer := s.Expose().(livesync.Synchronizer)
+		updates, err := synchronizer.Register(nil, &livesync.Filter{Actions: []string{"Initialization"}})
+		require.NoError(t, err)
+
+		go s.Run(ctx, func() {}, func() {})
+
+		select {
+		case segments := <-updates:
+			require.Len(t, segments, 1)
+			assert.Equal(t, "Initialization", segments[0].Link.Meta.Action)
+		case <-ctx.Done():
+			require.Fail(t, "the matching segment was not delivered")
+		}
+	})
+}
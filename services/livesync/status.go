@@ -0,0 +1,313 @@
+package livesync
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Status reports the health of the synchronization.
+type Status struct {
+	Workflows []*WorkflowStatus `json:"workflows"`
+	Listeners []*ListenerStatus `json:"listeners"`
+
+	// Alerts are the most recent integrity alerts.
+	Alerts []*IntegrityAlert `json:"alerts,omitempty"`
+}
+
+// WorkflowStatus reports the synchronization of a workflow.
+type WorkflowStatus struct {
+	ID string `json:"id"`
+
+	// Cursor is the cursor of the last synced link.
+	Cursor string `json:"cursor"`
+
+	// LastPoll is the time of the last successful poll. It is zero until the
+	// workflow is polled successfully.
+	LastPoll time.Time `json:"lastPoll"`
+
+	// LastError is the error of the last failed poll.
+	LastError string `json:"lastError,omitempty"`
+
+	// Failures is the number of consecutive failed polls.
+	Failures int `json:"failures"`
+
+	// Links is the number of synced links.
+	Links uint64 `json:"links"`
+
+	// Lag is the number of links of the workflow that are not synced yet, as
+	// of the last successful poll.
+	Lag uint64 `json:"lag"`
+
+	// IntegrityAlerts is the number of synced links that failed the
+	// integrity verification.
+	IntegrityAlerts uint64 `json:"integrityAlerts"`
+}
+
+// ListenerStatus reports the delivery of the updates to a listener.
+type ListenerStatus struct {
+	ID string `json:"id"`
+
+	// Workflows are the workflows the listener subscribed to. Their lag is
+	// the number of synced links not delivered to the listener yet.
+	Workflows []*ListenerWorkflowStatus `json:"workflows"`
+
+	// Queued is the number of updates waiting to be consumed.
+	Queued int `json:"queued"`
+
+	// Dropped is the number of updates dropped because the queue was full.
+	Dropped uint64 `json:"dropped"`
+}
+
+// ListenerWorkflowStatus reports the delivery of the updates of a workflow
+// to a listener.
+type ListenerWorkflowStatus struct {
+	ID     string `json:"id"`
+	Cursor string `json:"cursor"`
+	Lag    uint64 `json:"lag"`
+}
+
+// pollStatus is the outcome of the last polls of a workflow.
+type pollStatus struct {
+	lastPoll  time.Time
+	lastError string
+	failures  int
+	// total is the number of links of the workflow returned by the API.
+	total uint64
+	// alerts is the number of links that failed the integrity verification.
+	alerts uint64
+}
+
+var (
+	workflowKey, _ = tag.NewKey("workflow")
+
+	workflowLinks = stats.Int64(
+		"stratumn-connector/livesync/workflow-links",
+		"number of synced links of a workflow",
+		stats.UnitDimensionless,
+	)
+
+	workflowLag = stats.Int64(
+		"stratumn-connector/livesync/workflow-lag",
+		"number of links of a workflow that are not synced yet",
+		stats.UnitDimensionless,
+	)
+
+	lastPoll = stats.Int64(
+		"stratumn-connector/livesync/last-poll",
+		"unix time of the last successful poll of a workflow",
+		stats.UnitSeconds,
+	)
+
+	pollFailures = stats.Int64(
+		"stratumn-connector/livesync/poll-failures",
+		"number of failed polls of a workflow",
+		stats.UnitDimensionless,
+	)
+
+	listenerLag = stats.Int64(
+		"stratumn-connector/livesync/listener-lag",
+		"number of synced links not delivered to a listener yet",
+		stats.UnitDimensionless,
+	)
+)
+
+// cursorIndex returns the position of a cursor, or zero when the cursor is
+// empty or malformed.
+func cursorIndex(cursor string) uint64 {
+	if cursor == "" {
+		return 0
+	}
+
+	index, err := parseCursor(cursor)
+	if err != nil || index < 0 {
+		return 0
+	}
+	return uint64(index)
+}
+
+// lag returns how far a position is behind another one.
+func lag(from, to uint64) uint64 {
+	if to < from {
+		return 0
+	}
+	return to - from
+}
+
+// Status returns the status of the synced workflows and of the listeners.
+// The state of the synchronizer is copied under the lock, so that a status
+// request does not hold up the sync.
+func (s *synchronizer) Status() *Status {
+	workflows, polls, listeners, alerts := s.snapshot()
+
+	status := &Status{
+		Workflows: make([]*WorkflowStatus, len(workflows)),
+		Listeners: make([]*ListenerStatus, len(listeners)),
+		Alerts:    alerts,
+	}
+
+	for i, w := range workflows {
+		ws := &WorkflowStatus{
+			ID:     w.ID,
+			Cursor: w.Cursor,
+			Links:  cursorIndex(w.Cursor),
+		}
+		if p, ok := polls[w.ID]; ok {
+			ws.LastPoll = p.lastPoll
+			ws.LastError = p.lastError
+			ws.Failures = p.failures
+			ws.Lag = lag(ws.Links, p.total)
+			ws.IntegrityAlerts = p.alerts
+		}
+		status.Workflows[i] = ws
+	}
+
+	for i, l := range listeners {
+		ls := &ListenerStatus{
+			ID:        l.listener.id,
+			Workflows: make([]*ListenerWorkflowStatus, len(l.states)),
+			Queued:    len(l.listener.queue),
+			Dropped:   atomic.LoadUint64(&l.listener.dropped),
+		}
+		for j, lw := range l.states {
+			ls.Workflows[j] = &ListenerWorkflowStatus{
+				ID:     lw.ID,
+				Cursor: lw.Cursor,
+				Lag:    deliveryLag(workflows, lw),
+			}
+		}
+		status.Listeners[i] = ls
+	}
+
+	return status
+}
+
+// listenerSnapshot is a copy of the state of a listener.
+type listenerSnapshot struct {
+	listener *listener
+	states   WorkflowStates
+}
+
+// snapshot copies the state reported by the status.
+func (s *synchronizer) snapshot() (WorkflowStates, map[string]pollStatus, []*listenerSnapshot, []*IntegrityAlert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workflows := make(WorkflowStates, len(s.workflowStates))
+	for i, w := range s.workflowStates {
+		workflows[i] = &WorkflowState{ID: w.ID, Cursor: w.Cursor}
+	}
+
+	polls := make(map[string]pollStatus, len(s.statuses))
+	for id, p := range s.statuses {
+		polls[id] = *p
+	}
+
+	listeners := make([]*listenerSnapshot, 0, len(s.registeredServices)+len(s.subscriptions))
+	for _, l := range s.registeredServices {
+		states := make(WorkflowStates, len(l.states))
+		for j, lw := range l.states {
+			states[j] = &WorkflowState{ID: lw.ID, Cursor: lw.Cursor}
+		}
+		listeners = append(listeners, &listenerSnapshot{listener: l, states: states})
+	}
+
+	// the subscriptions report their acknowledged cursors.
+	names := make([]string, 0, len(s.subscriptions))
+	for name := range s.subscriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		states := make(WorkflowStates, len(s.workflowStates))
+		for j, w := range s.workflowStates {
+			states[j] = &WorkflowState{ID: w.ID, Cursor: s.ackedCursor(name, w.ID)}
+		}
+		listeners = append(listeners, &listenerSnapshot{listener: s.subscriptions[name], states: states})
+	}
+
+	var alerts []*IntegrityAlert
+	if len(s.alerts) > 0 {
+		alerts = make([]*IntegrityAlert, len(s.alerts))
+		copy(alerts, s.alerts)
+	}
+
+	return workflows, polls, listeners, alerts
+}
+
+// deliveryLag returns the number of synced links of a workflow that were not
+// delivered to a listener.
+func deliveryLag(workflows WorkflowStates, lw *WorkflowState) uint64 {
+	w, ok := workflows.Get(lw.ID)
+	if !ok {
+		return 0
+	}
+	return lag(cursorIndex(lw.Cursor), cursorIndex(w.Cursor))
+}
+
+// recordPoll updates the status of a workflow after a successful poll.
+// total is the number of links of the workflow returned by the API.
+func (s *synchronizer) recordPoll(workflowID string, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.pollStatus(workflowID)
+	p.lastPoll = time.Now()
+	p.lastError = ""
+	p.failures = 0
+	if total > 0 {
+		p.total = uint64(total)
+	}
+
+	var links uint64
+	if w, ok := s.workflowStates.Get(workflowID); ok {
+		links = cursorIndex(w.Cursor)
+	}
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(workflowKey, workflowID))
+	stats.Record(ctx,
+		workflowLinks.M(int64(links)),
+		workflowLag.M(int64(lag(links, p.total))),
+		lastPoll.M(p.lastPoll.Unix()),
+	)
+
+	for _, l := range s.registeredServices {
+		if lw, ok := l.states.Get(workflowID); ok {
+			stats.Record(l.ctx, listenerLag.M(int64(deliveryLag(s.workflowStates, lw))))
+		}
+	}
+	for _, l := range s.subscriptions {
+		l.sub.notify()
+	}
+
+	// the listeners may have consumed segments since the last delivery.
+	s.checkpoint()
+}
+
+// recordFailure updates the status of a workflow after a failed poll.
+func (s *synchronizer) recordFailure(workflowID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.pollStatus(workflowID)
+	p.lastError = err.Error()
+	p.failures++
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(workflowKey, workflowID))
+	stats.Record(ctx, pollFailures.M(1))
+}
+
+// pollStatus returns the poll status of a workflow.
+// It must be called with the lock held.
+func (s *synchronizer) pollStatus(workflowID string) *pollStatus {
+	p, ok := s.statuses[workflowID]
+	if !ok {
+		p = &pollStatus{}
+		s.statuses[workflowID] = p
+	}
+	return p
+}
@@ -0,0 +1,446 @@
+package livesync
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+)
+
+const (
+	// DefaultMaxDeliveryAttempts is the default number of times a batch is
+	// delivered to a subscription before it is moved to the dead letters.
+	DefaultMaxDeliveryAttempts = 5
+
+	// DefaultRedeliveryDelay is the default time waited before delivering again a batch that was not acknowledged (in milliseconds).
+	DefaultRedeliveryDelay = 1000
+
+	// DefaultDeadLetterFile is the default file the dead letters are appended to.
+	DefaultDeadLetterFile = "livesync_dead_letters.jsonl"
+)
+
+var (
+	// ErrSubscribed is returned when subscribing with the name of an active
+	// subscription.
+	ErrSubscribed = errors.New("a subscription with this name is already active")
+
+	// ErrMissingSubscriptionName is returned when subscribing without a name.
+	ErrMissingSubscriptionName = errors.New("the subscription name must be provided")
+)
+
+// Batch is a page of synced segments of a workflow delivered to a
+// subscription.
+// The consumer must call Ack once the segments are processed, or Nack when
+// they cannot be processed. Until then, no other batch is delivered to the
+// subscription.
+type Batch struct {
+	WorkflowID string
+	// Cursor is the cursor of the last link of the page.
+	Cursor   string
+	Segments []*cs.Segment
+
+	// Attempt is the number of times the batch was delivered, starting at 1.
+	Attempt int
+
+	// Replayed is set for the batches of past links delivered by Replay.
+	// Acknowledging them does not move the cursor of the subscription.
+	Replayed bool
+
+	acks chan error
+	once sync.Once
+}
+
+// NewBatch creates a batch and returns the channel receiving its
+// acknowledgement: nil when it is acknowledged, the error given to Nack
+// otherwise. It is meant for implementations of Synchronizer.
+func NewBatch(workflowID, cursor string, segments []*cs.Segment, attempt int) (*Batch, <-chan error) {
+	acks := make(chan error, 1)
+	return &Batch{
+		WorkflowID: workflowID,
+		Cursor:     cursor,
+		Segments:   segments,
+		Attempt:    attempt,
+		acks:       acks,
+	}, acks
+}
+
+// Ack confirms that the segments are processed. The cursor of the
+// subscription then moves past the batch.
+func (b *Batch) Ack() {
+	b.done(nil)
+}
+
+// Nack reports that the segments could not be processed. The batch is
+// delivered again until the maximum number of attempts is reached.
+func (b *Batch) Nack(err error) {
+	if err == nil {
+		err = errors.New("batch not acknowledged")
+	}
+	b.done(err)
+}
+
+func (b *Batch) done(err error) {
+	b.once.Do(func() {
+		if b.acks != nil {
+			b.acks <- err
+		}
+	})
+}
+
+// subscription delivers batches one at a time and waits for their
+// acknowledgement before moving its cursor.
+type subscription struct {
+	name    string
+	batches chan *Batch
+
+	// mu is held while a batch is delivered, so that the replayed batches
+	// are delivered one at a time with the live ones.
+	mu     sync.Mutex
+	closed bool
+
+	maxAttempts int
+	delay       time.Duration
+	deadLetters DeadLetterStore
+
+	// commit saves the acknowledged cursor of a workflow.
+	commit func(workflowID, cursor string)
+
+	// wake is signaled after each poll so that the subscription catches up
+	// with the synced workflows.
+	wake chan struct{}
+
+	// pagesMu protects the pages synced by the poller that were not
+	// delivered yet. At most maxPages pages are kept: the oldest ones are
+	// fetched again when more pages are synced.
+	pagesMu  sync.Mutex
+	pages    []*syncedPage
+	maxPages int
+}
+
+// syncedPage is a page of links synced by the poller.
+type syncedPage struct {
+	workflowID string
+	// from is the cursor the page was fetched from and to the cursor of
+	// its last link.
+	from, to string
+	edges    linkEdges
+}
+
+func newSubscription(name string, d Delivery, commit func(workflowID, cursor string)) *subscription {
+	maxAttempts := d.MaxDeliveryAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxDeliveryAttempts
+	}
+
+	return &subscription{
+		name:        name,
+		batches:     make(chan *Batch),
+		maxAttempts: maxAttempts,
+		delay:       d.RedeliveryDelay,
+		deadLetters: d.DeadLetters,
+		commit:      commit,
+		wake:        make(chan struct{}, 1),
+		maxPages:    d.queueSize(),
+	}
+}
+
+// push keeps a page synced by the poller until it is delivered.
+func (s *subscription) push(workflowID, from, to string, edges linkEdges) {
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	// the edges of the poller are decoded again for the next page.
+	p := &syncedPage{workflowID: workflowID, from: from, to: to, edges: append(linkEdges(nil), edges...)}
+	s.pages = append(s.pages, p)
+	if len(s.pages) > s.maxPages {
+		s.pages = s.pages[len(s.pages)-s.maxPages:]
+	}
+}
+
+// page returns the synced page containing the links following a cursor of a
+// workflow, if it is still kept. The pages preceding the cursor are
+// forgotten.
+func (s *subscription) page(workflowID, cursor string) (*syncedPage, bool) {
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	var found *syncedPage
+	kept := s.pages[:0]
+	for _, p := range s.pages {
+		if p.workflowID == workflowID {
+			if gap, err := CompareCursors(p.to, cursor); err != nil || gap <= 0 {
+				continue
+			}
+			if gap, err := CompareCursors(p.from, cursor); found == nil && err == nil && gap <= 0 {
+				found = p
+			}
+		}
+		kept = append(kept, p)
+	}
+	s.pages = kept
+
+	return found, found != nil
+}
+
+// notify wakes the subscription up without waiting for it.
+func (s *subscription) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends a batch and commits its cursor.
+// Batches without segments only move the cursor.
+// It returns false when the subscription must stop: the batch is then not
+// committed and will be delivered again when subscribing after a restart.
+func (s *subscription) deliver(b *Batch, done <-chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.send(b, done) {
+		return false
+	}
+
+	s.commit(b.WorkflowID, b.Cursor)
+	return true
+}
+
+// replay sends a batch of past links without committing its cursor.
+// It returns false when the batch was not processed.
+func (s *subscription) replay(b *Batch, done <-chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	return s.send(b, done)
+}
+
+// close closes the channel of the subscription once no batch is being
+// delivered.
+func (s *subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	close(s.batches)
+}
+
+// send delivers a batch until it is acknowledged or the maximum number of
+// attempts is reached, in which case it is moved to the dead letters.
+// It returns false when the delivery is interrupted.
+// It must be called with the lock held.
+func (s *subscription) send(b *Batch, done <-chan struct{}) bool {
+	for attempt := 1; len(b.Segments) > 0; attempt++ {
+		sent, acks := NewBatch(b.WorkflowID, b.Cursor, b.Segments, attempt)
+		sent.Replayed = b.Replayed
+
+		select {
+		case s.batches <- sent:
+		case <-done:
+			return false
+		}
+
+		var err error
+		select {
+		case err = <-acks:
+		case <-done:
+			return false
+		}
+		if err == nil {
+			break
+		}
+
+		log.Warnf("Subscription %s failed to process a batch of workflow %s (attempt %d): %s", s.name, b.WorkflowID, attempt, err)
+		if attempt >= s.maxAttempts {
+			if !s.deadLetter(sent, err) {
+				return false
+			}
+			break
+		}
+
+		select {
+		case <-time.After(s.delay):
+		case <-done:
+			return false
+		}
+	}
+
+	return true
+}
+
+// runSubscription delivers the synced links to a subscription until it
+// stops. The links are delivered from the acknowledged cursors of the
+// subscription up to the cursors of the synced workflows, without holding
+// the lock.
+func (s *synchronizer) runSubscription(l *listener) {
+	defer l.sub.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.done:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	for {
+		if !s.catchUp(ctx, l) {
+			return
+		}
+
+		select {
+		case <-l.sub.wake:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// catchUp delivers to a subscription the links synced after its
+// acknowledged cursors.
+// The links are delivered from the pages synced by the poller. They are only
+// fetched again when they were synced before the subscription started, for
+// instance after a restart, or when the subscription fell too far behind.
+// A failed fetch is logged and tried again after the next poll.
+// It returns false when the subscription stops.
+func (s *synchronizer) catchUp(ctx context.Context, l *listener) bool {
+	for _, w := range s.syncedStates() {
+		cursor := s.ackedCursor(l.sub.name, w.ID)
+		for {
+			if gap, err := CompareCursors(w.Cursor, cursor); err != nil || gap <= 0 {
+				break
+			}
+
+			p, ok := l.sub.page(w.ID, cursor)
+			if !ok {
+				rsp, err := s.fetch(ctx, w.ID, cursor)
+				if err != nil {
+					if ctx.Err() != nil {
+						return false
+					}
+					log.Warnf("Subscription %s could not fetch workflow %s: %s", l.sub.name, w.ID, err)
+					break
+				}
+
+				links := rsp.WorkflowByRowID.Links
+				if len(links.Edges) == 0 {
+					break
+				}
+				p = &syncedPage{workflowID: w.ID, from: cursor, to: links.PageInfo.EndCursor, edges: links.Edges}
+			}
+
+			// the batches without segments are delivered too, so that the
+			// cursors are acknowledged in order.
+			b := &Batch{
+				WorkflowID: w.ID,
+				Cursor:     p.to,
+				Segments:   l.filter.Apply(p.edges.Slice(cursor)),
+			}
+			if !l.sub.deliver(b, l.done) {
+				return false
+			}
+			cursor = b.Cursor
+		}
+	}
+
+	return true
+}
+
+// syncedStates returns a copy of the cursors of the synced workflows.
+func (s *synchronizer) syncedStates() WorkflowStates {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make(WorkflowStates, len(s.workflowStates))
+	for i, w := range s.workflowStates {
+		states[i] = &WorkflowState{ID: w.ID, Cursor: w.Cursor}
+	}
+	return states
+}
+
+// deadLetter stores a batch that could not be processed.
+func (s *subscription) deadLetter(b *Batch, cause error) bool {
+	if s.deadLetters == nil {
+		log.Errorf("Subscription %s discards %d segments of workflow %s", s.name, len(b.Segments), b.WorkflowID)
+		return true
+	}
+
+	if err := s.deadLetters.Put(s.name, b, cause); err != nil {
+		log.Errorf("could not store the dead letter of subscription %s: %s", s.name, err)
+		return false
+	}
+
+	log.Errorf("Subscription %s moved %d segments of workflow %s to the dead letters", s.name, len(b.Segments), b.WorkflowID)
+	return true
+}
+
+// DeadLetterStore stores the batches that subscriptions failed to process.
+type DeadLetterStore interface {
+	// Put stores a batch with the error of its last delivery.
+	Put(subscription string, b *Batch, cause error) error
+}
+
+// DeadLetter is a batch that a subscription failed to process.
+type DeadLetter struct {
+	Time         time.Time     `json:"time"`
+	Subscription string        `json:"subscription"`
+	WorkflowID   string        `json:"workflowId"`
+	Cursor       string        `json:"cursor"`
+	Attempts     int           `json:"attempts"`
+	Error        string        `json:"error"`
+	Segments     []*cs.Segment `json:"segments"`
+}
+
+type fileDeadLetterStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileDeadLetterStore returns a DeadLetterStore appending the dead letters
+// to a file, one JSON encoded DeadLetter per line.
+func NewFileDeadLetterStore(path string) DeadLetterStore {
+	return &fileDeadLetterStore{path: path}
+}
+
+// Put appends a dead letter to the file.
+func (s *fileDeadLetterStore) Put(subscription string, b *Batch, cause error) error {
+	line, err := json.Marshal(&DeadLetter{
+		Time:         time.Now(),
+		Subscription: subscription,
+		WorkflowID:   b.WorkflowID,
+		Cursor:       b.Cursor,
+		Attempts:     b.Attempt,
+		Error:        cause.Error(),
+		Segments:     b.Segments,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	return errors.WithStack(err)
+}
@@ -20,6 +20,9 @@ var (
 	LinkPrefix = []byte("link")
 )
 
+// SubscriptionName is the name of the livesync subscription of the parser.
+const SubscriptionName = "parser"
+
 type parser struct {
 	db           db.DB
 	synchronizer livesync.Synchronizer
@@ -44,26 +47,28 @@ func (p *parser) saveLinks(ctx context.Context, segments []*cs.Segment) error {
 }
 
 // run subscribes to the livesync service and waits for updates.
+// A batch that cannot be saved is not acknowledged so that livesync delivers
+// it again.
 // It returns an error in case the channel is closed.
 func (p *parser) run(ctx context.Context) error {
 	// pass nil to subscribe to all updates
-	linkChan, err := p.synchronizer.Register(nil)
+	batches, err := p.synchronizer.Subscribe(SubscriptionName, nil)
 	if err != nil {
 		return err
 	}
+	defer p.synchronizer.Unsubscribe(batches)
 
 	for {
 		select {
-		case links, more := <-linkChan:
+		case batch, more := <-batches:
 			if !more {
 				return ErrSyncStopped
 			}
-			if links != nil {
-				err := p.saveLinks(ctx, links)
-				if err != nil {
-					return err
-				}
+			if err := p.saveLinks(ctx, batch.Segments); err != nil {
+				batch.Nack(err)
+				continue
 			}
+			batch.Ack()
 		case <-ctx.Done():
 			return ctx.Err()
 		}
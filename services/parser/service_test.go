@@ -13,6 +13,7 @@ import (
 	// "github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/stratumn/go-connector/services/livesync"
 	"github.com/stratumn/go-connector/services/livesync/mocksynchronizer"
 	"github.com/stratumn/go-connector/services/memorystore/mockmemorystore"
 	"github.com/stratumn/go-connector/services/parser"
@@ -23,6 +24,8 @@ func TestParserService(t *testing.T) {
 	// mock service dependencies
 	ctrl := gomock.NewController(t)
 	synchronizer := mocksynchronizer.NewMockSynchronizer(ctrl)
+	// the parser leaves the synchronizer when it stops.
+	synchronizer.EXPECT().Unsubscribe(gomock.Any()).Return(nil).AnyTimes()
 	memorystore := mockmemorystore.NewMockDB(ctrl)
 
 	// init parser service
@@ -39,9 +42,9 @@ func TestParserService(t *testing.T) {
 		// add a timeout to the context in case the cancelFunc is not called
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
 
-		// parser must register to livesync updates
-		segmentsChan := make(chan []*cs.Segment)
-		synchronizer.EXPECT().Register(nil).Return(segmentsChan, nil).Times(1)
+		// parser must subscribe to livesync updates
+		batches := make(chan *livesync.Batch)
+		synchronizer.EXPECT().Subscribe(parser.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
 
 		// run service
 		runningCh := make(chan struct{})
@@ -59,8 +62,13 @@ func TestParserService(t *testing.T) {
 		newSegment, _ := newLink.Segmentify()
 		lBytes, _ := json.Marshal(newLink)
 		key := append(parser.LinkPrefix, newSegment.LinkHash()...)
-		memorystore.EXPECT().Put(key, lBytes).Do(func(k, v []byte) { cancel() }).Times(1)
-		segmentsChan <- []*cs.Segment{newSegment}
+		memorystore.EXPECT().Put(key, lBytes).Times(1)
+		batch, acks := livesync.NewBatch("p", "", []*cs.Segment{newSegment}, 1)
+		batches <- batch
+
+		// the batch is acknowledged once saved.
+		assert.NoError(t, <-acks)
+		cancel()
 
 		<-stoppingCh
 	})
@@ -70,9 +78,9 @@ func TestParserService(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
 		defer cancel()
 
-		// parser must register to livesync updates
-		segmentsChan := make(chan []*cs.Segment)
-		synchronizer.EXPECT().Register(nil).Return(segmentsChan, nil).Times(1)
+		// parser must subscribe to livesync updates
+		batches := make(chan *livesync.Batch)
+		synchronizer.EXPECT().Subscribe(parser.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
 
 		// run service
 		runningCh := make(chan struct{})
@@ -85,39 +93,44 @@ func TestParserService(t *testing.T) {
 		<-runningCh
 
 		// closing the segments channel should trigger an error and stop the service
-		close(segmentsChan)
+		close(batches)
 
 		<-stoppingCh
 	})
 
-	t.Run("returns an error when saving a link failed", func(t *testing.T) {
-		// add a timeout to the context in case no error is returned
+	t.Run("does not acknowledge a batch when saving a link failed", func(t *testing.T) {
+		// add a timeout to the context in case the cancelFunc is not called
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
 		defer cancel()
 
-		// parser must register to livesync updates
-		segmentsChan := make(chan []*cs.Segment)
-		synchronizer.EXPECT().Register(nil).Return(segmentsChan, nil).Times(1)
+		// parser must subscribe to livesync updates
+		batches := make(chan *livesync.Batch)
+		synchronizer.EXPECT().Subscribe(parser.SubscriptionName, gomock.Nil()).Return(batches, nil).Times(1)
 
 		// run service
 		runningCh := make(chan struct{})
 		stoppingCh := make(chan struct{})
 		go func() {
 			err := p.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
-			assert.EqualError(t, err, "Put failed")
+			assert.EqualError(t, err, context.Canceled.Error())
 			stoppingCh <- struct{}{}
 		}()
 		<-runningCh
 
 		// send a new link through the channel and
-		// ensure that it triggers an error
+		// ensure that the error is reported to livesync
 		newLink, _ := cs.NewLinkBuilder("p", "map").Build()
 		newSegment, _ := newLink.Segmentify()
 		lBytes, _ := json.Marshal(newLink)
 		key := append(parser.LinkPrefix, newSegment.LinkHash()...)
 		memorystore.EXPECT().Put(key, lBytes).Return(errors.New("Put failed")).Times(1)
-		segmentsChan <- []*cs.Segment{newSegment}
+		batch, acks := livesync.NewBatch("p", "", []*cs.Segment{newSegment}, 1)
+		batches <- batch
+
+		assert.EqualError(t, <-acks, "Put failed")
 
+		// the parser keeps running.
+		cancel()
 		<-stoppingCh
 	})
 }
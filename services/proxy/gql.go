@@ -0,0 +1,525 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrBadDocument is returned when the GraphQL document cannot be parsed.
+	ErrBadDocument = errors.New("the graphql document cannot be parsed")
+)
+
+// gqlDocument is a parsed GraphQL document. Only the operations and their
+// root fields are kept: the fragments and the nested selections are checked
+// and skipped.
+type gqlDocument struct {
+	operations []*gqlOperation
+}
+
+// gqlOperation is an operation of a GraphQL document.
+type gqlOperation struct {
+	// kind is query, mutation or subscription.
+	kind string
+	name string
+	// defaults are the default values of the variables.
+	defaults map[string]interface{}
+	fields   []*gqlField
+}
+
+// gqlField is a root field of an operation. The fragments spread at the
+// root have no name.
+type gqlField struct {
+	alias     string
+	name      string
+	arguments map[string]interface{}
+}
+
+// gqlVariable is a variable used as an argument value.
+type gqlVariable string
+
+// key returns the key of the field in the response.
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// operation returns the operation executed by a request: the one with the
+// given name, or the only one of the document.
+func (d *gqlDocument) operation(name string) (*gqlOperation, bool) {
+	if name == "" {
+		if len(d.operations) != 1 {
+			return nil, false
+		}
+		return d.operations[0], true
+	}
+
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// resolve replaces the variables of an argument value by their values, or by
+// the default values of the operation when they are not provided.
+func (op *gqlOperation) resolve(value interface{}, variables map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case gqlVariable:
+		if value, ok := variables[string(v)]; ok {
+			return value
+		}
+		return op.defaults[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = op.resolve(item, variables)
+		}
+		return list
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for name, field := range v {
+			fields[name] = op.resolve(field, variables)
+		}
+		return fields
+	default:
+		return value
+	}
+}
+
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenNumber
+	tokenString
+)
+
+type gqlToken struct {
+	kind  int
+	value string
+}
+
+// gqlParser is a recursive descent parser of executable GraphQL documents.
+// The parsing stops at the first syntax error.
+type gqlParser struct {
+	src   string
+	pos   int
+	token gqlToken
+	err   error
+}
+
+// parseDocument parses a GraphQL document.
+func parseDocument(document string) (*gqlDocument, error) {
+	p := &gqlParser{src: strings.TrimPrefix(document, "\ufeff")}
+	p.advance()
+
+	doc := &gqlDocument{}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", fields: p.parseSelectionSet()})
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			doc.operations = append(doc.operations, p.parseOperation())
+		case p.peek(tokenName, "fragment"):
+			p.parseFragment()
+		default:
+			p.fail("unexpected %q", p.token.value)
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		p.fail("no operation")
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return doc, nil
+}
+
+// fail records a syntax error and ends the document.
+func (p *gqlParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = errors.Wrapf(ErrBadDocument, "%s at offset %d", fmt.Sprintf(format, args...), p.pos)
+	}
+	p.pos = len(p.src)
+	p.token = gqlToken{kind: tokenEOF}
+}
+
+// more returns whether a list goes on, or consumes its closing punctuator.
+// The document must not end before the list.
+func (p *gqlParser) more(closing string) bool {
+	if p.token.kind == tokenEOF {
+		p.fail("expected %q", closing)
+		return false
+	}
+	return !p.skip(tokenPunctuator, closing)
+}
+
+// peek returns whether the current token has a kind and a value.
+func (p *gqlParser) peek(kind int, value string) bool {
+	return p.token.kind == kind && p.token.value == value
+}
+
+// skip consumes the current token if it has a kind and a value.
+func (p *gqlParser) skip(kind int, value string) bool {
+	if !p.peek(kind, value) {
+		return false
+	}
+	p.advance()
+	return true
+}
+
+// expect consumes the current token, which must have a kind and a value.
+func (p *gqlParser) expect(kind int, value string) {
+	if !p.skip(kind, value) {
+		p.fail("expected %q, found %q", value, p.token.value)
+	}
+}
+
+// name consumes a name token and returns it.
+func (p *gqlParser) name() string {
+	if p.token.kind != tokenName {
+		p.fail("expected a name, found %q", p.token.value)
+	}
+	name := p.token.value
+	p.advance()
+	return name
+}
+
+func (p *gqlParser) parseOperation() *gqlOperation {
+	op := &gqlOperation{kind: p.name(), defaults: make(map[string]interface{})}
+	if p.token.kind == tokenName {
+		op.name = p.name()
+	}
+
+	if p.skip(tokenPunctuator, "(") {
+		for p.more(")") {
+			p.expect(tokenPunctuator, "$")
+			name := p.name()
+			p.expect(tokenPunctuator, ":")
+			p.parseType()
+			if p.skip(tokenPunctuator, "=") {
+				op.defaults[name] = p.parseValue(true)
+			}
+			p.parseDirectives()
+		}
+	}
+	p.parseDirectives()
+	op.fields = p.parseSelectionSet()
+
+	return op
+}
+
+func (p *gqlParser) parseFragment() {
+	p.expect(tokenName, "fragment")
+	if p.name() == "on" {
+		p.fail("a fragment cannot be named on")
+	}
+	p.expect(tokenName, "on")
+	p.name()
+	p.parseDirectives()
+	p.parseSelectionSet()
+}
+
+func (p *gqlParser) parseType() {
+	if p.skip(tokenPunctuator, "[") {
+		p.parseType()
+		p.expect(tokenPunctuator, "]")
+	} else {
+		p.name()
+	}
+	p.skip(tokenPunctuator, "!")
+}
+
+func (p *gqlParser) parseDirectives() {
+	for p.skip(tokenPunctuator, "@") {
+		p.name()
+		p.parseArguments()
+	}
+}
+
+// parseSelectionSet parses a selection set and returns its fields.
+func (p *gqlParser) parseSelectionSet() []*gqlField {
+	var fields []*gqlField
+
+	p.expect(tokenPunctuator, "{")
+	for p.more("}") {
+		if p.skip(tokenPunctuator, "...") {
+			if p.token.kind == tokenName && p.token.value != "on" {
+				p.name()
+				p.parseDirectives()
+			} else {
+				if p.skip(tokenName, "on") {
+					p.name()
+				}
+				p.parseDirectives()
+				p.parseSelectionSet()
+			}
+			fields = append(fields, &gqlField{})
+			continue
+		}
+
+		f := &gqlField{name: p.name()}
+		if p.skip(tokenPunctuator, ":") {
+			f.alias, f.name = f.name, p.name()
+		}
+		f.arguments = p.parseArguments()
+		p.parseDirectives()
+		if p.peek(tokenPunctuator, "{") {
+			p.parseSelectionSet()
+		}
+		fields = append(fields, f)
+	}
+
+	if len(fields) == 0 {
+		p.fail("empty selection set")
+	}
+	return fields
+}
+
+func (p *gqlParser) parseArguments() map[string]interface{} {
+	arguments := make(map[string]interface{})
+	if !p.skip(tokenPunctuator, "(") {
+		return arguments
+	}
+
+	for p.more(")") {
+		name := p.name()
+		p.expect(tokenPunctuator, ":")
+		arguments[name] = p.parseValue(false)
+	}
+	return arguments
+}
+
+// parseValue parses a value. Variables are returned as a gqlVariable,
+// numbers as a json.Number and enum values as strings.
+func (p *gqlParser) parseValue(constant bool) interface{} {
+	t := p.token
+	switch t.kind {
+	case tokenNumber:
+		p.advance()
+		return json.Number(t.value)
+	case tokenString:
+		p.advance()
+		return t.value
+	case tokenName:
+		p.advance()
+		switch t.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		default:
+			return t.value
+		}
+	}
+
+	switch {
+	case p.skip(tokenPunctuator, "$"):
+		if constant {
+			p.fail("unexpected variable")
+		}
+		return gqlVariable(p.name())
+	case p.skip(tokenPunctuator, "["):
+		list := []interface{}{}
+		for p.more("]") {
+			list = append(list, p.parseValue(constant))
+		}
+		return list
+	case p.skip(tokenPunctuator, "{"):
+		fields := make(map[string]interface{})
+		for p.more("}") {
+			name := p.name()
+			p.expect(tokenPunctuator, ":")
+			fields[name] = p.parseValue(constant)
+		}
+		return fields
+	}
+
+	p.fail("unexpected %q", t.value)
+	return nil
+}
+
+// advance reads the next token. Whitespace, commas and comments are
+// skipped.
+func (p *gqlParser) advance() {
+	defer func() {
+		if p.err != nil {
+			p.token = gqlToken{kind: tokenEOF}
+		}
+	}()
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	if p.pos >= len(p.src) {
+		p.token = gqlToken{kind: tokenEOF}
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.token = gqlToken{kind: tokenPunctuator, value: "..."}
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		p.pos++
+		p.token = gqlToken{kind: tokenPunctuator, value: string(c)}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.token = gqlToken{kind: tokenName, value: p.src[start:p.pos]}
+	case c == '-' || c >= '0' && c <= '9':
+		p.token = gqlToken{kind: tokenNumber, value: p.scanNumber()}
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		p.token = gqlToken{kind: tokenString, value: p.scanBlockString()}
+	case c == '"':
+		p.token = gqlToken{kind: tokenString, value: p.scanString()}
+	default:
+		p.fail("unexpected character %q", c)
+	}
+}
+
+// scanNumber reads an integer or a float.
+func (p *gqlParser) scanNumber() string {
+	start := p.pos
+	digits := func() {
+		from := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == from {
+			p.fail("malformed number")
+		}
+	}
+
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+
+	number := p.src[start:p.pos]
+	var n json.Number
+	if json.Unmarshal([]byte(number), &n) != nil {
+		p.fail("malformed number %s", number)
+	}
+	if p.pos < len(p.src) && (isNameChar(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.fail("malformed number")
+	}
+	return number
+}
+
+// scanString reads a quoted string. The escape sequences of GraphQL are the
+// ones of JSON.
+func (p *gqlParser) scanString() string {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			break
+		}
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		p.fail("unterminated string")
+		return ""
+	}
+	p.pos++
+
+	var s string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+		p.fail("malformed string")
+	}
+	return s
+}
+
+// scanBlockString reads a block string and removes its common indentation
+// and its leading and trailing blank lines.
+func (p *gqlParser) scanBlockString() string {
+	p.pos += 3
+	end := strings.Index(p.src[p.pos:], `"""`)
+	for end > 0 && p.src[p.pos+end-1] == '\\' {
+		next := strings.Index(p.src[p.pos+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		p.fail("unterminated block string")
+		return ""
+	}
+	raw := strings.Replace(p.src[p.pos:p.pos+end], `\"""`, `"""`, -1)
+	p.pos += end + 3
+
+	lines := strings.Split(strings.Replace(strings.Replace(raw, "\r\n", "\n", -1), "\r", "\n", -1), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// isNameChar returns whether a character can be part of a GraphQL name.
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+
+	csutils "github.com/stratumn/go-connector/lib/chainscript"
+)
+
+var (
+	// ErrBadLink is returned when the link of a createLink mutation cannot be parsed.
+	ErrBadLink = errors.New("the link is not a valid chainscript link")
+
+	// ErrBadLinkInput is returned when the input of a createLink or
+	// createLinks mutation is not made of links only.
+	ErrBadLinkInput = errors.New("the inputs of createLink and createLinks must only contain a link")
+
+	// ErrMixedMutation is returned when a createLink or createLinks mutation
+	// is sent with other fields, which cannot be submitted with the links.
+	ErrMixedMutation = errors.New("the createLink and createLinks mutations cannot be sent with other fields")
+)
+
+// parseLink remarshals a link from a mutation input into a chainscript link.
+func parseLink(v interface{}) (*cs.Link, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(ErrBadLink, err.Error())
+	}
+
+	var link cs.Link
+	if err := json.Unmarshal(b, &link); err != nil {
+		return nil, errors.Wrap(ErrBadLink, err.Error())
+	}
+	if link.Meta == nil || link.Meta.Process == nil {
+		return nil, ErrBadLink
+	}
+
+	return &link, nil
+}
+
+// isEncrypted checks whether the link data has already been encrypted
+// for a list of recipients.
+func isEncrypted(link *cs.Link) bool {
+	var md struct {
+		Recipients []interface{}
+	}
+	if err := json.Unmarshal(link.GetMeta().GetData(), &md); err != nil {
+		return false
+	}
+	return len(md.Recipients) > 0
+}
+
+// encryptLink encrypts a plaintext link for the participants of its workflow.
+// The workflow ID is the name of the link process.
+func (p *proxy) encryptLink(ctx context.Context, link *cs.Link) error {
+	recipients, err := p.client.GetRecipientsPublicKeys(ctx, link.Meta.Process.Name)
+	if err != nil {
+		return err
+	}
+
+	return csutils.EncryptLink(ctx, link, recipients)
+}
+
+// prepareLink encrypts a plaintext link. Links that are already encrypted
+// are left as is, they are signed when submitted.
+func (p *proxy) prepareLink(ctx context.Context, input interface{}) (*cs.Link, error) {
+	fields, ok := input.(map[string]interface{})
+	if !ok || len(fields) != 1 {
+		return nil, ErrBadLinkInput
+	}
+	v, ok := fields["link"]
+	if !ok {
+		return nil, ErrBadLinkInput
+	}
+
+	link, err := parseLink(v)
+	if err != nil {
+		return nil, err
+	}
+	if !isEncrypted(link) {
+		if err := p.encryptLink(ctx, link); err != nil {
+			return nil, err
+		}
+	}
+
+	return link, nil
+}
+
+// createsLinks returns whether an operation is a createLink or createLinks
+// mutation.
+func createsLinks(op *gqlOperation) bool {
+	if op.kind != "mutation" {
+		return false
+	}
+	for _, f := range op.fields {
+		if f.name == "createLink" || f.name == "createLinks" {
+			return true
+		}
+	}
+	return false
+}
+
+// createLinks submits the links of the createLink and createLinks fields of a
+// mutation, in order, and returns the payloads by response key.
+// The links are read from the input argument of the fields, given inline or
+// in variables. They are encrypted when they are in plaintext, and signed by
+// the client when submitted. The payloads contain the fields selected by the
+// client: the trace row ID of createLink and the trace IDs of createLinks.
+func (p *proxy) createLinks(ctx context.Context, op *gqlOperation, variables map[string]interface{}) (map[string]interface{}, error) {
+	for _, f := range op.fields {
+		if f.name != "createLink" && f.name != "createLinks" {
+			return nil, ErrMixedMutation
+		}
+	}
+
+	data := make(map[string]interface{}, len(op.fields))
+	for _, f := range op.fields {
+		input := op.resolve(f.arguments["input"], variables)
+
+		if f.name == "createLink" {
+			link, err := p.prepareLink(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			rsp, err := p.client.CreateLink(ctx, link)
+			if err != nil {
+				return nil, err
+			}
+			data[f.key()] = rsp.CreateLink
+			continue
+		}
+
+		// A single input is coerced into a list, as GraphQL does.
+		inputs, ok := input.([]interface{})
+		if !ok {
+			inputs = []interface{}{input}
+		}
+		if len(inputs) == 0 {
+			return nil, ErrBadLinkInput
+		}
+		links := make([]*cs.Link, len(inputs))
+		for i, v := range inputs {
+			link, err := p.prepareLink(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			links[i] = link
+		}
+		rsp, err := p.client.CreateLinks(ctx, links)
+		if err != nil {
+			return nil, err
+		}
+		data[f.key()] = rsp.CreateLinks
+	}
+
+	return data, nil
+}
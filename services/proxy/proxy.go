@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
+	"github.com/stratumn/go-connector/services/client"
+)
+
+var (
+	// ErrMissingQuery is returned when the GraphQL request has no query.
+	ErrMissingQuery = errors.New("a graphql query must be provided")
+
+	// ErrMethodNotAllowed is returned when the GraphQL request is not a POST.
+	ErrMethodNotAllowed = errors.New("graphql requests must use the POST method")
+)
+
+// gqlRequest is the body of an incoming GraphQL request.
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type gqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// gqlResponse is the body of the responses sent back to the client apps.
+type gqlResponse struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+type proxy struct {
+	client client.TraceClient
+}
+
+// newHandler returns the HTTP handler of the proxy.
+// Every route is guarded by the authentication middleware.
+func newHandler(c client.TraceClient, m auth.Middleware) http.Handler {
+	p := &proxy{client: c}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", m.WithAuth(p.graphql))
+
+	return mux
+}
+
+// graphql relays the request to the Trace graphql endpoint.
+// The links contained in the response are decrypted by the client before
+// being sent back.
+// The createLink and createLinks mutations are not relayed: their links are
+// encrypted when they are in plaintext, then signed and submitted by the
+// client.
+func (p *proxy) graphql(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrors(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	var req gqlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrors(w, http.StatusBadRequest, errors.Wrap(err, "bad graphql request"))
+		return
+	}
+	if req.Query == "" {
+		writeErrors(w, http.StatusBadRequest, ErrMissingQuery)
+		return
+	}
+
+	doc, err := parseDocument(req.Query)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Links are encrypted and signed before being sent to Trace.
+	var data interface{}
+	if op, ok := doc.operation(req.OperationName); ok && createsLinks(op) {
+		var created map[string]interface{}
+		created, err = p.createLinks(r.Context(), op, req.Variables)
+		data = created
+	} else {
+		err = p.client.CallTraceGql(r.Context(), req.Query, req.Variables, &data)
+	}
+
+	switch errors.Cause(err) {
+	case ErrBadLink, ErrBadLinkInput, ErrMixedMutation:
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.Errorf("Trace API returned error %s", err)
+		writeTraceErrors(w, err)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, &gqlResponse{Data: data})
+}
+
+func writeErrors(w http.ResponseWriter, statusCode int, err error) {
+	httpapi.WriteJSON(w, statusCode, &gqlResponse{Errors: []gqlError{{Message: err.Error()}}})
+}
+
+// writeTraceErrors sends back the error of a call to Trace.
+// The GraphQL errors are relayed with their path, and the status tells the
+// client apps whether the request must be fixed or sent again later. The
+// failures of the connector itself, including its authentication, are bad
+// gateway errors.
+func writeTraceErrors(w http.ResponseWriter, err error) {
+	statusCode := http.StatusBadGateway
+	switch errors.Cause(err) {
+	case client.ErrAPI:
+		// the errors without status are relayed as Trace sent them.
+		statusCode = http.StatusOK
+	case client.ErrValidation:
+		statusCode = http.StatusBadRequest
+	case client.ErrNotFound:
+		statusCode = http.StatusNotFound
+	case client.ErrRateLimited:
+		statusCode = http.StatusTooManyRequests
+	}
+
+	clientErr, ok := err.(*client.Error)
+	if !ok || len(clientErr.Errors) == 0 {
+		writeErrors(w, statusCode, err)
+		return
+	}
+
+	errs := make([]gqlError, len(clientErr.Errors))
+	for i, e := range clientErr.Errors {
+		errs[i] = gqlError{Message: e.Message, Path: e.Path}
+	}
+	httpapi.WriteJSON(w, statusCode, &gqlResponse{Errors: errs})
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stratumn/go-node/core/cfg"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
+	"github.com/stratumn/go-connector/services/client"
+)
+
+var log = logrus.WithField("service", "proxy")
+
+var (
+	// ErrNotClient is returned when the connected service is not a stratumn client.
+	ErrNotClient = errors.New("connected service is not a stratumn client")
+)
+
+// Service is the Proxy service.
+type Service struct {
+	config *Config
+
+	handler http.Handler
+}
+
+// Config contains configuration options for the Proxy service.
+type Config struct {
+	// ConfigVersion is the version of the configuration file.
+	ConfigVersion int `toml:"configuration_version" comment:"The version of the service configuration."`
+
+	// Address is the address the HTTP server binds to.
+	Address string `toml:"address" comment:"Address to bind to."`
+
+	// AccountURL is the URL of the Account API used to authenticate requests.
+	AccountURL string `toml:"account_url" comment:"The URL of Stratumn Account APIs used to authenticate requests. Required when the address is not a loopback address."`
+
+	// AuthorizedAccounts restricts the access to a list of accounts.
+	AuthorizedAccounts []string `toml:"authorized_accounts" comment:"The IDs of the accounts allowed to use the proxy. Leave empty to allow any authenticated account."`
+
+	// InsecureNoAuth disables the authentication of the requests.
+	InsecureNoAuth bool `toml:"insecure_no_auth" comment:"Relay the requests without authenticating them. Anyone reaching the address can then read the decrypted links, so only enable it when the access is restricted at the network layer."`
+}
+
+// ID returns the unique identifier of the service.
+func (s *Service) ID() string {
+	return "proxy"
+}
+
+// Name returns the human friendly name of the service.
+func (s *Service) Name() string {
+	return "Trace Proxy"
+}
+
+// Desc returns a description of what the service does.
+func (s *Service) Desc() string {
+	return "Relays GraphQL requests to the Trace API and decrypts the responses"
+}
+
+// Config returns the current service configuration or creates one with
+// good default values.
+func (s *Service) Config() interface{} {
+	if s.config != nil {
+		return *s.config
+	}
+
+	return Config{
+		Address: "/ip4/127.0.0.1/tcp/8903",
+	}
+}
+
+// SetConfig configures the service.
+func (s *Service) SetConfig(config interface{}) error {
+	conf := config.(Config)
+	s.config = &conf
+	return nil
+}
+
+// Needs returns the set of services this service depends on.
+func (s *Service) Needs() map[string]struct{} {
+	return map[string]struct{}{
+		"stratumnClient": struct{}{},
+	}
+}
+
+// Plug sets the connected services.
+func (s *Service) Plug(exposed map[string]interface{}) error {
+	stratumnClient, ok := exposed["stratumnClient"].(client.TraceClient)
+	if !ok {
+		return errors.Wrap(ErrNotClient, "stratumnClient")
+	}
+
+	// The requests are only relayed without authentication to local
+	// clients, unless the authentication is explicitly disabled.
+	var middleware auth.Middleware = auth.NoAuthMiddleware{}
+	if s.config.InsecureNoAuth {
+		log.Warnf("Authentication is disabled, anyone reaching %s can read the decrypted links", s.config.Address)
+	} else {
+		var err error
+		middleware, err = auth.NewEndpointMiddleware(s.config.Address, s.config.AccountURL, s.config.AuthorizedAccounts)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.handler = newHandler(stratumnClient, middleware)
+
+	return nil
+}
+
+// Expose exposes the HTTP handler of the proxy to other services.
+func (s *Service) Expose() interface{} {
+	return s.handler
+}
+
+// Run starts the service.
+func (s *Service) Run(ctx context.Context, running, stopping func()) error {
+	lis, err := httpapi.Listen(s.config.Address)
+	if err != nil {
+		return err
+	}
+
+	running()
+	err = httpapi.Serve(ctx, lis, s.handler)
+	stopping()
+
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(ctx.Err())
+}
+
+// Migrator methods.
+
+// VersionKey is the version key.
+func (s *Service) VersionKey() string {
+	return "configuration_version"
+}
+
+// Migrations is the services migrations.
+func (s *Service) Migrations() []cfg.MigrateHandler {
+	return []cfg.MigrateHandler{
+		func(tree *cfg.Tree) error {
+			err := tree.Set("address", "/ip4/127.0.0.1/tcp/8903")
+			if err != nil {
+				return err
+			}
+			return tree.Set("account_url", "")
+		},
+		func(tree *cfg.Tree) error {
+			return tree.Set("insecure_no_auth", false)
+		},
+	}
+}
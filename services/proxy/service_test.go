@@ -0,0 +1,429 @@
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	cs "github.com/stratumn/go-chainscript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	csutils "github.com/stratumn/go-connector/lib/chainscript"
+	"github.com/stratumn/go-connector/services/client"
+	"github.com/stratumn/go-connector/services/client/mockclient"
+	"github.com/stratumn/go-connector/services/proxy"
+)
+
+const (
+	q  = "query { link { data } }"
+	pk = "-----BEGIN RSA PUBLIC KEY-----\nMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAnEHluaVmVFDzc2K47ntl\n9khvzX567pSgCZsOy4iuUSuQ1mGVRUFkcaUz2/xIZSbJHjwpi1lGmJItp92v0cZo\nUEn0ln0nI6UNRK3+MhA0ZyYFb8xs0UCe1OafEHVkuApGS0GVaraRp1LNLZYGPOQF\nHKkuA5b4l9imEJ5bxJIRJJQTIj10+RB4UFFj7WvsEd6oXp+3iS8SKumDF+sMQDPf\n8r+umOFFhm4f4nxSPP6qh85awqfVSVBM4lyXVf+xmhpSp50F18GGdGg8jiCtR7tC\nEcFQH/xUWx+VO1O3NJqLe0wIYneZTExfjEAVhs5yVXe6oyLSmtCfZcxurVQi26Xq\nMQIDAQAB\n-----END RSA PUBLIC KEY-----\n"
+)
+
+type gqlResponse struct {
+	Data   map[string]interface{}
+	Errors []struct {
+		Message string
+		Path    []interface{}
+	}
+}
+
+func TestProxyService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockClient := mockclient.NewMockStratumnClient(ctrl)
+
+	s := &proxy.Service{}
+	s.SetConfig(proxy.Config{InsecureNoAuth: true})
+	err := s.Plug(map[string]interface{}{
+		"stratumnClient": mockClient,
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(s.Expose().(http.Handler))
+	defer ts.Close()
+
+	post := func(t *testing.T, body string) (int, *gqlResponse) {
+		rsp, err := http.Post(ts.URL+"/graphql", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+
+		var gqlRsp gqlResponse
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(&gqlRsp))
+		return rsp.StatusCode, &gqlRsp
+	}
+
+	t.Run("Relays the request to Trace", func(t *testing.T) {
+		variables := map[string]interface{}{"life": "42"}
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), q, variables, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, query string, variables map[string]interface{}, rsp interface{}) error {
+				return json.Unmarshal([]byte(`{"link": {"data": "decrypted"}}`), rsp)
+			}).Times(1)
+
+		body, _ := json.Marshal(map[string]interface{}{"query": q, "variables": variables})
+		status, rsp := post(t, string(body))
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, rsp.Errors)
+		assert.Equal(t, map[string]interface{}{"data": "decrypted"}, rsp.Data["link"])
+	})
+
+	t.Run("Returns the Trace errors", func(t *testing.T) {
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), q, gomock.Any(), gomock.Any()).Return(errors.New("boom")).Times(1)
+
+		status, rsp := post(t, fmt.Sprintf(`{"query": "%s"}`, q))
+
+		assert.Equal(t, http.StatusBadGateway, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Equal(t, "boom", rsp.Errors[0].Message)
+	})
+
+	t.Run("Relays every GraphQL error", func(t *testing.T) {
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), q, gomock.Any(), gomock.Any()).Return(&client.Error{
+			Kind:   client.ErrValidation,
+			Status: http.StatusBadRequest,
+			Errors: []client.GraphQLError{
+				{Message: "unknown field", Path: []interface{}{"link", "data"}},
+				{Message: "missing argument"},
+			},
+		}).Times(1)
+
+		status, rsp := post(t, fmt.Sprintf(`{"query": "%s"}`, q))
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		require.Len(t, rsp.Errors, 2)
+		assert.Equal(t, "unknown field", rsp.Errors[0].Message)
+		assert.Equal(t, []interface{}{"link", "data"}, rsp.Errors[0].Path)
+		assert.Equal(t, "missing argument", rsp.Errors[1].Message)
+	})
+
+	t.Run("Relays the GraphQL errors without status as is", func(t *testing.T) {
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), q, gomock.Any(), gomock.Any()).Return(&client.Error{
+			Kind:   client.ErrAPI,
+			Errors: []client.GraphQLError{{Message: "unknown field"}},
+		}).Times(1)
+
+		status, rsp := post(t, fmt.Sprintf(`{"query": "%s"}`, q))
+
+		assert.Equal(t, http.StatusOK, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Equal(t, "unknown field", rsp.Errors[0].Message)
+	})
+
+	t.Run("Rejects requests without query", func(t *testing.T) {
+		status, rsp := post(t, `{"variables": {}}`)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Equal(t, proxy.ErrMissingQuery.Error(), rsp.Errors[0].Message)
+	})
+
+	t.Run("Rejects GET requests", func(t *testing.T) {
+		rsp, err := http.Get(ts.URL + "/graphql")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, rsp.StatusCode)
+	})
+}
+
+func TestProxyService_CreateLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockClient := mockclient.NewMockStratumnClient(ctrl)
+
+	s := &proxy.Service{}
+	s.SetConfig(proxy.Config{InsecureNoAuth: true})
+	err := s.Plug(map[string]interface{}{
+		"stratumnClient": mockClient,
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(s.Expose().(http.Handler))
+	defer ts.Close()
+
+	data := map[string]interface{}{"life": "42"}
+	recipients := []*csutils.PublicKeyInfo{&csutils.PublicKeyInfo{ID: "1", PublicKey: []byte(pk)}}
+
+	post := func(t *testing.T, query string, variables map[string]interface{}) (int, *gqlResponse) {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		rsp, err := http.Post(ts.URL+"/graphql", "application/json", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+
+		var gqlRsp gqlResponse
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(&gqlRsp))
+		return rsp.StatusCode, &gqlRsp
+	}
+
+	assertEncrypted := func(t *testing.T, l *cs.Link) {
+		var md struct{ Recipients []*csutils.LinkRecipient }
+		require.NoError(t, json.Unmarshal(l.Meta.Data, &md))
+		require.Len(t, md.Recipients, 1)
+		assert.Equal(t, "1", md.Recipients[0].PubKeyID)
+
+		// Encrypted data is serialized as a byte array.
+		var encData []byte
+		assert.NoError(t, json.Unmarshal(l.Data, &encData), "link data should be encrypted")
+	}
+
+	createLinkMutation := `mutation ($link: JSON!) { createLink(input: {link: $link}) { trace { rowId } } }`
+	createLinksMutation := `mutation ($links: [CreateLinkInput!]) { created: createLinks(input: $links) { links { traceId } } }`
+
+	createLinkPayload := func() *client.CreateLinkPayload {
+		var payload client.CreateLinkPayload
+		payload.CreateLink.Trace.RowID = "42"
+		return &payload
+	}
+
+	// literal writes a value as a GraphQL input value.
+	var literal func(v interface{}) string
+	literal = func(v interface{}) string {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			var fields []string
+			for name, field := range v {
+				fields = append(fields, name+": "+literal(field))
+			}
+			return "{" + strings.Join(fields, ", ") + "}"
+		case []interface{}:
+			var items []string
+			for _, item := range v {
+				items = append(items, literal(item))
+			}
+			return "[" + strings.Join(items, ", ") + "]"
+		default:
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+	}
+
+	t.Run("Encrypts plaintext links before submitting them", func(t *testing.T) {
+		link, _ := cs.NewLinkBuilder("211", "map").WithData(data).Build()
+
+		gomock.InOrder(
+			mockClient.EXPECT().GetRecipientsPublicKeys(gomock.Any(), "211").Return(recipients, nil).Times(1),
+			mockClient.EXPECT().CreateLink(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, l *cs.Link) (*client.CreateLinkPayload, error) {
+					assertEncrypted(t, l)
+					return createLinkPayload(), nil
+				}).Times(1),
+		)
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		status, rsp := post(t, createLinkMutation, map[string]interface{}{"link": link})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, rsp.Errors)
+		assert.Equal(t, map[string]interface{}{"trace": map[string]interface{}{"rowId": "42"}}, rsp.Data["createLink"])
+	})
+
+	t.Run("Encrypts the links of a createLinks mutation", func(t *testing.T) {
+		link1, _ := cs.NewLinkBuilder("211", "map1").WithData(data).Build()
+		link2, _ := cs.NewLinkBuilder("211", "map2").WithData(data).Build()
+
+		mockClient.EXPECT().GetRecipientsPublicKeys(gomock.Any(), "211").Return(recipients, nil).Times(2)
+		mockClient.EXPECT().CreateLinks(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, links []*cs.Link) (*client.CreateLinksPayload, error) {
+				require.Len(t, links, 2)
+				assert.Equal(t, "map1", links[0].Meta.MapId)
+				assert.Equal(t, "map2", links[1].Meta.MapId)
+				for _, l := range links {
+					assertEncrypted(t, l)
+				}
+				return &client.CreateLinksPayload{}, nil
+			}).Times(1)
+
+		status, rsp := post(t, createLinksMutation, map[string]interface{}{
+			"links": []map[string]interface{}{{"link": link1}, {"link": link2}},
+		})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, rsp.Errors)
+		assert.Contains(t, rsp.Data, "created")
+	})
+
+	t.Run("Reads the links given inline", func(t *testing.T) {
+		link, _ := cs.NewLinkBuilder("211", "map").WithData(data).Build()
+		b, _ := json.Marshal(link)
+		var raw map[string]interface{}
+		require.NoError(t, json.Unmarshal(b, &raw))
+
+		query := fmt.Sprintf(`
+			# createLinks(input: $links) is not sent
+			mutation CreateLink {
+				created: createLink(input: {link: %s}) { trace { rowId } }
+			}`, literal(raw))
+
+		mockClient.EXPECT().GetRecipientsPublicKeys(gomock.Any(), "211").Return(recipients, nil).Times(1)
+		mockClient.EXPECT().CreateLink(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, l *cs.Link) (*client.CreateLinkPayload, error) {
+				assert.Equal(t, "map", l.Meta.MapId)
+				assertEncrypted(t, l)
+				return createLinkPayload(), nil
+			}).Times(1)
+
+		status, rsp := post(t, query, nil)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, rsp.Errors)
+		assert.Equal(t, map[string]interface{}{"trace": map[string]interface{}{"rowId": "42"}}, rsp.Data["created"])
+	})
+
+	t.Run("Submits links that are already encrypted", func(t *testing.T) {
+		link, _ := cs.NewLinkBuilder("211", "map").WithData(data).Build()
+		require.NoError(t, csutils.EncryptLink(context.Background(), link, recipients))
+
+		mockClient.EXPECT().GetRecipientsPublicKeys(gomock.Any(), gomock.Any()).Times(0)
+		mockClient.EXPECT().CreateLink(gomock.Any(), gomock.Any()).Return(createLinkPayload(), nil).Times(1)
+
+		status, _ := post(t, createLinkMutation, map[string]interface{}{"link": link})
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("Relays the documents only mentioning createLink", func(t *testing.T) {
+		query := `query { search(text: "createLink(") { id } } # createLink(input: $link)`
+		mockClient.EXPECT().CreateLink(gomock.Any(), gomock.Any()).Times(0)
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), query, gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		status, _ := post(t, query, nil)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("Returns the submission errors", func(t *testing.T) {
+		link, _ := cs.NewLinkBuilder("211", "map").WithData(data).Build()
+		require.NoError(t, csutils.EncryptLink(context.Background(), link, recipients))
+
+		mockClient.EXPECT().CreateLink(gomock.Any(), gomock.Any()).Return(nil, errors.New("no key")).Times(1)
+
+		status, rsp := post(t, createLinkMutation, map[string]interface{}{"link": link})
+
+		assert.Equal(t, http.StatusBadGateway, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Equal(t, "no key", rsp.Errors[0].Message)
+	})
+
+	t.Run("Rejects invalid links", func(t *testing.T) {
+		status, rsp := post(t, client.CreateLinkMutation, map[string]interface{}{"link": "plap"})
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Contains(t, rsp.Errors[0].Message, proxy.ErrBadLink.Error())
+	})
+
+	t.Run("Rejects inputs that are not links", func(t *testing.T) {
+		status, rsp := post(t, `mutation { createLinks(input: [{link: {}, workflowId: 211}]) { links { traceId } } }`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Equal(t, proxy.ErrBadLinkInput.Error(), rsp.Errors[0].Message)
+	})
+
+	t.Run("Rejects createLink mixed with other fields", func(t *testing.T) {
+		link, _ := cs.NewLinkBuilder("211", "map").WithData(data).Build()
+		query := `mutation ($link: JSON!) { createLink(input: {link: $link}) { trace { rowId } } deleteTrace(id: 1) { id } }`
+
+		status, rsp := post(t, query, map[string]interface{}{"link": link})
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Equal(t, proxy.ErrMixedMutation.Error(), rsp.Errors[0].Message)
+	})
+
+	t.Run("Rejects malformed documents", func(t *testing.T) {
+		mockClient.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		status, rsp := post(t, `mutation { createLink(input: {link: "plap"}) { trace { rowId }`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		require.Len(t, rsp.Errors, 1)
+		assert.Contains(t, rsp.Errors[0].Message, proxy.ErrBadDocument.Error())
+	})
+}
+
+func TestProxyService_Auth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mockclient.NewMockStratumnClient(ctrl)
+
+	accountMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer accountMock.Close()
+
+	s := &proxy.Service{}
+	s.SetConfig(proxy.Config{AccountURL: accountMock.URL})
+	err := s.Plug(map[string]interface{}{
+		"stratumnClient": client,
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(s.Expose().(http.Handler))
+	defer ts.Close()
+
+	client.EXPECT().CallTraceGql(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/graphql", bytes.NewBufferString(`{"query": "{}"}`))
+	req.Header.Set("authorization", "Bearer bad token")
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+}
+
+func TestProxyService_MissingAccountURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mockclient.NewMockStratumnClient(ctrl)
+
+	s := &proxy.Service{}
+	s.SetConfig(proxy.Config{Address: "/ip4/0.0.0.0/tcp/8903"})
+	err := s.Plug(map[string]interface{}{
+		"stratumnClient": client,
+	})
+	assert.Equal(t, auth.ErrMissingAccountURL, errors.Cause(err))
+}
+
+func TestProxyService_DefaultConfig(t *testing.T) {
+	plug := func(t *testing.T, config proxy.Config) {
+		ctrl := gomock.NewController(t)
+
+		s := &proxy.Service{}
+		s.SetConfig(config)
+		assert.NoError(t, s.Plug(map[string]interface{}{
+			"stratumnClient": mockclient.NewMockStratumnClient(ctrl),
+		}))
+	}
+
+	t.Run("Plugs with the default config", func(t *testing.T) {
+		s := &proxy.Service{}
+		plug(t, s.Config().(proxy.Config))
+	})
+
+	t.Run("Plugs with the migrated config", func(t *testing.T) {
+		tree, err := toml.LoadFile("../../config.core.toml")
+		require.NoError(t, err)
+
+		var config proxy.Config
+		require.NoError(t, tree.Get("proxy").(*toml.Tree).Unmarshal(&config))
+		assert.Equal(t, "/ip4/127.0.0.1/tcp/8903", config.Address)
+		assert.Empty(t, config.AccountURL)
+		assert.False(t, config.InsecureNoAuth)
+
+		plug(t, config)
+	})
+}
+
+func TestProxyService_BadAccountURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mockclient.NewMockStratumnClient(ctrl)
+
+	s := &proxy.Service{}
+	s.SetConfig(proxy.Config{AccountURL: "test"})
+	err := s.Plug(map[string]interface{}{
+		"stratumnClient": client,
+	})
+	assert.Error(t, err)
+}
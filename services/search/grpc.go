@@ -0,0 +1,208 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/stratumn/go-connector/services/search/grpc"
+)
+
+//go:generate protoc --proto_path=$GOPATH/src --go_out=plugins=grpc:$GOPATH/src github.com/stratumn/go-connector/services/search/grpc/search.proto
+
+var (
+	// ErrUnavailable is returned from gRPC methods when the service is not
+	// available.
+	ErrUnavailable = errors.New("the service is not available")
+)
+
+// AddToGRPCServer adds the service to a gRPC server.
+func (s *Service) AddToGRPCServer(gs *grpc.Server) {
+	pb.RegisterSearchServer(gs, grpcServer{
+		GetSearcher: func() Searcher {
+			return s.searcher
+		},
+		Authenticate: func(ctx context.Context) error {
+			return s.grpcAuth.Authenticate(ctx)
+		},
+	})
+}
+
+// grpcServer is a gRPC server for the search service.
+type grpcServer struct {
+	GetSearcher  func() Searcher
+	Authenticate func(context.Context) error
+}
+
+// Search searches the indexed links.
+func (s grpcServer) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	searcher := s.GetSearcher()
+	if searcher == nil {
+		return nil, status.Error(codes.Unavailable, ErrUnavailable.Error())
+	}
+	if err := s.Authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.Query == "" && len(req.Must) == 0 && len(req.Should) == 0 && len(req.MustNot) == 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrMissingQuery.Error())
+	}
+
+	q, err := fromProtoRequest(req)
+	if err == nil {
+		err = q.Validate()
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res, err := searcher.Search(ctx, q)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	rsp := &pb.SearchResponse{
+		Hits:  make([]*pb.Hit, len(res.Hits)),
+		Total: res.Total,
+		From:  int32(res.From),
+		Size:  int32(res.Size),
+	}
+	for i, h := range res.Hits {
+		hit, err := toProtoHit(h)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		rsp.Hits[i] = hit
+	}
+
+	return rsp, nil
+}
+
+// fromProtoRequest builds the structured query of a gRPC search request.
+func fromProtoRequest(req *pb.SearchRequest) (*Query, error) {
+	q := &Query{
+		Text: req.Query,
+		Sort: req.Sort,
+		From: int(req.From),
+		Size: int(req.Size),
+
+		Highlight: req.Highlight,
+		Explain:   req.Explain,
+	}
+
+	var err error
+	if q.Must, err = fromProtoClauses(req.Must); err != nil {
+		return nil, errors.Wrap(err, "must")
+	}
+	if q.Should, err = fromProtoClauses(req.Should); err != nil {
+		return nil, errors.Wrap(err, "should")
+	}
+	if q.MustNot, err = fromProtoClauses(req.MustNot); err != nil {
+		return nil, errors.Wrap(err, "mustNot")
+	}
+
+	return q, nil
+}
+
+func fromProtoClauses(clauses []*pb.Clause) ([]*Clause, error) {
+	if len(clauses) == 0 {
+		return nil, nil
+	}
+
+	res := make([]*Clause, len(clauses))
+	for i, c := range clauses {
+		if c == nil {
+			return nil, ErrBadClause
+		}
+
+		clause := &Clause{
+			Field: c.Field,
+			Term:  c.Term,
+			Match: c.Match,
+		}
+
+		if r := c.NumericRange; r != nil {
+			min, max := r.GetMin(), r.GetMax()
+			if min == nil && max == nil {
+				return nil, errors.Wrap(ErrBadClause, c.Field)
+			}
+			if min != nil {
+				clause.Min = &min.Value
+			}
+			if max != nil {
+				clause.Max = &max.Value
+			}
+		}
+
+		if r := c.DateRange; r != nil {
+			if r.Start == "" && r.End == "" {
+				return nil, errors.Wrap(ErrBadClause, c.Field)
+			}
+			var err error
+			if clause.Start, err = parseDate(r.Start); err != nil {
+				return nil, errors.Wrap(err, c.Field)
+			}
+			if clause.End, err = parseDate(r.End); err != nil {
+				return nil, errors.Wrap(err, c.Field)
+			}
+		}
+
+		res[i] = clause
+	}
+
+	return res, nil
+}
+
+// parseDate parses an optional RFC 3339 date.
+func parseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &t, nil
+}
+
+func toProtoHit(h *Hit) (*pb.Hit, error) {
+	raw, err := json.Marshal(h.Link)
+	if err != nil {
+		return nil, err
+	}
+
+	hit := &pb.Hit{
+		LinkHash: h.LinkHash,
+		Raw:      string(raw),
+		Score:    h.Score,
+	}
+
+	fields := make([]string, 0, len(h.Fragments))
+	for field := range h.Fragments {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		hit.Fragments = append(hit.Fragments, &pb.Fragments{
+			Field:     field,
+			Fragments: h.Fragments[field],
+		})
+	}
+
+	if h.Explanation != nil {
+		expl, err := json.Marshal(h.Explanation)
+		if err != nil {
+			return nil, err
+		}
+		hit.Explanation = string(expl)
+	}
+
+	return hit, nil
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
+)
+
+var (
+	// ErrMissingQuery is returned when the search request has no query string.
+	ErrMissingQuery = errors.New("the q parameter must be provided")
+)
+
+type handler struct {
+	searcher Searcher
+}
+
+// NewHandler returns an HTTP handler serving the search endpoint.
+// GET /search runs a full-text search built from the q, from, size, sort,
+// highlight and explain parameters and POST /search runs the structured
+// Query given in the request body.
+// Every route is guarded by the authentication middleware.
+func NewHandler(searcher Searcher, m auth.Middleware) http.Handler {
+	h := &handler{searcher: searcher}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", m.WithAuth(h.search))
+
+	return mux
+}
+
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	var q *Query
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		q, err = parseQueryParams(r)
+	case http.MethodPost:
+		q = &Query{}
+		err = errors.Wrap(json.NewDecoder(r.Body).Decode(q), "invalid query")
+	default:
+		httpapi.WriteError(w, http.StatusMethodNotAllowed, errors.New("search requests must use the GET or POST method"))
+		return
+	}
+	if err == nil {
+		err = q.Validate()
+	}
+	if err != nil {
+		httpapi.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := h.searcher.Search(r.Context(), q)
+	if err != nil {
+		log.Errorf("Search failed: %s", err)
+		httpapi.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, res)
+}
+
+// parseQueryParams builds a full-text query from the URL parameters.
+func parseQueryParams(r *http.Request) (*Query, error) {
+	params := r.URL.Query()
+
+	q := &Query{Text: params.Get("q")}
+	if q.Text == "" {
+		return nil, ErrMissingQuery
+	}
+
+	var err error
+	q.From, err = intParam(params.Get("from"), 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "from")
+	}
+
+	q.Size, err = intParam(params.Get("size"), DefaultSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "size")
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		q.Sort = strings.Split(sort, ",")
+	}
+
+	q.Highlight, err = boolParam(params.Get("highlight"))
+	if err != nil {
+		return nil, errors.Wrap(err, "highlight")
+	}
+
+	q.Explain, err = boolParam(params.Get("explain"))
+	if err != nil {
+		return nil, errors.Wrap(err, "explain")
+	}
+
+	return q, nil
+}
+
+// intParam parses an integer query parameter, using the default value when
+// the parameter is missing.
+func intParam(p string, defaultValue int) (int, error) {
+	if p == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(p)
+	if err != nil {
+		return 0, errors.Errorf("%s is not an integer", p)
+	}
+
+	return i, nil
+}
+
+// boolParam parses a boolean query parameter, which is false when missing.
+func boolParam(p string) (bool, error) {
+	if p == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(p)
+	if err != nil {
+		return false, errors.Errorf("%s is not a boolean", p)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,242 @@
+package search
+
+import (
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMissingField is returned when a clause does not target a field.
+	ErrMissingField = errors.New("a clause must have a field")
+
+	// ErrBadClause is returned when a clause is not exactly one of a term,
+	// match, numeric range or date range clause.
+	ErrBadClause = errors.New("a clause must be exactly one of term, match, numeric range or date range")
+
+	// ErrNotTermField is returned when a term clause targets a field that is
+	// not indexed as a keyword.
+	ErrNotTermField = errors.New("term clauses are only supported on meta.action, meta.process.state, meta.mapId and metadata.<key>")
+)
+
+// termFields are the meta fields indexed as keywords that accept exact term
+// filters. The metadata fields are all indexed as keywords too.
+// They must be kept in sync with the keyword fields of the blevestore mapping.
+var termFields = []string{
+	"meta.action",
+	"meta.process.state",
+	"meta.mapId",
+}
+
+// Query is a structured search query.
+// The full-text and must clauses all have to match, at least one of the
+// should clauses has to match when there is no must clause and none of the
+// must not clauses may match.
+// An empty query matches all the links.
+type Query struct {
+	// Text is a fuzzy full-text search on the link data and meta.
+	Text string `json:"text,omitempty"`
+
+	Must    []*Clause `json:"must,omitempty"`
+	Should  []*Clause `json:"should,omitempty"`
+	MustNot []*Clause `json:"mustNot,omitempty"`
+
+	// Sort lists the fields to sort on, prefixed with "-" for a descending
+	// order. "_score" sorts by relevance.
+	Sort []string `json:"sort,omitempty"`
+
+	// From is the offset of the first returned link.
+	From int `json:"from,omitempty"`
+	// Size is the maximum number of links returned.
+	Size int `json:"size,omitempty"`
+
+	// Highlight adds the highlighted fragments of the matched data fields
+	// to the hits.
+	Highlight bool `json:"highlight,omitempty"`
+	// Explain adds the explanation of the score to the hits.
+	Explain bool `json:"explain,omitempty"`
+}
+
+// Clause is a condition on a single field.
+// Exactly one of Term, Match, the numeric range (Min/Max) or the date range
+// (Start/End) must be set. Range bounds are inclusive and may be omitted.
+type Clause struct {
+	Field string `json:"field"`
+
+	// Term matches the exact value of a keyword field.
+	Term string `json:"term,omitempty"`
+	// Match runs an analyzed full-text match on the field.
+	Match string `json:"match,omitempty"`
+
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// IsTermField returns whether term clauses are supported on a field.
+func IsTermField(field string) bool {
+	if strings.HasPrefix(field, "metadata.") {
+		return len(field) > len("metadata.")
+	}
+	for _, f := range termFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the clause is well-formed.
+func (c *Clause) Validate() error {
+	if c == nil {
+		return ErrBadClause
+	}
+	if c.Field == "" {
+		return ErrMissingField
+	}
+
+	kinds := 0
+	if c.Term != "" {
+		kinds++
+		if !IsTermField(c.Field) {
+			return errors.Wrap(ErrNotTermField, c.Field)
+		}
+	}
+	if c.Match != "" {
+		kinds++
+	}
+	if c.Min != nil || c.Max != nil {
+		kinds++
+	}
+	if c.Start != nil || c.End != nil {
+		kinds++
+	}
+
+	if kinds != 1 {
+		return errors.Wrap(ErrBadClause, c.Field)
+	}
+
+	return nil
+}
+
+// BleveQuery converts the clause to a bleve query.
+func (c *Clause) BleveQuery() (query.Query, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.Term != "":
+		q := bleve.NewTermQuery(c.Term)
+		q.SetField(c.Field)
+		return q, nil
+	case c.Match != "":
+		q := bleve.NewMatchQuery(c.Match)
+		q.SetField(c.Field)
+		return q, nil
+	case c.Min != nil || c.Max != nil:
+		inclusive := true
+		q := bleve.NewNumericRangeInclusiveQuery(c.Min, c.Max, &inclusive, &inclusive)
+		q.SetField(c.Field)
+		return q, nil
+	default:
+		var start, end time.Time
+		if c.Start != nil {
+			start = *c.Start
+		}
+		if c.End != nil {
+			end = *c.End
+		}
+		inclusive := true
+		q := bleve.NewDateRangeInclusiveQuery(start, end, &inclusive, &inclusive)
+		q.SetField(c.Field)
+		return q, nil
+	}
+}
+
+// Validate checks that all the clauses of the query are well-formed.
+func (q *Query) Validate() error {
+	groups := []struct {
+		name    string
+		clauses []*Clause
+	}{
+		{"must", q.Must},
+		{"should", q.Should},
+		{"mustNot", q.MustNot},
+	}
+	for _, g := range groups {
+		for _, c := range g.clauses {
+			if err := c.Validate(); err != nil {
+				return errors.Wrap(err, g.name)
+			}
+		}
+	}
+	return nil
+}
+
+// BleveQuery converts the structured query to a bleve query.
+// It does not handle sorting and paging.
+func (q *Query) BleveQuery() (query.Query, error) {
+	if q.Text == "" && len(q.Must) == 0 && len(q.Should) == 0 && len(q.MustNot) == 0 {
+		return bleve.NewMatchAllQuery(), nil
+	}
+
+	var must []query.Query
+	if q.Text != "" {
+		text := bleve.NewMatchQuery(q.Text)
+		text.Fuzziness = 1
+		must = append(must, text)
+	}
+
+	must, err := appendClauses(must, q.Must)
+	if err != nil {
+		return nil, errors.Wrap(err, "must")
+	}
+
+	should, err := appendClauses(nil, q.Should)
+	if err != nil {
+		return nil, errors.Wrap(err, "should")
+	}
+
+	mustNot, err := appendClauses(nil, q.MustNot)
+	if err != nil {
+		return nil, errors.Wrap(err, "mustNot")
+	}
+
+	// A single condition does not need a boolean query.
+	if len(must) == 1 && len(should) == 0 && len(mustNot) == 0 {
+		return must[0], nil
+	}
+
+	bq := bleve.NewBooleanQuery()
+	if len(must) > 0 {
+		bq.AddMust(must...)
+	}
+	if len(should) > 0 {
+		bq.AddShould(should...)
+		if len(must) == 0 {
+			bq.SetMinShould(1)
+		}
+	}
+	if len(mustNot) > 0 {
+		bq.AddMustNot(mustNot...)
+	}
+
+	return bq, nil
+}
+
+func appendClauses(queries []query.Query, clauses []*Clause) ([]query.Query, error) {
+	for _, c := range clauses {
+		cq, err := c.BleveQuery()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, cq)
+	}
+	return queries, nil
+}
@@ -3,15 +3,53 @@ package search
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
 	"github.com/pkg/errors"
 	cs "github.com/stratumn/go-chainscript"
 )
 
+const (
+	// DefaultSize is the number of links returned when no size is given.
+	DefaultSize = 10
+
+	// MaxSize is the maximum number of links returned by a single search.
+	MaxSize = 100
+)
+
 // Searcher is the type sxposed by the search service.
 type Searcher interface {
-	Search(context.Context, string) ([]*cs.Link, error)
+	// Search returns a page of the links matching a query.
+	Search(ctx context.Context, q *Query) (*Results, error)
+}
+
+// Results is a page of search results.
+type Results struct {
+	Hits []*Hit `json:"hits"`
+
+	// Total is the number of links matching the search.
+	Total uint64 `json:"total"`
+	// From is the offset of the first returned link.
+	From int `json:"from"`
+	// Size is the maximum number of links in the page.
+	Size int `json:"size"`
+}
+
+// Hit is a link matching a search.
+type Hit struct {
+	LinkHash string   `json:"linkHash"`
+	Link     *cs.Link `json:"link"`
+
+	// Score is the relevance of the link for the search.
+	Score float64 `json:"score"`
+	// Fragments are the highlighted fragments of the matched data fields,
+	// indexed by field name. It is only set when highlighting is requested.
+	Fragments map[string][]string `json:"fragments,omitempty"`
+	// Explanation details how the score was computed. It is only set when
+	// requested.
+	Explanation *search.Explanation `json:"explanation,omitempty"`
 }
 
 type searcher struct {
@@ -24,20 +62,46 @@ func newSearcher(idx bleve.Index) Searcher {
 	}
 }
 
-// Search searches for all the links that contain a given
-// string in either their data or meta.
-func (s *searcher) Search(ctx context.Context, str string) ([]*cs.Link, error) {
-	res := []*cs.Link{}
+// Search searches for all the links matching a structured query.
+func (s *searcher) Search(ctx context.Context, q *Query) (*Results, error) {
+	from, size := q.From, q.Size
+	if from < 0 {
+		from = 0
+	}
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if size > MaxSize {
+		size = MaxSize
+	}
+
+	res := &Results{
+		Hits: []*Hit{},
+		From: from,
+		Size: size,
+	}
+
+	bq, err := q.BleveQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	req := bleve.NewSearchRequestOptions(bq, size, from, q.Explain)
+	req.Fields = []string{"*"}
+	if len(q.Sort) > 0 {
+		req.SortBy(q.Sort)
+	}
+	if q.Highlight {
+		req.Highlight = bleve.NewHighlight()
+	}
 
-	query := bleve.NewMatchQuery(str)
-	query.Fuzziness = 1
-	search := bleve.NewSearchRequest(query)
-	search.Fields = []string{"*"}
-	searchResults, err := s.idx.Search(search)
+	searchResults, err := s.idx.Search(req)
 	if err != nil {
 		return nil, err
 	}
 
+	res.Total = searchResults.Total
+
 	for _, l := range searchResults.Hits {
 		var link cs.Link
 
@@ -50,8 +114,31 @@ func (s *searcher) Search(ctx context.Context, str string) ([]*cs.Link, error) {
 		if err != nil {
 			return nil, err
 		}
-		res = append(res, &link)
+
+		res.Hits = append(res.Hits, &Hit{
+			LinkHash:    l.ID,
+			Link:        &link,
+			Score:       l.Score,
+			Fragments:   dataFragments(l.Fragments),
+			Explanation: l.Expl,
+		})
 	}
 
 	return res, nil
 }
+
+// dataFragments only keeps the fragments of the link data fields, the other
+// fields are either not stored or not meant to be displayed.
+func dataFragments(fragments search.FieldFragmentMap) map[string][]string {
+	var res map[string][]string
+	for field, f := range fragments {
+		if !strings.HasPrefix(field, "data.") {
+			continue
+		}
+		if res == nil {
+			res = map[string][]string{}
+		}
+		res[field] = f
+	}
+	return res
+}
@@ -2,12 +2,19 @@ package search
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/blevesearch/bleve"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stratumn/go-node/core/cfg"
+
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/lib/httpapi"
 )
 
+var log = logrus.WithField("service", "search")
+
 var (
 	// ErrNotStore is returned when the connected service is not a store exposind a DB.
 	ErrNotStore = errors.New("connected service is exposing neither a DB not a bleve index")
@@ -17,6 +24,9 @@ var (
 type Service struct {
 	config   *Config
 	searcher Searcher
+
+	handler  http.Handler
+	grpcAuth *auth.GRPCAuth
 }
 
 // Config contains configuration options for the Memorystore service.
@@ -26,6 +36,16 @@ type Config struct {
 
 	// The name of the store service used for the search.
 	Store string `toml:"store" comment:"The name of the store service."`
+
+	// Address is the address the HTTP search endpoint binds to.
+	Address string `toml:"address" comment:"Address of the HTTP search endpoint. Leave empty to disable it."`
+
+	// AccountURL is the URL of the Account API used to authenticate requests.
+	// Without it, the gRPC API only accepts the calls of local clients.
+	AccountURL string `toml:"account_url" comment:"The URL of Stratumn Account APIs used to authenticate requests. Required when the address is not a loopback address. Without it, the gRPC API only accepts local clients."`
+
+	// AuthorizedAccounts restricts the access to a list of accounts.
+	AuthorizedAccounts []string `toml:"authorized_accounts" comment:"The IDs of the accounts allowed to search. Leave empty to allow any authenticated account."`
 }
 
 // ID returns the unique identifier of the service.
@@ -50,7 +70,9 @@ func (s *Service) Config() interface{} {
 		return *s.config
 	}
 
-	return Config{}
+	return Config{
+		Address: "/ip4/127.0.0.1/tcp/8907",
+	}
 }
 
 // SetConfig configures the service.
@@ -74,6 +96,26 @@ func (s *Service) Plug(exposed map[string]interface{}) error {
 
 	s.searcher = newSearcher(idx)
 
+	// The HTTP endpoint serves decrypted data, so it is only exposed
+	// without authentication to local clients.
+	var middleware auth.Middleware
+	if s.config.Address != "" {
+		var err error
+		middleware, err = auth.NewEndpointMiddleware(s.config.Address, s.config.AccountURL, s.config.AuthorizedAccounts)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.handler = NewHandler(s.searcher, middleware)
+
+	// The gRPC API serves decrypted data too.
+	grpcAuth, err := auth.NewGRPCAuth(s.config.AccountURL, s.config.AuthorizedAccounts)
+	if err != nil {
+		return err
+	}
+	s.grpcAuth = grpcAuth
+
 	return nil
 }
 
@@ -84,12 +126,28 @@ func (s *Service) Expose() interface{} {
 }
 
 // Run starts the service.
+// It serves the HTTP search endpoint if an address is configured.
 func (s *Service) Run(ctx context.Context, running, stopping func()) error {
+	if s.config.Address == "" {
+		running()
+		<-ctx.Done()
+		stopping()
+
+		return errors.WithStack(ctx.Err())
+	}
+
+	lis, err := httpapi.Listen(s.config.Address)
+	if err != nil {
+		return err
+	}
 
 	running()
-	<-ctx.Done()
+	err = httpapi.Serve(ctx, lis, s.handler)
 	stopping()
 
+	if err != nil {
+		return err
+	}
 	return errors.WithStack(ctx.Err())
 }
 
@@ -106,5 +164,12 @@ func (s *Service) Migrations() []cfg.MigrateHandler {
 		func(tree *cfg.Tree) error {
 			return tree.Set("store", "blevestore")
 		},
+		func(tree *cfg.Tree) error {
+			err := tree.Set("address", "/ip4/127.0.0.1/tcp/8907")
+			if err != nil {
+				return err
+			}
+			return tree.Set("account_url", "")
+		},
 	}
 }
@@ -3,18 +3,32 @@ package search_test
 import (
 	"context"
 	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/blevesearch/bleve"
 	bs "github.com/blevesearch/bleve/search"
 	"github.com/blevesearch/bleve/search/query"
 	"github.com/golang/mock/gomock"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	chainscript "github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-connector/lib/auth"
+	"github.com/stratumn/go-connector/services/blevestore"
 	"github.com/stratumn/go-connector/services/blevestore/mockblevestore"
 	"github.com/stratumn/go-connector/services/search"
+	pb "github.com/stratumn/go-connector/services/search/grpc"
 )
 
 func TestSearchService(t *testing.T) {
@@ -55,22 +69,35 @@ func TestSearchService(t *testing.T) {
 		require.True(t, ok, "the query should be a match query")
 		assert.Equal(t, str, q.Match)
 
+		assert.Equal(t, 0, r.From)
+		assert.Equal(t, search.DefaultSize, r.Size)
+
 		return &bleve.SearchResult{
+			Total: 42,
 			Hits: []*bs.DocumentMatch{
-				&bs.DocumentMatch{Fields: map[string]interface{}{
+				&bs.DocumentMatch{ID: "lh1", Score: 0.8, Fields: map[string]interface{}{
 					"raw": string(lb1),
 				}},
-				&bs.DocumentMatch{Fields: map[string]interface{}{
+				&bs.DocumentMatch{ID: "lh2", Score: 0.2, Fields: map[string]interface{}{
 					"raw": string(lb2),
 				}},
 			},
 		}, nil
 	})
 
-	links, err := searcher.Search(ctx, str)
+	res, err := searcher.Search(ctx, &search.Query{Text: str})
 	assert.NoError(t, err)
 
-	assert.Len(t, links, 2)
+	assert.Equal(t, uint64(42), res.Total)
+	assert.Equal(t, search.DefaultSize, res.Size)
+
+	hits := res.Hits
+	require.Len(t, hits, 2)
+	assert.Equal(t, "lh1", hits[0].LinkHash)
+	assert.Equal(t, 0.8, hits[0].Score)
+	assert.Equal(t, "lh2", hits[1].LinkHash)
+	assert.Equal(t, 0.2, hits[1].Score)
+	links := []*chainscript.Link{hits[0].Link, hits[1].Link}
 
 	// links[0].data = d1
 	data := map[string]interface{}{}
@@ -84,3 +111,494 @@ func TestSearchService(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, d2, data)
 }
+
+func TestSearchService_HTTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mockblevestore.NewMockIndex(ctrl)
+
+	s := &search.Service{}
+	s.SetConfig(search.Config{
+		Store: "blevestore",
+	})
+	s.Plug(map[string]interface{}{
+		"blevestore": mockStore,
+	})
+
+	searcher := s.Expose().(search.Searcher)
+	ts := httptest.NewServer(search.NewHandler(searcher, auth.NoAuthMiddleware{}))
+	defer ts.Close()
+
+	l, _ := chainscript.NewLinkBuilder("p", "m").WithData(map[string]interface{}{"life": "42"}).Build()
+	lb, _ := json.Marshal(l)
+
+	t.Run("Returns a page of links", func(t *testing.T) {
+		mockStore.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *bleve.SearchRequest) (*bleve.SearchResult, error) {
+			q, ok := r.Query.(*query.MatchQuery)
+			require.True(t, ok, "the query should be a match query")
+			assert.Equal(t, "life", q.Match)
+			assert.Equal(t, 10, r.From)
+			assert.Equal(t, 5, r.Size)
+
+			return &bleve.SearchResult{
+				Total: 11,
+				Hits: []*bs.DocumentMatch{
+					&bs.DocumentMatch{Fields: map[string]interface{}{
+						"raw": string(lb),
+					}},
+				},
+			}, nil
+		})
+
+		rsp, err := http.Get(ts.URL + "/search?q=life&from=10&size=5")
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+		var res search.Results
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(&res))
+		assert.Equal(t, uint64(11), res.Total)
+		assert.Equal(t, 10, res.From)
+		assert.Equal(t, 5, res.Size)
+		require.Len(t, res.Hits, 1)
+		assert.Equal(t, l.Data, res.Hits[0].Link.Data)
+	})
+
+	t.Run("Requires a query", func(t *testing.T) {
+		rsp, err := http.Get(ts.URL + "/search")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	})
+
+	t.Run("Rejects bad pagination", func(t *testing.T) {
+		rsp, err := http.Get(ts.URL + "/search?q=life&size=plap")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	})
+
+	t.Run("Runs a structured query", func(t *testing.T) {
+		mockStore.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *bleve.SearchRequest) (*bleve.SearchResult, error) {
+			q, ok := r.Query.(*query.BooleanQuery)
+			require.True(t, ok, "the query should be a boolean query")
+			assert.NotNil(t, q.Must)
+			assert.NotNil(t, q.MustNot)
+			assert.Equal(t, 20, r.Size)
+			require.Len(t, r.Sort, 1)
+
+			return &bleve.SearchResult{Total: 0}, nil
+		})
+
+		body := `{"must":[{"field":"meta.action","term":"init"}],"mustNot":[{"field":"meta.priority","min":2}],"sort":["-meta.priority"],"size":20}`
+		rsp, err := http.Post(ts.URL+"/search", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	})
+
+	t.Run("Rejects invalid structured queries", func(t *testing.T) {
+		body := `{"must":[{"field":"data.name","term":"alice"}]}`
+		rsp, err := http.Post(ts.URL+"/search", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	})
+}
+
+func TestSearchService_MissingAccountURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mockblevestore.NewMockIndex(ctrl)
+
+	s := &search.Service{}
+	s.SetConfig(search.Config{
+		Store:   "blevestore",
+		Address: "/ip4/0.0.0.0/tcp/8907",
+	})
+
+	err := s.Plug(map[string]interface{}{
+		"blevestore": mockStore,
+	})
+	assert.Equal(t, auth.ErrMissingAccountURL, errors.Cause(err))
+}
+
+func TestSearchService_DefaultConfig(t *testing.T) {
+	plug := func(t *testing.T, config search.Config) {
+		ctrl := gomock.NewController(t)
+
+		s := &search.Service{}
+		s.SetConfig(config)
+		assert.NoError(t, s.Plug(map[string]interface{}{
+			"blevestore": mockblevestore.NewMockIndex(ctrl),
+		}))
+	}
+
+	t.Run("Plugs with the default config", func(t *testing.T) {
+		s := &search.Service{}
+		config := s.Config().(search.Config)
+		config.Store = "blevestore"
+		plug(t, config)
+	})
+
+	t.Run("Plugs with the migrated config", func(t *testing.T) {
+		tree, err := toml.LoadFile("../../config.core.toml")
+		require.NoError(t, err)
+
+		var config search.Config
+		require.NoError(t, tree.Get("search").(*toml.Tree).Unmarshal(&config))
+		assert.Equal(t, "/ip4/127.0.0.1/tcp/8907", config.Address)
+		assert.Empty(t, config.AccountURL)
+
+		plug(t, config)
+	})
+}
+
+func TestSearchService_Query(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Use an in-memory blevestore to check the index mapping.
+	store := &blevestore.Service{}
+	store.SetConfig(store.Config())
+	runningCh := make(chan struct{})
+	go store.Run(ctx, func() { runningCh <- struct{}{} }, func() {})
+	<-runningCh
+
+	idx := store.Expose().(bleve.Index)
+
+	s := &search.Service{}
+	s.SetConfig(search.Config{
+		Store: "blevestore",
+	})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"blevestore": idx,
+	}))
+	searcher := s.Expose().(search.Searcher)
+
+	docs := []struct {
+		action   string
+		state    string
+		priority float64
+		formID   string
+		comment  string
+	}{
+		{"Consent request", "FREE", 1, "12", ""},
+		{"Initialization", "FREE", 2, "12", "Needs review"},
+		{"Consent request", "DONE", 3, "13", ""},
+	}
+	for i, d := range docs {
+		l, _ := chainscript.NewLinkBuilder("p", "m").WithAction(d.action).WithProcessState(d.state).WithPriority(d.priority).Build()
+		lb, _ := json.Marshal(l)
+		require.NoError(t, idx.Index(strconv.Itoa(i), map[string]interface{}{
+			"type": "root",
+			"raw":  string(lb),
+			"meta": map[string]interface{}{
+				"action":   d.action,
+				"priority": d.priority,
+				"process":  map[string]interface{}{"state": d.state},
+			},
+			"metadata": map[string]interface{}{"formId": d.formID, "comment": d.comment},
+		}))
+	}
+
+	one, two := 1., 2.
+	tests := []struct {
+		name    string
+		query   *search.Query
+		actions []string
+	}{{
+		"match all",
+		&search.Query{Sort: []string{"meta.priority"}},
+		[]string{"Consent request", "Initialization", "Consent request"},
+	}, {
+		"term filter",
+		&search.Query{
+			Must: []*search.Clause{{Field: "meta.action", Term: "Consent request"}},
+			Sort: []string{"-meta.priority"},
+		},
+		[]string{"Consent request", "Consent request"},
+	}, {
+		"metadata term filter",
+		&search.Query{
+			Must: []*search.Clause{{Field: "metadata.comment", Term: "Needs review"}},
+		},
+		[]string{"Initialization"},
+	}, {
+		"numeric range",
+		&search.Query{
+			Must: []*search.Clause{{Field: "meta.priority", Min: &one, Max: &two}},
+			Sort: []string{"meta.priority"},
+		},
+		[]string{"Consent request", "Initialization"},
+	}, {
+		"boolean composition",
+		&search.Query{
+			Should: []*search.Clause{
+				{Field: "metadata.formId", Term: "12"},
+				{Field: "meta.process.state", Term: "DONE"},
+			},
+			MustNot: []*search.Clause{{Field: "meta.action", Term: "Initialization"}},
+			Sort:    []string{"meta.priority"},
+		},
+		[]string{"Consent request", "Consent request"},
+	}, {
+		"paging",
+		&search.Query{Sort: []string{"meta.priority"}, From: 1, Size: 1},
+		[]string{"Initialization"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := searcher.Search(ctx, tt.query)
+			require.NoError(t, err)
+
+			var actions []string
+			for _, h := range res.Hits {
+				actions = append(actions, h.Link.Meta.Action)
+			}
+			assert.Equal(t, tt.actions, actions)
+		})
+	}
+
+	t.Run("total", func(t *testing.T) {
+		res, err := searcher.Search(ctx, &search.Query{Size: 1})
+		require.NoError(t, err)
+		assert.Len(t, res.Hits, 1)
+		assert.Equal(t, uint64(3), res.Total)
+	})
+
+	t.Run("term on an analyzed field", func(t *testing.T) {
+		_, err := searcher.Search(ctx, &search.Query{
+			Must: []*search.Clause{{Field: "data.name", Term: "alice"}},
+		})
+		assert.Equal(t, search.ErrNotTermField, errors.Cause(err))
+	})
+
+	t.Run("term on the whole metadata", func(t *testing.T) {
+		_, err := searcher.Search(ctx, &search.Query{
+			Must: []*search.Clause{{Field: "metadata.", Term: "alice"}},
+		})
+		assert.Equal(t, search.ErrNotTermField, errors.Cause(err))
+	})
+
+	t.Run("ambiguous clause", func(t *testing.T) {
+		_, err := searcher.Search(ctx, &search.Query{
+			Must: []*search.Clause{{Field: "meta.priority", Term: "1", Min: &one}},
+		})
+		assert.Equal(t, search.ErrBadClause, errors.Cause(err))
+	})
+
+	t.Run("highlights and explanation", func(t *testing.T) {
+		l, _ := chainscript.NewLinkBuilder("p", "m").WithAction("Note").Build()
+		lb, _ := json.Marshal(l)
+		require.NoError(t, idx.Index("deadbeef", map[string]interface{}{
+			"type":     "root",
+			"raw":      string(lb),
+			"meta":     map[string]interface{}{"action": "Note"},
+			"metadata": map[string]interface{}{"formId": "42"},
+			"data":     map[string]interface{}{"title": "Alice in Wonderland"},
+		}))
+
+		res, err := searcher.Search(ctx, &search.Query{
+			Text:      "wonderland",
+			Highlight: true,
+			Explain:   true,
+		})
+		require.NoError(t, err)
+		require.Len(t, res.Hits, 1)
+
+		hit := res.Hits[0]
+		assert.Equal(t, "deadbeef", hit.LinkHash)
+		assert.True(t, hit.Score > 0)
+		require.Contains(t, hit.Fragments, "data.title")
+		assert.Contains(t, hit.Fragments["data.title"][0], "<mark>Wonderland</mark>")
+		assert.NotNil(t, hit.Explanation)
+
+		res, err = searcher.Search(ctx, &search.Query{Text: "wonderland"})
+		require.NoError(t, err)
+		require.Len(t, res.Hits, 1)
+		assert.Nil(t, res.Hits[0].Fragments)
+		assert.Nil(t, res.Hits[0].Explanation)
+	})
+}
+
+func TestSearchService_GRPC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mockblevestore.NewMockIndex(ctrl)
+
+	s := &search.Service{}
+	s.SetConfig(search.Config{
+		Store: "blevestore",
+	})
+	s.Plug(map[string]interface{}{
+		"blevestore": mockStore,
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	gs := grpc.NewServer()
+	s.AddToGRPCServer(gs)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewSearchClient(conn)
+	ctx := context.Background()
+
+	l, _ := chainscript.NewLinkBuilder("p", "m").WithData(map[string]interface{}{"life": "42"}).Build()
+	lb, _ := json.Marshal(l)
+
+	t.Run("Returns a page of links", func(t *testing.T) {
+		mockStore.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *bleve.SearchRequest) (*bleve.SearchResult, error) {
+			assert.Equal(t, 2, r.From)
+			assert.Equal(t, 3, r.Size)
+
+			return &bleve.SearchResult{
+				Total: 6,
+				Hits: []*bs.DocumentMatch{
+					&bs.DocumentMatch{
+						ID:    "lh",
+						Score: 0.5,
+						Fields: map[string]interface{}{
+							"raw": string(lb),
+						},
+						Fragments: bs.FieldFragmentMap{
+							"data.life": []string{"<mark>42</mark>"},
+						},
+					},
+				},
+			}, nil
+		})
+
+		rsp, err := client.Search(ctx, &pb.SearchRequest{Query: "life", From: 2, Size: 3, Highlight: true})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(6), rsp.Total)
+		assert.Equal(t, int32(2), rsp.From)
+		assert.Equal(t, int32(3), rsp.Size)
+		require.Len(t, rsp.Hits, 1)
+		assert.Equal(t, "lh", rsp.Hits[0].LinkHash)
+		assert.Equal(t, 0.5, rsp.Hits[0].Score)
+		require.Len(t, rsp.Hits[0].Fragments, 1)
+		assert.Equal(t, "data.life", rsp.Hits[0].Fragments[0].Field)
+		assert.Equal(t, []string{"<mark>42</mark>"}, rsp.Hits[0].Fragments[0].Fragments)
+
+		var link chainscript.Link
+		require.NoError(t, json.Unmarshal([]byte(rsp.Hits[0].Raw), &link))
+		assert.Equal(t, l.Data, link.Data)
+	})
+
+	t.Run("Filters on clauses", func(t *testing.T) {
+		mockStore.EXPECT().Search(gomock.Any()).DoAndReturn(func(r *bleve.SearchRequest) (*bleve.SearchResult, error) {
+			bq, ok := r.Query.(*query.BooleanQuery)
+			require.True(t, ok, "the query should be a boolean query")
+
+			must, ok := bq.Must.(*query.ConjunctionQuery)
+			require.True(t, ok)
+			require.Len(t, must.Conjuncts, 3)
+
+			term, ok := must.Conjuncts[0].(*query.TermQuery)
+			require.True(t, ok)
+			assert.Equal(t, "meta.action", term.FieldVal)
+			assert.Equal(t, "Note", term.Term)
+
+			num, ok := must.Conjuncts[1].(*query.NumericRangeQuery)
+			require.True(t, ok)
+			assert.Equal(t, 1.0, *num.Min)
+			assert.Nil(t, num.Max)
+
+			date, ok := must.Conjuncts[2].(*query.DateRangeQuery)
+			require.True(t, ok)
+			assert.Equal(t, 2019, date.Start.Year())
+			assert.True(t, date.End.IsZero())
+
+			require.NotNil(t, bq.MustNot)
+
+			return &bleve.SearchResult{}, nil
+		})
+
+		rsp, err := client.Search(ctx, &pb.SearchRequest{
+			Must: []*pb.Clause{
+				{Field: "meta.action", Term: "Note"},
+				{Field: "meta.priority", NumericRange: &pb.NumericRange{Min: &pb.NumericBound{Value: 1}}},
+				{Field: "data.date", DateRange: &pb.DateRange{Start: "2019-01-01T00:00:00Z"}},
+			},
+			MustNot: []*pb.Clause{{Field: "meta.process.state", Term: "DONE"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), rsp.Total)
+	})
+
+	t.Run("Rejects invalid clauses", func(t *testing.T) {
+		tests := []*pb.Clause{
+			{Field: "data.name", Term: "alice"},
+			{Field: "meta.priority", NumericRange: &pb.NumericRange{}},
+			{Field: "data.date", DateRange: &pb.DateRange{Start: "yesterday"}},
+			{Field: "meta.action", Term: "Note", Match: "note"},
+		}
+		for _, c := range tests {
+			_, err := client.Search(ctx, &pb.SearchRequest{Must: []*pb.Clause{c}})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), c.Field)
+		}
+	})
+
+	t.Run("Requires a query", func(t *testing.T) {
+		_, err := client.Search(ctx, &pb.SearchRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestSearchService_GRPCAuth(t *testing.T) {
+	accountMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"accountId":"1"}`))
+	}))
+	defer accountMock.Close()
+
+	ctrl := gomock.NewController(t)
+	mockStore := mockblevestore.NewMockIndex(ctrl)
+
+	s := &search.Service{}
+	s.SetConfig(search.Config{
+		Store:      "blevestore",
+		AccountURL: accountMock.URL,
+	})
+	require.NoError(t, s.Plug(map[string]interface{}{
+		"blevestore": mockStore,
+	}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	gs := grpc.NewServer()
+	s.AddToGRPCServer(gs)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewSearchClient(conn)
+
+	t.Run("Rejects the calls without a valid token", func(t *testing.T) {
+		mockStore.EXPECT().Search(gomock.Any()).Times(0)
+
+		_, err := client.Search(context.Background(), &pb.SearchRequest{Query: "life"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer bad token")
+		_, err = client.Search(ctx, &pb.SearchRequest{Query: "life"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Searches with a valid token", func(t *testing.T) {
+		mockStore.EXPECT().Search(gomock.Any()).Return(&bleve.SearchResult{}, nil).Times(1)
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+		_, err := client.Search(ctx, &pb.SearchRequest{Query: "life"})
+		assert.NoError(t, err)
+	})
+}